@@ -0,0 +1,216 @@
+package repr
+
+import (
+	"reflect"
+)
+
+/*
+Pointers which occur more than once in the declarations, mapped to the names of
+the variables declaring them. See "Decls.PreserveAliasing".
+*/
+type aliasing struct {
+	names map[visitKey]string
+
+	// Variables which are part of reference cycles. They're declared via "new"
+	// and filled in by "init", since package-level variables can't refer to
+	// themselves in their initializers, even indirectly.
+	deferred map[string]bool
+}
+
+// Returns the name of the variable declaring the pointer, if any.
+func (self *aliasing) name(val interface{}) (string, bool) {
+	rval := reflect.ValueOf(val)
+	if rval.Kind() != reflect.Ptr || rval.IsNil() {
+		return ``, false
+	}
+	name, ok := self.names[visitKey{rval.Pointer(), rval.Type()}]
+	return name, ok
+}
+
+/*
+Finds pointers which occur more than once, see "Decls.PreserveAliasing". Returns
+declarations of variables for such pointers, in the order of first occurrence,
+and the mapping used to refer to them. Pointers which are the roots of
+declarations are referred to by the names of those declarations. Names of the
+given declarations are avoided.
+*/
+func (self *Decls) aliased(taken []decl) ([]decl, *aliasing) {
+	counts := map[visitKey]int{}
+	var order []visitKey
+	vals := map[visitKey]interface{}{}
+
+	for _, decl := range self.list {
+		Walk(decl.val, func(_ string, rval reflect.Value) bool {
+			if rval.Kind() != reflect.Ptr || rval.IsNil() || !rval.CanInterface() {
+				return true
+			}
+			key := visitKey{rval.Pointer(), rval.Type()}
+			counts[key]++
+			if counts[key] > 1 {
+				return false
+			}
+			order = append(order, key)
+			vals[key] = rval.Interface()
+			return true
+		}, self.Config)
+	}
+
+	alias := &aliasing{names: map[visitKey]string{}, deferred: map[string]bool{}}
+	var roots []decl
+
+	for _, decl := range self.list {
+		rval := reflect.ValueOf(decl.val)
+		if rval.Kind() != reflect.Ptr || rval.IsNil() {
+			continue
+		}
+		key := visitKey{rval.Pointer(), rval.Type()}
+		if counts[key] > 1 && alias.names[key] == `` {
+			alias.names[key] = decl.name
+			roots = append(roots, decl)
+		}
+	}
+
+	// Names are reserved in a copy, keeping repeated calls deterministic.
+	names := Decls{names: make(map[string]struct{}, len(self.names))}
+	for name := range self.names {
+		names.reserve(name)
+	}
+	for _, decl := range taken {
+		names.reserve(decl.name)
+	}
+
+	var list []decl
+	for _, key := range order {
+		if counts[key] < 2 || alias.names[key] != `` {
+			continue
+		}
+		name := names.unique(NameByType(vals[key]))
+		alias.names[key] = name
+		list = append(list, decl{name: name, val: vals[key]})
+	}
+
+	alias.findCycles(append(roots, list...), self.Config)
+	return list, alias
+}
+
+/*
+Marks variables which are part of reference cycles as deferred. The variables
+form a graph where each variable refers to the variables printed in its
+initializer. Uses Tarjan's algorithm for strongly connected components.
+*/
+func (self *aliasing) findCycles(list []decl, conf Config) {
+	edges := make(map[string][]string, len(list))
+	for _, decl := range list {
+		root := reflect.ValueOf(decl.val)
+		rootKey := visitKey{root.Pointer(), root.Type()}
+
+		Walk(decl.val, func(path string, rval reflect.Value) bool {
+			if rval.Kind() != reflect.Ptr || rval.IsNil() {
+				return true
+			}
+			key := visitKey{rval.Pointer(), rval.Type()}
+			if key == rootKey && path == `` {
+				return true
+			}
+			name, ok := self.names[key]
+			if ok {
+				edges[decl.name] = append(edges[decl.name], name)
+			}
+			return !ok
+		}, conf)
+	}
+
+	var (
+		index   = map[string]int{}
+		low     = map[string]int{}
+		onStack = map[string]bool{}
+		stack   []string
+		visit   func(string)
+	)
+
+	visit = func(name string) {
+		index[name] = len(index)
+		low[name] = index[name]
+		stack = append(stack, name)
+		onStack[name] = true
+
+		for _, next := range edges[name] {
+			if next == name {
+				self.deferred[name] = true
+			}
+			if _, ok := index[next]; !ok {
+				visit(next)
+				low[name] = minInt(low[name], low[next])
+			} else if onStack[next] {
+				low[name] = minInt(low[name], index[next])
+			}
+		}
+
+		if low[name] != index[name] {
+			return
+		}
+
+		start := len(stack) - 1
+		for stack[start] != name {
+			start--
+		}
+		component := stack[start:]
+		stack = stack[:start]
+
+		for _, elem := range component {
+			onStack[elem] = false
+			if len(component) > 1 {
+				self.deferred[elem] = true
+			}
+		}
+	}
+
+	for _, decl := range list {
+		if _, ok := index[decl.name]; !ok {
+			visit(decl.name)
+		}
+	}
+}
+
+/*
+Appends "func init" which fills in deferred variables, see "aliasing.deferred":
+
+	func init() {
+		*node = Node{Next: node2}
+		*node2 = Node{Next: node}
+	}
+*/
+func (self *Decls) appendInit(out []byte, list []decl, fmter fmter) []byte {
+	alias := fmter.state.aliasing
+	if alias == nil || len(alias.deferred) == 0 {
+		return out
+	}
+
+	indent := self.Config.Indent
+	if indent == `` {
+		indent = "\t"
+	}
+
+	out = appendNewline(out, fmter)
+	out = append(out, `func init() {`...)
+	out = appendNewline(out, fmter)
+
+	fmter.indent = 1
+	for _, decl := range list {
+		if !alias.deferred[decl.name] {
+			continue
+		}
+		out = append(out, indent...)
+		out = append(out, '*')
+		out = append(out, decl.name...)
+		out = append(out, ` = `...)
+
+		// The target is declared by this statement.
+		fmter.noShare = true
+		out = appendAny(out, reflect.ValueOf(decl.val).Elem().Interface(), fmter)
+		out = appendNewline(out, fmter)
+	}
+
+	out = append(out, '}')
+	return appendNewline(out, fmter)
+}
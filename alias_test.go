@@ -0,0 +1,64 @@
+package repr
+
+import (
+	"go/format"
+	"testing"
+)
+
+func TestDeclsPreserveAliasing(t *testing.T) {
+	shared := &testNode{Value: 1}
+	one := &testNode{Value: 2}
+	two := &testNode{Value: 3, Next: one}
+	one.Next = two
+
+	decls := Decls{Config: CompactConfig, PreserveAliasing: true}
+	decls.AddNamed(`list`, []*testNode{shared, shared, one})
+	decls.AddNamed(`two`, two)
+	decls.AddNamed(`single`, &testNode{Value: 4})
+
+	actual := decls.String()
+	expected := `var (
+	list = []*repr.testNode{testNodeVal, testNodeVal, testNodeVal2}
+
+	two = new(repr.testNode)
+
+	single = &repr.testNode{Value: 4}
+
+	testNodeVal = &repr.testNode{Value: 1}
+
+	testNodeVal2 = new(repr.testNode)
+)
+
+func init() {
+	*two = repr.testNode{Value: 3, Next: testNodeVal2}
+	*testNodeVal2 = repr.testNode{Value: 2, Next: two}
+}
+`
+	if actual != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+	}
+
+	_, err := format.Source([]byte("package repr\n\n" + actual))
+	if err != nil {
+		t.Fatalf("invalid output: %v", err)
+	}
+
+	self := &testNode{Value: 5}
+	self.Next = self
+
+	decls = Decls{Config: Default, PreserveAliasing: true}
+	decls.AddNamed(`self`, self)
+	actual = decls.String()
+	expected = `var self = new(repr.testNode)
+
+func init() {
+	*self = repr.testNode{
+		Value: 5,
+		Next: self,
+	}
+}
+`
+	if actual != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+	}
+}
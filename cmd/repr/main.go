@@ -0,0 +1,93 @@
+/*
+Command repr generates a Go file declaring a variable, from a Go expression or
+JSON input. Designed for "go:generate" directives:
+
+	//go:generate repr -json=fixtures.json -var=fixtures -o=fixtures.go
+
+Flags:
+
+	-o       output path; defaults to stdout
+	-pkg     package name; defaults to $GOPACKAGE
+	-var     variable name; required
+	-expr    Go expression using built-in types only
+	-json    path to a JSON file, or "-" for stdin
+	-single  single-line output
+	-time    record the generation time in the header
+
+The output starts with the standard "Code generated ... DO NOT EDIT." header,
+which records the command line.
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/mitranim/repr"
+)
+
+func main() {
+	err := run(os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	flags := flag.NewFlagSet(`repr`, flag.ContinueOnError)
+	output := flags.String(`o`, ``, `output path; defaults to stdout`)
+	pkg := flags.String(`pkg`, ``, `package name; defaults to $GOPACKAGE`)
+	name := flags.String(`var`, ``, `variable name`)
+	expr := flags.String(`expr`, ``, `Go expression using built-in types only`)
+	jsonPath := flags.String(`json`, ``, `path to a JSON file, or "-" for stdin`)
+	single := flags.Bool(`single`, false, `single-line output`)
+	stamp := flags.Bool(`time`, false, `record the generation time in the header`)
+
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	opts := repr.GenerateOptions{
+		Output:  *output,
+		Package: *pkg,
+		Var:     *name,
+		Expr:    *expr,
+		Config:  repr.Default,
+		Header: repr.Header{
+			Generator: `repr`,
+			Command:   append([]string{`repr`}, args...),
+		},
+	}
+	if *stamp {
+		opts.Header.Time = time.Now()
+	}
+
+	if *jsonPath != `` {
+		opts.Config = repr.JSONConfig
+		opts.Source = *jsonPath
+		if opts.Source == `-` {
+			opts.Source = `stdin`
+		}
+		opts.JSON, err = readInput(*jsonPath)
+		if err != nil {
+			return err
+		}
+	}
+	if *single {
+		opts.Config.Indent = ``
+	}
+
+	return repr.Generate(opts)
+}
+
+func readInput(path string) ([]byte, error) {
+	if path == `-` {
+		return ioutil.ReadAll(os.Stdin)
+	}
+	return ioutil.ReadFile(path)
+}
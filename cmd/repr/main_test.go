@@ -0,0 +1,46 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunJSON(t *testing.T) {
+	dir, err := ioutil.TempDir(``, `repr`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	input := filepath.Join(dir, `fixtures.json`)
+	output := filepath.Join(dir, `fixtures.go`)
+	err = ioutil.WriteFile(input, []byte(`{"one": [1, 2.5], "two": "three"}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = run([]string{`-json=` + input, `-o=` + output, `-pkg=fixtures`, `-var=fixtures`})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	actual, err := ioutil.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `var fixtures = map[string]interface{}{
+	"one": []interface{}{
+		float64(1),
+		2.5,
+	},
+	"two": "three",
+}
+`
+	if !strings.HasSuffix(string(actual), expected) {
+		t.Fatalf("expected output ending with:\n%v\nactual output:\n%s", expected, actual)
+	}
+}
@@ -0,0 +1,115 @@
+package repr
+
+import (
+	"reflect"
+)
+
+/*
+Identifies a reference value. The type is part of the key because a pointer to
+a struct and a pointer to its first field share the address.
+*/
+type visitKey struct {
+	ptr   uintptr
+	rtype reflect.Type
+}
+
+/*
+References being printed by the ancestors of the current value, mapped to their
+paths, which are empty unless tracked. Printing a reference which is already
+being printed would never terminate, so it's printed as "nil" with a comment:
+
+	&Node{
+		Value: 1,
+		Next: &Node{
+			Value: 2,
+			Next: &Node{
+				Value: 3,
+				Next: nil /* cyclic reference to .Next *\/,
+			},
+		},
+	}
+
+Allocated on the first reference which may be cyclic, and shared with the
+contents of that reference.
+*/
+type visiting map[visitKey]string
+
+// Used by "fmter.measure", which may abandon rendering midway without removing
+// its entries.
+func (self visiting) clone() visiting {
+	if self == nil {
+		return nil
+	}
+	out := make(visiting, len(self))
+	for key, path := range self {
+		out[key] = path
+	}
+	return out
+}
+
+/*
+True for non-empty references whose contents may lead back to them. Cycles
+require pointers, maps, slices or interfaces, which aren't primitive.
+*/
+func mayCycle(rval reflect.Value) bool {
+	rtype := rval.Type()
+	switch rtype.Kind() {
+	case reflect.Ptr:
+		return !rval.IsNil() && !isPrimitive(rtype.Elem())
+	case reflect.Slice:
+		return rval.Len() > 0 && !isPrimitive(rtype.Elem())
+	case reflect.Map:
+		return rval.Len() > 0 && !(isPrimitive(rtype.Key()) && isPrimitive(rtype.Elem()))
+	default:
+		return false
+	}
+}
+
+/*
+Marks the reference as being visited by the returned formatter and its copies.
+Returns false if an ancestor is already visiting it, along with the path of the
+ancestor. Callers must call "leave" afterwards.
+*/
+func (self fmter) visit(rval reflect.Value) (fmter, visitKey, string, bool) {
+	key := visitKey{rval.Pointer(), rval.Type()}
+	if path, ok := self.visiting[key]; ok {
+		return self, key, path, false
+	}
+	if self.visiting == nil {
+		self.visiting = visiting{}
+	}
+	self.visiting[key] = self.path
+	if self.stack != nil {
+		self.stack.visited = append(self.stack.visited, visitEntry{key, self.path})
+	}
+	return self, key, ``, true
+}
+
+func (self fmter) leave(key visitKey) {
+	delete(self.visiting, key)
+	if self.stack != nil {
+		self.stack.visited = self.stack.visited[:len(self.stack.visited)-1]
+	}
+}
+
+// Used by "appendValue" for values which satisfy "mayCycle".
+func appendVisit(out []byte, rval reflect.Value, fmter fmter) []byte {
+	fmter, key, path, ok := fmter.visit(rval)
+	if !ok {
+		return appendCyclic(out, rval, path, fmter)
+	}
+	out = appendReflect(out, rval, fmter)
+	fmter.leave(key)
+	return out
+}
+
+func appendCyclic(out []byte, rval reflect.Value, path string, fmter fmter) []byte {
+	fmter.warn(rval.Type(), `cyclic reference is printed as nil`)
+	if !fmter.tracksPaths() {
+		return appendPlaceholder(out, `cyclic reference`)
+	}
+	if path == `` {
+		return appendPlaceholder(out, `cyclic reference to the root`)
+	}
+	return appendPlaceholder(out, `cyclic reference to `+path)
+}
@@ -0,0 +1,74 @@
+package repr
+
+import (
+	"reflect"
+	"testing"
+)
+
+type testNode struct {
+	Value int
+	Next  *testNode
+}
+
+func TestCycles(t *testing.T) {
+	one := &testNode{Value: 1}
+	two := &testNode{Value: 2}
+	three := &testNode{Value: 3, Next: two}
+	one.Next, two.Next = two, three
+
+	test := func(expected string, val interface{}) {
+		t.Helper()
+		actual := StringC(val, CompactConfig)
+		if actual != expected {
+			t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+		}
+	}
+
+	test(`&repr.testNode{Value: 1, Next: &repr.testNode{Value: 2, Next: &repr.testNode{Value: 3, Next: nil /* cyclic reference */}}}`, one)
+
+	list := []interface{}{1, nil}
+	list[1] = list
+	test(`[]interface {}{1, nil /* cyclic reference */}`, list)
+
+	dict := map[string]interface{}{}
+	dict[`self`] = dict
+	test(`map[string]interface {}{"self": nil /* cyclic reference */}`, dict)
+
+	// Shared references which aren't cyclic are printed in full.
+	shared := &testNode{Value: 1}
+	test(`[]*repr.testNode{{Value: 1}, {Value: 1}}`, []*testNode{shared, shared})
+
+	res, err := Format(one, Default)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `&repr.testNode{
+	Value: 1,
+	Next: &repr.testNode{
+		Value: 2,
+		Next: &repr.testNode{
+			Value: 3,
+			Next: nil /* cyclic reference to .Next */,
+		},
+	},
+}`
+	if string(res.Bytes) != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, string(res.Bytes))
+	}
+	if len(res.Warnings) != 1 || res.Warnings[0].Path != `.Next.Next.Next` {
+		t.Fatalf(`expected a warning about the cycle, got %v`, res.Warnings)
+	}
+
+	var paths []string
+	Walk(one, func(path string, _ reflect.Value) bool {
+		paths = append(paths, path)
+		return true
+	}, CompactConfig)
+	if len(paths) != 10 {
+		t.Fatalf(`unexpected paths: %q`, paths)
+	}
+
+	if size := EstimateSize(dict, CompactConfig); size <= 0 {
+		t.Fatalf(`unexpected size estimate: %v`, size)
+	}
+}
@@ -0,0 +1,384 @@
+package repr
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+Settings of "Debug", "Q", "Dump" and "DumpNamed". Not synchronized: modify
+before use, such as in "init".
+*/
+type DebugOptions struct {
+	/**
+	If true, output is prefixed with the time of the call, with millisecond
+	precision.
+	*/
+	Time bool
+
+	/**
+	If positive, each call site prints at most this many times per second, and
+	further calls are dropped. The next printed output mentions the number of
+	dropped calls. Useful when a debug call is left in a hot loop, which would
+	otherwise flood the logs.
+	*/
+	MaxPerSecond int
+}
+
+/*
+See "DebugOptions". Read by every debug call without synchronization: set it
+once before any concurrent use, such as in "init", and don't modify it
+afterwards.
+*/
+var Debugging DebugOptions
+
+/*
+Prints each value to stderr using "DebugConfig", prefixed with the file, line
+and function of the caller. A replacement for `fmt.Printf("%+v\n", val)` which
+shows where the output came from:
+
+	repr.Debug(req, resp)
+
+	// handler.go:42 api.serve: &http.Request{
+	// 	...
+	// }
+	// handler.go:42 api.serve: 200
+*/
+func Debug(vals ...interface{}) {
+	note, ok := debugNote(1)
+	if ok {
+		writeDebug(os.Stderr, note+callerPrefix(1), nil, vals)
+	}
+}
+
+/*
+Prints several values to stdout using "DebugConfig", numbered and separated by
+blank lines, for inspecting related values together:
+
+	repr.Dump(req, resp)
+
+	// 1
+	&http.Request{
+		...
+	}
+
+	// 2
+	200
+
+A single value is printed without a number.
+*/
+func Dump(vals ...interface{}) (int, error) {
+	note, ok := debugNote(1)
+	if !ok {
+		return 0, nil
+	}
+	return os.Stdout.Write(appendDump(appendNoteComment(nil, note), vals, DebugConfig))
+}
+
+func appendDump(out []byte, vals []interface{}, conf Config) []byte {
+	for i, val := range vals {
+		if i > 0 {
+			out = append(out, '\n')
+		}
+		if len(vals) > 1 {
+			out = append(out, `// `...)
+			out = strconv.AppendInt(out, int64(i+1), 10)
+			out = append(out, '\n')
+		}
+		out = appendDebug(out, val, conf)
+		out = append(out, '\n')
+	}
+	return out
+}
+
+/*
+Prints alternating names and values to stdout using "DebugConfig", as
+self-describing assignments:
+
+	repr.DumpNamed("id", id, "user", user)
+
+	id = 10
+	user = &User{
+		Name: "one",
+	}
+
+Names which aren't strings are printed via "fmt.Sprint". A trailing name
+without a value is followed by a comment.
+*/
+func DumpNamed(pairs ...interface{}) (int, error) {
+	note, ok := debugNote(1)
+	if !ok {
+		return 0, nil
+	}
+	return os.Stdout.Write(appendNamed(appendNoteComment(nil, note), pairs, DebugConfig))
+}
+
+func appendNamed(out []byte, pairs []interface{}, conf Config) []byte {
+	for i := 0; i < len(pairs); i += 2 {
+		name, ok := pairs[i].(string)
+		if !ok {
+			name = fmt.Sprint(pairs[i])
+		}
+		out = append(out, name...)
+
+		if i+1 < len(pairs) {
+			out = append(out, ` = `...)
+			out = appendDebug(out, pairs[i+1], conf)
+		} else {
+			out = append(out, ` /* missing value */`...)
+		}
+		out = append(out, '\n')
+	}
+	return out
+}
+
+/*
+Same as "Debug", but labels each value with the expression which produced it,
+read from the source of the caller, like the "q" debugging package:
+
+	repr.Q(x+y, conf.Items[0])
+
+	// main.go:12 main.run: x+y = 3
+	// main.go:12 main.run: conf.Items[0] = "one"
+
+Requires the source file to be available at the path recorded in the binary,
+which is the case for "go run" and "go test". Otherwise values are printed
+without labels. If a line has several calls of "Q" with the same number of
+arguments, the labels are taken from the first.
+*/
+func Q(vals ...interface{}) {
+	note, ok := debugNote(1)
+	if ok {
+		writeDebug(os.Stderr, note+callerPrefix(1), callerArgs(1, `Q`, len(vals)), vals)
+	}
+}
+
+// Writes all values in a single call, which keeps the output of concurrent
+// calls from interleaving, and ignores errors, like the "log" package. Labels
+// are optional.
+func writeDebug(out io.Writer, prefix string, labels []string, vals []interface{}) {
+	var buf []byte
+	for i, val := range vals {
+		buf = append(buf, prefix...)
+		buf = append(buf, ' ')
+		if i < len(labels) {
+			buf = append(buf, labels[i]...)
+			buf = append(buf, ` = `...)
+		}
+		buf = appendDebug(buf, val, DebugConfig)
+		buf = append(buf, '\n')
+	}
+	_, _ = out.Write(buf)
+}
+
+/*
+Debugging helpers must not crash the program. Values which can't be formatted,
+such as pointers to non-composite types, are printed via "%#v" instead.
+*/
+func appendDebug(out []byte, val interface{}, conf Config) (res []byte) {
+	start := len(out)
+	defer func() {
+		if recover() != nil {
+			res = append(out[:start], fmt.Sprintf(`%#v`, val)...)
+		}
+	}()
+	return AppendC(out, val, conf)
+}
+
+/*
+Applies "Debugging" to the caller of the function which called this, skipping
+the given number of additional frames. Returns false if the call must be
+dropped. Otherwise returns the text to prepend to the output, such as
+"15:04:05.000 (3 dropped) ", which is empty by default.
+*/
+func debugNote(skip int) (string, bool) {
+	opts := Debugging
+	now := time.Now()
+	note := ``
+
+	if opts.MaxPerSecond > 0 {
+		pc, _, _, _ := runtime.Caller(skip + 1)
+		ok, dropped := debugLimiter.allow(pc, opts.MaxPerSecond, now)
+		if !ok {
+			return ``, false
+		}
+		if dropped > 0 {
+			note = `(` + strconv.Itoa(dropped) + ` dropped) `
+		}
+	}
+
+	if opts.Time {
+		note = now.Format(`15:04:05.000`) + ` ` + note
+	}
+	return note, true
+}
+
+// Used by "Dump" and "DumpNamed", where the note precedes the values on a
+// separate line.
+func appendNoteComment(out []byte, note string) []byte {
+	if note == `` {
+		return out
+	}
+	out = append(out, `// `...)
+	out = append(out, strings.TrimSpace(note)...)
+	return append(out, '\n')
+}
+
+var debugLimiter = rateLimiter{sites: map[uintptr]*rateWindow{}}
+
+// Counts calls per call site in windows of one second. See
+// "DebugOptions.MaxPerSecond".
+type rateLimiter struct {
+	sync.Mutex
+	sites map[uintptr]*rateWindow
+}
+
+type rateWindow struct {
+	start   time.Time
+	count   int
+	dropped int
+}
+
+// Returns true if the call is allowed, along with the number of calls dropped
+// since the previous allowed call, which resets the count.
+func (self *rateLimiter) allow(site uintptr, max int, now time.Time) (bool, int) {
+	self.Lock()
+	defer self.Unlock()
+
+	win := self.sites[site]
+	if win == nil {
+		win = &rateWindow{start: now}
+		self.sites[site] = win
+	}
+	if now.Sub(win.start) >= time.Second {
+		win.start = now
+		win.count = 0
+	}
+	if win.count >= max {
+		win.dropped++
+		return false, 0
+	}
+
+	win.count++
+	dropped := win.dropped
+	win.dropped = 0
+	return true, dropped
+}
+
+/*
+Describes the caller of the function which called this, skipping the given
+number of additional frames, as "file.go:12 pkg.func:". The package path is
+shortened to the package name.
+*/
+func callerPrefix(skip int) string {
+	pc, file, line, ok := runtime.Caller(skip + 1)
+	if !ok {
+		return `???:`
+	}
+
+	out := filepath.Base(file) + `:` + strconv.Itoa(line)
+	if fun := runtime.FuncForPC(pc); fun != nil {
+		name := fun.Name()
+		out += ` ` + name[strings.LastIndexByte(name, '/')+1:]
+	}
+	return out + `:`
+}
+
+/*
+Returns the source code of the arguments of the call of the named function on
+the line of the caller of the function which called this, skipping the given
+number of additional frames. Returns nil if the source is unavailable or the
+call isn't found.
+*/
+func callerArgs(skip int, name string, count int) []string {
+	_, path, line, ok := runtime.Caller(skip + 1)
+	if !ok {
+		return nil
+	}
+
+	src, file := parseSource(path)
+	if file == nil {
+		return nil
+	}
+
+	var out []string
+	ast.Inspect(file, func(node ast.Node) bool {
+		if out != nil {
+			return false
+		}
+		call, _ := node.(*ast.CallExpr)
+		if call == nil || !isCallAt(call, name, count, line) {
+			return true
+		}
+
+		out = make([]string, 0, count)
+		for _, arg := range call.Args {
+			start := sourceFset.Position(arg.Pos()).Offset
+			end := sourceFset.Position(arg.End()).Offset
+			out = append(out, string(src[start:end]))
+		}
+		return false
+	})
+	return out
+}
+
+// True if the call of the named function spans the line and has the given
+// number of arguments, without a spread.
+func isCallAt(call *ast.CallExpr, name string, count, line int) bool {
+	if len(call.Args) != count || call.Ellipsis.IsValid() {
+		return false
+	}
+	if sourceFset.Position(call.Pos()).Line > line || sourceFset.Position(call.End()).Line < line {
+		return false
+	}
+
+	switch fun := call.Fun.(type) {
+	case *ast.Ident:
+		return fun.Name == name
+	case *ast.SelectorExpr:
+		return fun.Sel.Name == name
+	default:
+		return false
+	}
+}
+
+type parsedSource struct {
+	src  []byte
+	file *ast.File
+}
+
+// Parsed source files by path, including failures, which are cached as nil.
+var (
+	sourceCache sync.Map
+	sourceFset  = token.NewFileSet()
+)
+
+func parseSource(path string) ([]byte, *ast.File) {
+	cached, ok := sourceCache.Load(path)
+	if !ok {
+		var parsed parsedSource
+		src, err := ioutil.ReadFile(path)
+		if err == nil {
+			parsed.file, err = parser.ParseFile(sourceFset, path, src, 0)
+			if err == nil {
+				parsed.src = src
+			} else {
+				parsed.file = nil
+			}
+		}
+		cached, _ = sourceCache.LoadOrStore(path, parsed)
+	}
+	parsed := cached.(parsedSource)
+	return parsed.src, parsed.file
+}
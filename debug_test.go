@@ -0,0 +1,138 @@
+package repr
+
+import (
+	"bytes"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestDebug(t *testing.T) {
+	type Data struct{ Name string }
+
+	var buf bytes.Buffer
+	writeDebug(&buf, testCallerPrefix(), nil, []interface{}{Data{`one`}, 10})
+
+	expected := regexp.MustCompile(`^debug_test\.go:\d+ repr\.TestDebug: repr\.Data\{
+	Name: "one",
+\}
+debug_test\.go:\d+ repr\.TestDebug: 10
+$`)
+	if !expected.Match(buf.Bytes()) {
+		t.Fatalf("unexpected output:\n%s", buf.Bytes())
+	}
+}
+
+func TestDump(t *testing.T) {
+	type Data struct{ Name string }
+
+	actual := string(appendDump(nil, []interface{}{Data{`one`}, 10, nil}, DebugConfig))
+	expected := `// 1
+repr.Data{
+	Name: "one",
+}
+
+// 2
+10
+
+// 3
+nil
+`
+	if actual != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+	}
+
+	actual = string(appendDump(nil, []interface{}{10}, DebugConfig))
+	if actual != "10\n" {
+		t.Fatalf(`unexpected output for a single value: %q`, actual)
+	}
+
+	// Unsupported values fall back on "%#v" instead of panicking.
+	val := 10
+	actual = string(appendDump(nil, []interface{}{&val, 20}, DebugConfig))
+	expected = "// 1\n(*int)(0x"
+	if actual[:len(expected)] != expected || actual[len(actual)-len("// 2\n20\n"):] != "// 2\n20\n" {
+		t.Fatalf("unexpected output for an unsupported value:\n%v", actual)
+	}
+}
+
+func TestDumpNamed(t *testing.T) {
+	type Data struct{ Name string }
+
+	actual := string(appendNamed(nil, []interface{}{`one`, 10, `two`, Data{`two`}, 3, nil, `four`}, DebugConfig))
+	expected := `one = 10
+two = repr.Data{
+	Name: "two",
+}
+3 = nil
+four /* missing value */
+`
+	if actual != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+	}
+
+	val := 10
+	actual = string(appendNamed(nil, []interface{}{`ptr`, &val}, DebugConfig))
+	if !regexp.MustCompile(`^ptr = \(\*int\)\(0x[0-9a-f]+\)\n$`).MatchString(actual) {
+		t.Fatalf("unexpected output for an unsupported value:\n%v", actual)
+	}
+}
+
+func TestQ(t *testing.T) {
+	type Conf struct{ Items []string }
+	conf := Conf{Items: []string{`one`}}
+	one, two := 1, 2
+
+	labels := testQ(one+two, conf.Items[0])
+	expected := []string{`one+two`, `conf.Items[0]`}
+	if !reflect.DeepEqual(labels, expected) {
+		t.Fatalf(`expected labels %q, got %q`, expected, labels)
+	}
+
+	labels = testQ(
+		one,
+		conf,
+	)
+	expected = []string{`one`, `conf`}
+	if !reflect.DeepEqual(labels, expected) {
+		t.Fatalf(`expected labels %q, got %q`, expected, labels)
+	}
+
+	var buf bytes.Buffer
+	writeDebug(&buf, `main.go:1 main.main:`, labels, []interface{}{one, `two`})
+	if actual := buf.String(); actual != "main.go:1 main.main: one = 1\nmain.go:1 main.main: conf = \"two\"\n" {
+		t.Fatalf(`unexpected output: %q`, actual)
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	limiter := rateLimiter{sites: map[uintptr]*rateWindow{}}
+	start := time.Now()
+
+	test := func(site uintptr, offset time.Duration, expectedOk bool, expectedDropped int) {
+		t.Helper()
+		ok, dropped := limiter.allow(site, 2, start.Add(offset))
+		if ok != expectedOk || dropped != expectedDropped {
+			t.Fatalf(`expected (%v, %v), got (%v, %v)`, expectedOk, expectedDropped, ok, dropped)
+		}
+	}
+
+	test(1, 0, true, 0)
+	test(1, time.Millisecond, true, 0)
+	test(1, 2*time.Millisecond, false, 0)
+	test(1, 3*time.Millisecond, false, 0)
+	test(2, 3*time.Millisecond, true, 0)
+	test(1, time.Second, true, 2)
+	test(1, time.Second+time.Millisecond, true, 0)
+
+	if note := appendNoteComment(nil, `15:04:05.000 (2 dropped) `); string(note) != "// 15:04:05.000 (2 dropped)\n" {
+		t.Fatalf(`unexpected note: %q`, note)
+	}
+}
+
+// Called like "callerArgs" is called by "Q".
+func testQ(vals ...interface{}) []string { return callerArgs(1, `testQ`, len(vals)) }
+
+// Called like "callerPrefix" is called by "Debug", describing the caller.
+func testCallerPrefix() string { return callerPrefix(1) }
@@ -0,0 +1,657 @@
+package repr
+
+import (
+	"fmt"
+	"go/token"
+	"go/types"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+/*
+Accumulates values and emits them as Go variable declarations. Useful for code
+generation. The zero value is ready to use. Values are formatted with the
+"Config" field as-is; set it to "Default" for multiline output.
+
+Names are either explicit, see "Decls.AddNamed", or generated by "Namer", see
+"Decls.Add". Collisions are resolved by appending numeric suffixes, which makes
+the output deterministic for a given sequence of calls.
+*/
+type Decls struct {
+	Config Config
+
+	/**
+	Generates names for values added via "Decls.Add". Defaults to "NameByType".
+	*/
+	Namer Namer
+
+	/**
+	If true, values representable as constants, such as strings, numbers and
+	named types based on them, are declared with "const" instead of "var".
+	*/
+	Const bool
+
+	/**
+	If positive, identical struct and array values whose single-line output
+	takes at least this many bytes, occurring more than once, are declared once
+	as separate variables and referenced by name, which can greatly shrink files
+	with repetitive data. Values are compared by type and output, regardless of
+	pointer identity. Shared variables are named via "NameByType" and declared
+	after other variables. Pointer targets and other reference types, such as
+	slices and maps, aren't shared, since sharing them would alias memory which
+	is separate in the original data.
+	*/
+	Dedup int
+
+	/**
+	If true, pointers which occur more than once, including in reference
+	cycles, are declared once as separate variables and referenced by name,
+	which reproduces the original object graph when the code is compiled:
+
+		var (
+			list = []*Node{node, node}
+
+			node = &Node{Value: 1}
+		)
+
+	Without this, the targets of such pointers are printed at every occurrence,
+	and cyclic references are printed as nil. Pointers which are the roots of
+	declarations are referenced by the names of those declarations. Other
+	variables are named via "NameByType" and declared after other variables.
+	Variables in cycles are declared via "new" and filled in by a generated
+	"func init", since package-level variables can't refer to themselves in
+	their initializers:
+
+		var node = new(Node)
+
+		func init() {
+			*node = Node{Value: 1, Next: node}
+		}
+	*/
+	PreserveAliasing bool
+
+	/**
+	If positive, strings of at least this many bytes, occurring more than once
+	inside the declared values, are declared once as constants and referenced
+	by name, which shrinks sources and binaries with repeated URLs, schema
+	names or messages:
+
+		const homeURL = "https://example.com"
+
+		var links = []Link{
+			{Title: "Home", HomeURL: homeURL},
+			{Title: "About", HomeURL: homeURL},
+		}
+
+	Constants are named after the struct field of the first occurrence, or
+	"str", and declared after other constants. Strings printed as something
+	other than plain literals, such as truncated strings, aren't interned.
+	*/
+	InternStrings int
+
+	list  []decl
+	names map[string]struct{}
+}
+
+type decl struct {
+	name   string
+	val    interface{}
+	source string
+}
+
+/*
+Generates a variable name for the given value. The result doesn't need to be
+unique; "Decls" resolves collisions. An empty result is replaced with "val".
+*/
+type Namer func(val interface{}) string
+
+/*
+Adds a value, generating its name via "Decls.Namer". Returns the final name,
+which is guaranteed to be unique within this "Decls".
+*/
+func (self *Decls) Add(val interface{}) string {
+	namer := self.Namer
+	if namer == nil {
+		namer = NameByType
+	}
+	return self.AddNamed(namer(val), val)
+}
+
+/*
+Adds a value under the given name. If the name is already taken, appends a
+numeric suffix. Returns the final name.
+*/
+func (self *Decls) AddNamed(name string, val interface{}) string {
+	return self.AddFrom(name, ``, val)
+}
+
+/*
+Same as "Decls.AddNamed", but precedes the declaration with a comment naming
+its data source, such as a file path or a URL, unless the source is empty:
+
+	// Source: testdata/abi.json
+	abi = ...
+*/
+func (self *Decls) AddFrom(name, source string, val interface{}) string {
+	self.reserveTypeNames(reflect.TypeOf(val), map[reflect.Type]bool{})
+	name = self.unique(name)
+	self.list = append(self.list, decl{name, val, source})
+	return name
+}
+
+/*
+Marks the given names as taken, without declaring them. Use this to avoid
+collisions with other identifiers in the generated file.
+*/
+func (self *Decls) Reserve(names ...string) {
+	for _, name := range names {
+		self.reserve(name)
+	}
+}
+
+/*
+Appends the declarations to the provided buffer. Constants come first, followed
+by variables, each in the order they were added. When there's more than one
+declaration of a kind, they're grouped into a single "const ( ... )" or
+"var ( ... )" block, separated by blank lines. May be followed by "func init",
+see "Decls.PreserveAliasing". Panics on errors reported by "AppendE", such as
+output which can't be expressed in "Config.LangVersion", or failures of
+"Config.Validate"; "File.Bytes" returns an error instead.
+*/
+func (self *Decls) Append(out []byte) []byte {
+	out, err := self.append(out, &state{})
+	if err != nil {
+		panic(err)
+	}
+	return out
+}
+
+func (self *Decls) append(out []byte, state *state) (res []byte, err error) {
+	if err := self.Config.check(); err != nil {
+		return out, err
+	}
+	// See "appendE".
+	if self.Config.langBelow(18) {
+		prev := out
+		defer func() {
+			if val := recover(); val != nil {
+				res, err = prev, langVersionErr(val)
+			}
+		}()
+	}
+
+	fmter := fmter{conf: &self.Config, state: state}
+	fmter.ownConf()
+	fmter.conf.LinePrefix = ``
+
+	var consts, vars []decl
+	for _, decl := range self.list {
+		if self.Const && isConstValue(decl.val) {
+			consts = append(consts, decl)
+		} else {
+			vars = append(vars, decl)
+		}
+	}
+
+	if self.Dedup > 0 {
+		shared, sharing := self.shared()
+		if len(shared) > 0 {
+			fmter.state.sharing = sharing
+			vars = append(vars, shared...)
+
+			// Stubs are named via the copy of "Decls" owned by "File.Bytes".
+			if fmter.state.stubs != nil {
+				for _, decl := range shared {
+					fmter.state.stubs.decls.reserve(decl.name)
+				}
+			}
+		}
+	}
+
+	if self.PreserveAliasing {
+		aliased, aliasing := self.aliased(vars)
+		fmter.state.aliasing = aliasing
+		vars = append(vars, aliased...)
+
+		if fmter.state.stubs != nil {
+			for _, decl := range aliased {
+				fmter.state.stubs.decls.reserve(decl.name)
+			}
+		}
+	}
+
+	if self.InternStrings > 0 {
+		interned, interning := self.interned(append(consts[:len(consts):len(consts)], vars...))
+		fmter.state.interning = interning
+		consts = append(consts, interned...)
+
+		if fmter.state.stubs != nil {
+			for _, decl := range interned {
+				fmter.state.stubs.decls.reserve(decl.name)
+			}
+		}
+	}
+
+	out, err = self.appendBlock(out, `const`, consts, fmter)
+	if err != nil {
+		return out, err
+	}
+	if len(consts) > 0 && len(vars) > 0 {
+		out = appendNewline(out, fmter)
+	}
+	out, err = self.appendBlock(out, `var`, vars, fmter)
+	if err != nil {
+		return out, err
+	}
+	out = self.appendInit(out, vars, fmter)
+	return out, nil
+}
+
+func (self *Decls) appendBlock(out []byte, keyword string, list []decl, fmter fmter) ([]byte, error) {
+	if len(list) == 0 {
+		return out, nil
+	}
+
+	if len(list) == 1 {
+		out = appendSource(out, list[0].source, ``, fmter)
+		out = append(out, keyword...)
+		out = append(out, ' ')
+		out, err := self.appendDecl(out, list[0], fmter)
+		return appendNewline(out, fmter), err
+	}
+
+	indent := self.Config.Indent
+	if indent == `` {
+		indent = "\t"
+	}
+
+	out = append(out, keyword...)
+	out = append(out, ` (`...)
+	out = appendNewline(out, fmter)
+
+	fmter.indent = 1
+	for i, decl := range list {
+		if i > 0 {
+			out = appendNewline(out, fmter)
+		}
+		out = appendSource(out, decl.source, indent, fmter)
+		out = append(out, indent...)
+		var err error
+		out, err = self.appendDecl(out, decl, fmter)
+		if err != nil {
+			return out, err
+		}
+		out = appendNewline(out, fmter)
+	}
+	out = append(out, ')')
+	out = appendNewline(out, fmter)
+	return out, nil
+}
+
+func (self *Decls) appendDecl(out []byte, decl decl, fmter fmter) ([]byte, error) {
+	out = append(out, decl.name...)
+	out = append(out, ` = `...)
+
+	if alias := fmter.state.aliasing; alias != nil && alias.deferred[decl.name] {
+		out = append(out, `new(`...)
+		out = appendTypeName(out, reflect.TypeOf(decl.val).Elem(), fmter)
+		return append(out, ')'), nil
+	}
+
+	// The root of a shared variable must not refer to itself.
+	fmter.noShare = true
+	start := len(out)
+
+	// The declared type is inferred from the value, so numbers whose literals
+	// have a different default type are converted, like with
+	// "Config.TypedNumbers": "uint8(0x05)" rather than "0x05".
+	if name := numberConversion(decl.val); name != `` {
+		fmter.elideType = true
+		out = append(out, name...)
+		out = append(out, '(')
+		out = appendAny(out, decl.val, fmter)
+		out = append(out, ')')
+	} else {
+		out = appendAny(out, decl.val, fmter)
+	}
+	if self.Config.Validate {
+		return out, validate(out[start:])
+	}
+	return out, nil
+}
+
+// See "Decls.AddFrom". Line breaks in the source are replaced with spaces.
+func appendSource(out []byte, source, indent string, fmter fmter) []byte {
+	if source == `` {
+		return out
+	}
+	out = append(out, indent...)
+	out = append(out, `// Source: `...)
+	out = append(out, strings.Join(strings.Fields(source), ` `)...)
+	return appendNewline(out, fmter)
+}
+
+/*
+Selects repeated values to declare as shared variables, see "Decls.Dedup".
+Returns their declarations, in the order of first occurrence, and the mapping
+used to refer to them. Values are counted twice: the first pass counts all
+occurrences, and the second pass counts only the occurrences which remain in
+the output once repeated values are replaced, which avoids sharing values only
+repeated inside other shared values.
+*/
+func (self *Decls) shared() ([]decl, *sharing) {
+	sharing := newSharing(self.Config)
+
+	counts := map[sharedKey]int{}
+	self.eachShareable(sharing, func(key sharedKey, _ reflect.Value) bool {
+		counts[key]++
+		return true
+	})
+
+	type candidate struct {
+		key sharedKey
+		val interface{}
+	}
+	var candidates []candidate
+	uses := map[sharedKey]int{}
+
+	self.eachShareable(sharing, func(key sharedKey, rval reflect.Value) bool {
+		if counts[key] < 2 {
+			return true
+		}
+		uses[key]++
+		if uses[key] > 1 {
+			return false
+		}
+		candidates = append(candidates, candidate{key, rval.Interface()})
+		return true
+	})
+
+	// Names are reserved in a copy, keeping repeated calls deterministic.
+	names := Decls{names: make(map[string]struct{}, len(self.names))}
+	for name := range self.names {
+		names.reserve(name)
+	}
+
+	var list []decl
+	for _, cand := range candidates {
+		if uses[cand.key] < 2 {
+			continue
+		}
+		name := names.unique(NameByType(cand.val))
+		sharing.names[cand.key] = name
+		list = append(list, decl{name: name, val: cand.val})
+	}
+	return list, sharing
+}
+
+// Visits shareable values inside each declaration, see "Decls.Dedup". The
+// callback may skip the contents of a value by returning false.
+func (self *Decls) eachShareable(sharing *sharing, fun func(sharedKey, reflect.Value) bool) {
+	for _, decl := range self.list {
+		// "Walk" visits pointers along with their targets, at the same path.
+		var ptr bool
+		var ptrPath string
+
+		Walk(decl.val, func(path string, rval reflect.Value) bool {
+			target := ptr && path == ptrPath
+			ptr, ptrPath = rval.Kind() == reflect.Ptr, path
+
+			if path == `` || target || !isShareable(rval.Type()) || !rval.CanInterface() {
+				return true
+			}
+
+			key := sharing.key(rval.Interface())
+			// Contents are always shorter than their container.
+			if len(key.code) < self.Dedup {
+				return false
+			}
+			return fun(key, rval)
+		}, self.Config)
+	}
+}
+
+// Shared variables, see "Decls.Dedup".
+type sharing struct {
+	// Single-line config for comparing values.
+	conf  Config
+	names map[sharedKey]string
+}
+
+type sharedKey struct {
+	rtype reflect.Type
+	code  string
+}
+
+func newSharing(conf Config) *sharing {
+	conf.Indent = ``
+	conf.PathOverrides = nil
+	conf.OverrideFunc = nil
+	conf.Include = nil
+	conf.Exclude = nil
+	return &sharing{conf: conf, names: map[sharedKey]string{}}
+}
+
+func (self *sharing) key(val interface{}) sharedKey {
+	code := appendAny(nil, val, fmter{conf: &self.conf})
+	return sharedKey{reflect.TypeOf(val), string(code)}
+}
+
+// Returns the name of the shared variable equal to the value, if any, of the
+// variable declaring the pointer, see "Decls.PreserveAliasing", or of the
+// constant declaring the string, see "Decls.InternStrings".
+func (self fmter) sharedName(val interface{}) (string, bool) {
+	if self.noShare {
+		return ``, false
+	}
+	if self.state.aliasing != nil {
+		if name, ok := self.state.aliasing.name(val); ok {
+			return name, true
+		}
+	}
+	if self.state.interning != nil {
+		if name, ok := self.internedName(val); ok {
+			return name, true
+		}
+	}
+	if self.state.sharing == nil || !isShareable(reflect.TypeOf(val)) {
+		return ``, false
+	}
+	name, ok := self.state.sharing.names[self.state.sharing.key(val)]
+	return name, ok
+}
+
+// Values of these types are copied on assignment, which makes it safe to
+// declare them once and refer to them in several places.
+func isShareable(rtype reflect.Type) bool {
+	if rtype == nil {
+		return false
+	}
+	kind := rtype.Kind()
+	return kind == reflect.Struct || kind == reflect.Array
+}
+
+// Returns the declarations as Go code. See "Decls.Append".
+func (self *Decls) Bytes() []byte { return self.Append(nil) }
+
+// Returns the declarations as Go code. See "Decls.Append".
+func (self *Decls) String() string { return bytesToMutableString(self.Bytes()) }
+
+// True if the value can be declared as a Go constant.
+func isConstValue(val interface{}) bool {
+	if val == nil {
+		return false
+	}
+	if _, ok := val.(fmt.GoStringer); ok {
+		return false
+	}
+
+	rval := reflect.ValueOf(val)
+	switch rval.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return isLiteralFloat(rval.Float())
+	case reflect.Complex64, reflect.Complex128:
+		return isLiteralComplex(rval.Complex())
+	default:
+		return isPrimitive(rval.Type())
+	}
+}
+
+func isFinite(num float64) bool {
+	return !math.IsNaN(num) && !math.IsInf(num, 0)
+}
+
+func (self *Decls) unique(name string) string {
+	if name == `` {
+		name = `val`
+	}
+	if !self.taken(name) {
+		self.reserve(name)
+		return name
+	}
+
+	for i := 2; ; i++ {
+		out := name + strconv.Itoa(i)
+		if !self.taken(out) {
+			self.reserve(out)
+			return out
+		}
+	}
+}
+
+func (self *Decls) taken(name string) bool {
+	_, ok := self.names[name]
+	return ok
+}
+
+func (self *Decls) reserve(name string) {
+	if self.names == nil {
+		self.names = map[string]struct{}{}
+	}
+	self.names[name] = struct{}{}
+}
+
+/*
+Reserves names of types reachable from the given type which are printed without
+package qualifiers, such as types of package "main" under "Default". Variables
+with such names would shadow the types in the generated code.
+*/
+func (self *Decls) reserveTypeNames(rtype reflect.Type, seen map[reflect.Type]bool) {
+	if rtype == nil || seen[rtype] {
+		return
+	}
+	seen[rtype] = true
+
+	if rtype.Name() != `` && rtype.PkgPath() != `` {
+		if pkg, ok := self.Config.PackageMap[rtype.PkgPath()]; ok && pkg == `` {
+			self.reserve(typeNameBase(rtype))
+		}
+	}
+
+	switch rtype.Kind() {
+	case reflect.Array, reflect.Slice, reflect.Ptr, reflect.Chan:
+		self.reserveTypeNames(rtype.Elem(), seen)
+	case reflect.Map:
+		self.reserveTypeNames(rtype.Key(), seen)
+		self.reserveTypeNames(rtype.Elem(), seen)
+	case reflect.Struct:
+		for i := 0; i < rtype.NumField(); i++ {
+			self.reserveTypeNames(rtype.Field(i).Type, seen)
+		}
+	}
+}
+
+/*
+Default "Namer". Derives a camelCase name from the value's type, for example
+"abiFunction" for "test.AbiFunction", "abiParamList" for "[]test.AbiParam", or
+"urlMap" for "map[string]URL". Type arguments of generic types are ignored.
+Avoids Go keywords, predeclared identifiers such as "new" or "len", "init",
+"main", and the names of unexported types, which the variable would shadow.
+"Decls" also avoids the names of other types printed without qualifiers.
+*/
+func NameByType(val interface{}) string {
+	return typeVarName(reflect.TypeOf(val))
+}
+
+/*
+Returns a "Namer" that generates sequential names with the given prefix, such
+as "v1", "v2", "v3".
+*/
+func NameSequential(prefix string) Namer {
+	var count int
+	return func(interface{}) string {
+		count++
+		return prefix + strconv.Itoa(count)
+	}
+}
+
+func typeVarName(rtype reflect.Type) string {
+	name := typeVarNameBase(rtype)
+	if isReservedIdent(name) {
+		name += `Val`
+	}
+	return name
+}
+
+func typeVarNameBase(rtype reflect.Type) string {
+	if rtype == nil {
+		return `val`
+	}
+
+	switch {
+	case rtype.Name() != `` && rtype.PkgPath() != ``:
+		name := lowerInitial(typeNameBase(rtype))
+		if name == typeNameBase(rtype) {
+			name += `Val`
+		}
+		return name
+	case rtype.Kind() == reflect.Ptr:
+		return typeVarNameBase(rtype.Elem())
+	case rtype.Kind() == reflect.Slice, rtype.Kind() == reflect.Array:
+		return typeVarNameBase(rtype.Elem()) + `List`
+	case rtype.Kind() == reflect.Map:
+		return typeVarNameBase(rtype.Elem()) + `Map`
+	default:
+		return `val`
+	}
+}
+
+// Name of a named type without type arguments: "Pair" for "Pair[int]".
+func typeNameBase(rtype reflect.Type) string { return stripTypeArgs(rtype.Name()) }
+
+func stripTypeArgs(name string) string {
+	if index := strings.IndexByte(name, '['); index >= 0 {
+		return name[:index]
+	}
+	return name
+}
+
+// True for identifiers which generated package-level variables must not use:
+// keywords, predeclared identifiers, and functions with special meaning.
+func isReservedIdent(name string) bool {
+	return token.IsKeyword(name) || types.Universe.Lookup(name) != nil ||
+		name == `init` || name == `main` || name == `_`
+}
+
+/*
+Lowercases the leading uppercase run of an identifier, treating it as an
+initialism: "AbiType" → "abiType", "URLMap" → "urlMap", "ID" → "id".
+*/
+func lowerInitial(name string) string {
+	runes := []rune(name)
+	count := 0
+	for count < len(runes) && unicode.IsUpper(runes[count]) {
+		count++
+	}
+
+	if count > 1 && count < len(runes) {
+		count--
+	}
+	for i := 0; i < count; i++ {
+		runes[i] = unicode.ToLower(runes[i])
+	}
+	return string(runes)
+}
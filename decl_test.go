@@ -0,0 +1,304 @@
+package repr
+
+import (
+	"testing"
+
+	"github.com/mitranim/repr/test"
+)
+
+func TestDeclsNaming(t *testing.T) {
+	var decls Decls
+	decls.Reserve(`abiType`)
+
+	names := []string{
+		decls.Add(test.AbiFunction{Name: "one"}),
+		decls.Add(test.AbiFunction{Name: "two"}),
+		decls.Add(test.AbiType{}),
+		decls.Add([]test.AbiParam{}),
+		decls.Add(map[string]test.Word{}),
+		decls.Add(test.AbiKindInt),
+		decls.Add(123),
+		decls.AddNamed(`val`, 456),
+		decls.AddNamed(`custom`, "str"),
+	}
+
+	expected := []string{
+		`abiFunction`,
+		`abiFunction2`,
+		`abiType2`,
+		`abiParamList`,
+		`wordMap`,
+		`abiKind`,
+		`val`,
+		`val2`,
+		`custom`,
+	}
+
+	for i := range expected {
+		if names[i] != expected[i] {
+			t.Fatalf("expected names:\n%q\nactual names:\n%q", expected, names)
+		}
+	}
+}
+
+func TestDeclsSequential(t *testing.T) {
+	decls := Decls{Namer: NameSequential(`v`)}
+	decls.Reserve(`v2`)
+	decls.Add(1)
+	decls.Add(2)
+	decls.Add(3)
+
+	actual := decls.String()
+	expected := `var (
+	v1 = 1
+
+	v22 = 2
+
+	v3 = 3
+)
+`
+	if actual != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+	}
+}
+
+func TestDeclsConst(t *testing.T) {
+	decls := Decls{Const: true}
+	decls.AddNamed(`name`, "hello")
+	decls.AddNamed(`count`, 123)
+	decls.AddNamed(`kind`, test.AbiKindInt)
+	decls.AddNamed(`ratio`, 0.5)
+	decls.AddNamed(`abiType`, test.AbiType{Type: "bool"})
+	decls.AddNamed(`list`, []int{1})
+
+	actual := decls.String()
+	expected := `const (
+	name = "hello"
+
+	count = 123
+
+	kind = test.AbiKind(3)
+
+	ratio = 0.5
+)
+
+var (
+	abiType = test.AbiType{Type: "bool"}
+
+	list = []int{1}
+)
+`
+	if actual != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+	}
+}
+
+func TestDeclsTypedNumbers(t *testing.T) {
+	decls := Decls{Const: true}
+	decls.AddNamed(`a`, int64(5))
+	decls.AddNamed(`b`, byte(5))
+	decls.AddNamed(`c`, float32(1.5))
+	decls.AddNamed(`d`, float64(2))
+	decls.AddNamed(`e`, uint(7))
+	decls.AddNamed(`f`, 'x')
+	decls.AddNamed(`g`, 7)
+	decls.AddNamed(`h`, 1.5)
+	decls.AddNamed(`list`, []uint{7})
+
+	actual := decls.String()
+	expected := `const (
+	a = int64(5)
+
+	b = uint8(0x05)
+
+	c = float32(1.5)
+
+	d = float64(2)
+
+	e = uint(7)
+
+	f = int32(120)
+
+	g = 7
+
+	h = 1.5
+)
+
+var list = []uint{7}
+`
+	if actual != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+	}
+
+	decls = Decls{}
+	decls.AddNamed(`a`, int64(5))
+	decls.AddNamed(`b`, byte(5))
+
+	actual = decls.String()
+	expected = `var (
+	a = int64(5)
+
+	b = uint8(0x05)
+)
+`
+	if actual != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+	}
+}
+
+func TestDeclsGrouped(t *testing.T) {
+	decls := Decls{Config: Default}
+	decls.Add(test.AbiType{Type: "uint256", Kind: test.AbiKindUint})
+	decls.Add(test.AbiParam{Name: "amount", AbiType: test.AbiType{Type: "uint8"}})
+
+	actual := decls.String()
+	expected := `var (
+	abiType = test.AbiType{
+		Type: "uint256",
+		Kind: 2,
+	}
+
+	abiParam = test.AbiParam{
+		Name: "amount",
+		AbiType: test.AbiType{
+			Type: "uint8",
+		},
+	}
+)
+`
+	if actual != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+	}
+
+	single := Decls{Config: Default}
+	single.Add(test.AbiType{Type: "bool"})
+
+	actual = single.String()
+	expected = `var abiType = test.AbiType{
+	Type: "bool",
+}
+`
+	if actual != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+	}
+}
+
+func TestDeclsDedup(t *testing.T) {
+	uint256 := test.AbiType{Type: "uint256", Kind: test.AbiKindUint}
+	amount := test.AbiParam{Name: "amount", Type: "uint256", AbiType: uint256}
+	list := test.AbiType{Type: "uint256[]", Kind: test.AbiKindSparseArray, Elem: &uint256}
+
+	decls := Decls{Config: Default, Dedup: 30}
+	decls.Add(test.AbiFunction{Name: "one", Inputs: []test.AbiParam{amount}})
+	decls.Add(test.AbiFunction{Name: "two", Inputs: []test.AbiParam{amount}, Outputs: []test.AbiParam{{AbiType: list}}})
+	decls.Add(test.AbiParam{AbiType: uint256})
+
+	actual := decls.String()
+	expected := `var (
+	abiFunction = test.AbiFunction{
+		Name: "one",
+		Inputs: []test.AbiParam{
+			abiParam2,
+		},
+	}
+
+	abiFunction2 = test.AbiFunction{
+		Name: "two",
+		Inputs: []test.AbiParam{
+			abiParam2,
+		},
+		Outputs: []test.AbiParam{
+			{
+				AbiType: test.AbiType{
+					Type: "uint256[]",
+					Kind: 7,
+					Elem: &test.AbiType{
+						Type: "uint256",
+						Kind: 2,
+					},
+				},
+			},
+		},
+	}
+
+	abiParam = test.AbiParam{
+		AbiType: abiType,
+	}
+
+	abiParam2 = test.AbiParam{
+		Name: "amount",
+		Type: "uint256",
+		AbiType: abiType,
+	}
+
+	abiType = test.AbiType{
+		Type: "uint256",
+		Kind: 2,
+	}
+)
+`
+	if actual != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+	}
+
+	if again := decls.String(); again != actual {
+		t.Fatalf("expected repeated output to be identical, got:\n%v", again)
+	}
+}
+
+type point struct{ X int }
+
+type New struct{ X int }
+
+func TestDeclsNamingCollisions(t *testing.T) {
+	decls := Decls{Config: CompactConfig}
+	decls.Config.PackageMap = map[string]string{`github.com/mitranim/repr`: ``}
+
+	names := []string{
+		decls.Add(point{1}),
+		decls.Add(New{2}),
+		decls.Add([]New{{3}}),
+		decls.AddNamed(`point`, 4),
+		decls.AddNamed(`len`, 5),
+	}
+	expected := []string{`pointVal`, `newVal`, `newList`, `point2`, `len`}
+
+	for i := range expected {
+		if names[i] != expected[i] {
+			t.Fatalf("expected names:\n%q\nactual names:\n%q", expected, names)
+		}
+	}
+
+	// Unexported types of the rendered package are reserved, whatever namer is used.
+	decls = Decls{Config: CompactConfig, Namer: func(interface{}) string { return `point` }}
+	decls.Config.PackageMap = map[string]string{`github.com/mitranim/repr`: ``}
+	if name := decls.Add(point{}); name != `point2` {
+		t.Fatalf(`unexpected name %q`, name)
+	}
+
+	for _, name := range []string{`new`, `len`, `nil`, `true`, `make`, `append`, `init`, `main`, `int`} {
+		if !isReservedIdent(name) {
+			t.Fatalf(`expected %q to be reserved`, name)
+		}
+	}
+	if stripTypeArgs(`Pair[int]`) != `Pair` || stripTypeArgs(`Pair[map[string]int]`) != `Pair` {
+		t.Fatalf(`expected type arguments to be stripped`)
+	}
+}
+
+func TestLowerInitial(t *testing.T) {
+	cases := [][2]string{
+		{`AbiType`, `abiType`},
+		{`URLMap`, `urlMap`},
+		{`ID`, `id`},
+		{`x`, `x`},
+		{``, ``},
+	}
+
+	for _, pair := range cases {
+		actual := lowerInitial(pair[0])
+		if actual != pair[1] {
+			t.Fatalf("expected %q for %q, got %q", pair[1], pair[0], actual)
+		}
+	}
+}
@@ -0,0 +1,416 @@
+package repr
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+Complete Go source file with package clause, imports and declarations. Useful
+for generating fixtures such as benchmark corpora:
+
+	file := repr.File{Package: "fixtures", CompressBytes: 1 << 16}
+	file.Decls.Config = repr.Default
+	file.Decls.AddNamed("benchAbi", someAbi)
+	file.Decls.AddNamed("benchBlob", someBytes)
+	code, err := file.Bytes()
+
+Imports are derived from the types referenced by the output. The result is
+formatted via "go/format".
+*/
+type File struct {
+	/**
+	Package name. Required.
+	*/
+	Package string
+
+	/**
+	Declarations in this file. See "Decls".
+	*/
+	Decls Decls
+
+	/**
+	If positive, top-level byte slices longer than this are stored as gzipped
+	base64 strings, decoded at init time by a generated helper function. This
+	keeps large binary fixtures reasonably sized.
+	*/
+	CompressBytes int
+
+	/**
+	If true, non-nil funcs are printed as references to generated stub
+	functions, declared at the end of the file with the same signatures and
+	bodies which panic, instead of nil:
+
+		var routes = []Route{
+			{Path: "/users", Handler: stubHandler},
+		}
+
+		// Stub for func(http.ResponseWriter, *http.Request) closure defined at main.go:12.
+		func stubHandler(http.ResponseWriter, *http.Request) {
+			panic("stub")
+		}
+
+	This keeps tables of handlers and callbacks compilable, to be filled in by
+	hand. Stubs are named after the enclosing struct field. When
+	"Config.FuncNames" is set, funcs which can be referenced by name are
+	printed as usual.
+	*/
+	FuncStubs bool
+
+	/**
+	Header comment marking the file as generated. See "Header".
+	*/
+	Header Header
+}
+
+/*
+Header comment of a generated file, following the convention recognized by Go
+tools and code review systems:
+
+	// Code generated by repr. DO NOT EDIT.
+	// Command: repr -json=fixtures.json -var=fixtures
+	// Time: 2024-01-02T03:04:05Z
+
+Data sources of individual declarations are recorded by "Decls.AddFrom".
+*/
+type Header struct {
+	/**
+	Name of the generator, such as the command or package. The header is
+	omitted if empty.
+	*/
+	Generator string
+
+	/**
+	Command line which generated the file, such as "os.Args". Arguments with
+	spaces or quotes are quoted. Omitted if empty.
+	*/
+	Command []string
+
+	/**
+	Generation time, printed in UTC. Omitted if zero, which is the default, as
+	timestamps make regenerated files differ even when the data is the same.
+	*/
+	Time time.Time
+}
+
+func (self Header) append(out []byte) []byte {
+	if self.Generator == `` {
+		return out
+	}
+
+	out = append(out, `// Code generated by `...)
+	out = append(out, self.Generator...)
+	out = append(out, `. DO NOT EDIT.`...)
+	out = append(out, '\n')
+
+	if len(self.Command) > 0 {
+		out = append(out, `// Command:`...)
+		for _, arg := range self.Command {
+			out = append(out, ' ')
+			if arg == `` || strings.ContainsAny(arg, " \t\n\"'`") {
+				out = strconv.AppendQuote(out, arg)
+			} else {
+				out = append(out, arg...)
+			}
+		}
+		out = append(out, '\n')
+	}
+
+	if !self.Time.IsZero() {
+		out = append(out, `// Time: `...)
+		out = self.Time.UTC().AppendFormat(out, time.RFC3339)
+		out = append(out, '\n')
+	}
+	return append(out, '\n')
+}
+
+/*
+Generates the file, formatted via "go/format". Line endings follow
+"Config.Newline" of the declarations. Returns an error if the package name is
+missing or the generated code is invalid.
+*/
+func (self *File) Bytes() ([]byte, error) {
+	if self.Package == `` {
+		return nil, fmt.Errorf(`repr: File requires a package name`)
+	}
+
+	decls := self.Decls
+	decls.list = make([]decl, len(self.Decls.list))
+	decls.names = make(map[string]struct{}, len(self.Decls.names))
+	for name := range self.Decls.names {
+		decls.names[name] = struct{}{}
+	}
+
+	var gunzipName string
+	for i, decl := range self.Decls.list {
+		val, ok := decl.val.([]byte)
+		if ok && self.CompressBytes > 0 && len(val) > self.CompressBytes {
+			if gunzipName == `` {
+				gunzipName = decls.unique(`mustGunzip`)
+			}
+			decl.val = gunzipExpr{gunzipName, gzipBase64(val)}
+		}
+		decls.list[i] = decl
+	}
+
+	state := &state{imports: map[string]string{}}
+	if self.FuncStubs {
+		state.stubs = &funcStubs{decls: &decls, names: map[funcStubKey]string{}}
+		state.paths = true
+	}
+	body, err := decls.append(nil, state)
+	if err != nil {
+		return nil, err
+	}
+
+	if gunzipName != `` {
+		state.imports[`compress/gzip`] = `gzip`
+		state.imports[`encoding/base64`] = `base64`
+		state.imports[`io/ioutil`] = `ioutil`
+		state.imports[`strings`] = `strings`
+	}
+
+	var out []byte
+	out = self.Header.append(out)
+	out = append(out, `package `...)
+	out = append(out, self.Package...)
+	out = append(out, '\n', '\n')
+	out = appendImports(out, state.imports)
+	out = append(out, body...)
+
+	if state.stubs != nil {
+		out = append(out, state.stubs.code...)
+	}
+
+	if gunzipName != `` {
+		out = append(out, '\n')
+		out = append(out, fmt.Sprintf(gunzipFunc, gunzipName)...)
+	}
+
+	out, err = format.Source(out)
+	if err != nil {
+		return nil, err
+	}
+
+	newline := self.Decls.Config.Newline
+	if newline != `` && newline != "\n" {
+		out = bytes.Replace(out, []byte("\n"), []byte(newline), -1)
+	}
+	return out, nil
+}
+
+func appendImports(out []byte, imports map[string]string) []byte {
+	if len(imports) == 0 {
+		return out
+	}
+
+	paths := make([]string, 0, len(imports))
+	for path := range imports {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	out = append(out, `import (`...)
+	out = append(out, '\n')
+	for _, val := range paths {
+		out = append(out, '\t')
+		if name := imports[val]; name != path.Base(val) {
+			out = append(out, name...)
+			out = append(out, ' ')
+		}
+		out = strconv.AppendQuote(out, val)
+		out = append(out, '\n')
+	}
+	out = append(out, ')', '\n', '\n')
+	return out
+}
+
+/*
+Replaces the content between marker comments in Go source, preserving the rest
+of the source. Markers are line comments, keyed by region name:
+
+	// repr:begin fixtures
+	var someVar = ...
+	// repr:end fixtures
+
+Each region in the map must have exactly one pair of markers in the source.
+Markers of regions not in the map are left untouched. The new content is
+indented to match the begin marker. Typical usage:
+
+	var decls repr.Decls
+	decls.AddNamed("someVar", someValue)
+	src, err = repr.Inject(src, map[string][]byte{"fixtures": decls.Bytes()})
+*/
+func Inject(src []byte, regions map[string][]byte) ([]byte, error) {
+	lines := bytes.SplitAfter(src, []byte("\n"))
+	found := make(map[string]bool, len(regions))
+	out := make([]byte, 0, len(src))
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		out = append(out, line...)
+
+		name, ok := markerName(line, markerBegin)
+		if !ok {
+			continue
+		}
+		content, ok := regions[name]
+		if !ok {
+			continue
+		}
+		if found[name] {
+			return nil, fmt.Errorf(`repr: duplicate marker %q`, markerBegin+name)
+		}
+		found[name] = true
+
+		end := i + 1
+		for end < len(lines) {
+			endName, ok := markerName(lines[end], markerEnd)
+			if ok && endName == name {
+				break
+			}
+			end++
+		}
+		if end == len(lines) {
+			return nil, fmt.Errorf(`repr: missing marker %q`, markerEnd+name)
+		}
+
+		if len(line) > 0 && line[len(line)-1] != '\n' {
+			out = append(out, '\n')
+		}
+		out = appendIndented(out, content, leadingSpace(line))
+		i = end - 1
+	}
+
+	for name := range regions {
+		if !found[name] {
+			return nil, fmt.Errorf(`repr: missing marker %q`, markerBegin+name)
+		}
+	}
+	return out, nil
+}
+
+/*
+Same as "Inject", but reads and writes the file at the given path. The file is
+replaced atomically, so a failed write leaves the original intact.
+*/
+func InjectFile(path string, regions map[string][]byte) error {
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	out, err := Inject(src, regions)
+	if err != nil {
+		return fmt.Errorf(`%v: %v`, path, err)
+	}
+	return writeFileAtomic(path, out)
+}
+
+const (
+	markerBegin = `// repr:begin `
+	markerEnd   = `// repr:end `
+)
+
+func markerName(line []byte, prefix string) (string, bool) {
+	line = bytes.TrimSpace(line)
+	if !bytes.HasPrefix(line, []byte(prefix)) {
+		return ``, false
+	}
+	name := bytes.TrimSpace(line[len(prefix):])
+	return string(name), len(name) > 0
+}
+
+func leadingSpace(line []byte) []byte {
+	return line[:len(line)-len(bytes.TrimLeft(line, " \t"))]
+}
+
+// Prefixes every non-empty line with the given indent, ensuring a trailing
+// newline.
+func appendIndented(out, content, indent []byte) []byte {
+	for _, line := range bytes.SplitAfter(content, []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		if len(bytes.TrimSpace(line)) > 0 {
+			out = append(out, indent...)
+		}
+		out = append(out, line...)
+	}
+	if len(out) > 0 && out[len(out)-1] != '\n' {
+		out = append(out, '\n')
+	}
+	return out
+}
+
+// Writes to a temporary file in the same directory, then renames it over the
+// target, preserving the permissions of an existing target.
+func writeFileAtomic(path string, content []byte) error {
+	mode := os.FileMode(0666)
+	info, err := os.Stat(path)
+	if err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	file, err := ioutil.TempFile(filepath.Dir(path), `.`+filepath.Base(path)+`.*`)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	_, err = file.Write(content)
+	if err == nil {
+		err = file.Chmod(mode)
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
+
+func gzipBase64(src []byte) string {
+	var buf bytes.Buffer
+	encoder := base64.NewEncoder(base64.StdEncoding, &buf)
+	writer := gzip.NewWriter(encoder)
+	_, _ = writer.Write(src)
+	_ = writer.Close()
+	_ = encoder.Close()
+	return buf.String()
+}
+
+// Prints as a call to the generated gunzip helper.
+type gunzipExpr struct {
+	name string
+	data string
+}
+
+func (self gunzipExpr) GoString() string {
+	return self.name + `(` + strconv.Quote(self.data) + `)`
+}
+
+const gunzipFunc = `func %v(src string) []byte {
+	reader, err := gzip.NewReader(base64.NewDecoder(base64.StdEncoding, strings.NewReader(src)))
+	if err != nil {
+		panic(err)
+	}
+	out, err := ioutil.ReadAll(reader)
+	if err != nil {
+		panic(err)
+	}
+	return out
+}
+`
@@ -0,0 +1,295 @@
+package repr
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mitranim/repr/test"
+)
+
+func TestFile(t *testing.T) {
+	file := File{Package: `fixtures`}
+	file.Decls.Config = Default
+	file.Decls.Config.PackageMap = map[string]string{`github.com/mitranim/repr/test`: `abi`}
+	file.Decls.AddNamed(`benchType`, test.AbiType{Type: "bool", Kind: test.AbiKindBool})
+	file.Decls.AddNamed(`benchCount`, 123)
+
+	actual, err := file.Bytes()
+	if err != nil {
+		t.Fatalf("failed to generate file: %v", err)
+	}
+
+	expected := `package fixtures
+
+import (
+	abi "github.com/mitranim/repr/test"
+)
+
+var (
+	benchType = abi.AbiType{
+		Type: "bool",
+		Kind: 1,
+	}
+
+	benchCount = 123
+)
+`
+	if string(actual) != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%s", expected, actual)
+	}
+}
+
+func TestFileHeader(t *testing.T) {
+	file := File{
+		Package: `fixtures`,
+		Header: Header{
+			Generator: `repr`,
+			Command:   []string{`repr`, `-json=abi.json`, `-var`, `two words`},
+			Time:      time.Date(2024, 1, 2, 3, 4, 5, 0, time.FixedZone(``, 3600)),
+		},
+	}
+	file.Decls.Config = Default
+	file.Decls.AddFrom(`one`, "testdata/one.json", 1)
+	file.Decls.AddNamed(`two`, 2)
+	file.Decls.AddFrom(`three`, "https://example.com/\nthree", 3)
+
+	actual, err := file.Bytes()
+	if err != nil {
+		t.Fatalf("failed to generate file: %v", err)
+	}
+
+	expected := `// Code generated by repr. DO NOT EDIT.
+// Command: repr -json=abi.json -var "two words"
+// Time: 2024-01-02T02:04:05Z
+
+package fixtures
+
+var (
+	// Source: testdata/one.json
+	one = 1
+
+	two = 2
+
+	// Source: https://example.com/ three
+	three = 3
+)
+`
+	if string(actual) != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%s", expected, actual)
+	}
+
+	file = File{Package: `fixtures`, Header: Header{Generator: `repr`}}
+	file.Decls.AddFrom(`one`, `one.json`, 1)
+
+	actual, err = file.Bytes()
+	if err != nil {
+		t.Fatalf("failed to generate file: %v", err)
+	}
+
+	expected = `// Code generated by repr. DO NOT EDIT.
+
+package fixtures
+
+// Source: one.json
+var one = 1
+`
+	if string(actual) != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%s", expected, actual)
+	}
+}
+
+func TestFileCompressBytes(t *testing.T) {
+	file := File{Package: `fixtures`, CompressBytes: 16}
+	file.Decls.Config = Default
+	file.Decls.AddNamed(`small`, []byte{1, 2, 3})
+	file.Decls.AddNamed(`large`, testBytes)
+
+	code, err := file.Bytes()
+	if err != nil {
+		t.Fatalf("failed to generate file: %v", err)
+	}
+
+	if !bytes.Contains(code, []byte(`small = []uint8{0x01, 0x02, 0x03}`)) {
+		t.Fatalf("expected small byte slice to remain inline:\n%s", code)
+	}
+	if !bytes.Contains(code, []byte(`large = mustGunzip("`)) {
+		t.Fatalf("expected large byte slice to be compressed:\n%s", code)
+	}
+	if !bytes.Contains(code, []byte(`"compress/gzip"`)) {
+		t.Fatalf("expected gzip import:\n%s", code)
+	}
+
+	reader, err := gzip.NewReader(base64.NewDecoder(base64.StdEncoding, strings.NewReader(gzipBase64(testBytes))))
+	if err != nil {
+		t.Fatalf("failed to decode: %v", err)
+	}
+	decoded, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("failed to decode: %v", err)
+	}
+	if !bytes.Equal(decoded, testBytes) {
+		t.Fatalf("compressed bytes don't round-trip")
+	}
+}
+
+func TestInject(t *testing.T) {
+	src := `package fixtures
+
+func init() {
+	// repr:begin kinds
+	old := 1
+	// repr:end kinds
+}
+
+// repr:begin types
+var stale = 1
+// repr:end types
+
+// repr:begin other
+var untouched = 1
+// repr:end other
+`
+
+	var decls Decls
+	decls.AddNamed(`abiType`, test.AbiType{Type: "bool"})
+
+	actual, err := Inject([]byte(src), map[string][]byte{
+		`kinds`: []byte("kinds := []int{1, 2}\n\nkinds = nil"),
+		`types`: decls.Bytes(),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := `package fixtures
+
+func init() {
+	// repr:begin kinds
+	kinds := []int{1, 2}
+
+	kinds = nil
+	// repr:end kinds
+}
+
+// repr:begin types
+var abiType = test.AbiType{Type: "bool"}
+// repr:end types
+
+// repr:begin other
+var untouched = 1
+// repr:end other
+`
+	if string(actual) != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%s", expected, actual)
+	}
+
+	_, err = Inject([]byte(src), map[string][]byte{`missing`: nil})
+	if err == nil {
+		t.Fatalf("expected an error for a missing marker")
+	}
+
+	_, err = Inject([]byte("// repr:begin open\n"), map[string][]byte{`open`: nil})
+	if err == nil {
+		t.Fatalf("expected an error for a missing end marker")
+	}
+}
+
+func TestInjectFile(t *testing.T) {
+	file, err := ioutil.TempFile(``, `repr*.go`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+
+	_, err = file.WriteString("// repr:begin one\n// repr:end one\n")
+	_ = file.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = InjectFile(file.Name(), map[string][]byte{`one`: Bytes([]int{1})})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	actual, err := ioutil.ReadFile(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(actual) != "// repr:begin one\n[]int{1}\n// repr:end one\n" {
+		t.Fatalf("unexpected file content:\n%s", actual)
+	}
+}
+
+func TestFileWithoutPackage(t *testing.T) {
+	var file File
+	_, err := file.Bytes()
+	if err == nil {
+		t.Fatalf("expected an error for a missing package name")
+	}
+}
+
+func TestFileLangVersion(t *testing.T) {
+	val := 10
+	file := File{Package: `fixtures`}
+	file.Decls.Config = Default
+	file.Decls.Config.PtrFunc = `ptr.To`
+	file.Decls.AddNamed(`one`, &val)
+
+	_, err := file.Bytes()
+	if err != nil {
+		t.Fatalf("unexpected error without a language version: %v", err)
+	}
+
+	file.Decls.Config.LangVersion = `go1.17`
+	_, err = file.Bytes()
+	if err == nil {
+		t.Fatalf("expected an error for a generic helper before go1.18")
+	}
+
+	file.Decls.Config.LangVersion = `go1.x`
+	_, err = file.Bytes()
+	if err == nil {
+		t.Fatalf("expected an error for an invalid language version")
+	}
+}
+
+func TestFileOutputVersion(t *testing.T) {
+	file := File{Package: `fixtures`}
+	file.Decls.Config = Default
+	file.Decls.AddNamed(`one`, 10)
+
+	for _, ver := range []int{-1, LatestOutputVersion + 1} {
+		file.Decls.Config.OutputVersion = ver
+		_, err := file.Bytes()
+		if err == nil {
+			t.Fatalf("expected an error for output version %v", ver)
+		}
+	}
+}
+
+func TestFileValidate(t *testing.T) {
+	file := File{Package: `fixtures`}
+	file.Decls.Config = Default
+	file.Decls.Config.Validate = true
+	file.Decls.AddNamed(`one`, 10)
+	file.Decls.AddNamed(`two`, invalidGoStringer{})
+
+	_, err := file.Bytes()
+	if err == nil || !strings.Contains(err.Error(), `not a valid Go expression`) {
+		t.Fatalf("expected a validation error, got %v", err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected a panic from Decls.Bytes")
+		}
+	}()
+	file.Decls.Bytes()
+}
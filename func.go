@@ -0,0 +1,205 @@
+package repr
+
+import (
+	"go/token"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+/*
+Prints a non-nil func according to "Config.FuncNames". Functions are identified
+by their symbol names in the runtime, which look like this:
+
+	github.com/mitranim/repr.appendFunc        // top-level function
+	github.com/mitranim/repr.Config.SingleLine // method expression
+	github.com/mitranim/repr.Lazy.String-fm    // method value
+	github.com/mitranim/repr.TestFunc.func1    // closure
+	github.com/mitranim/repr.glob..func1       // closure in a package variable
+
+Dots in the last element of the package path are escaped as "%2e".
+*/
+func appendFunc(out []byte, rval reflect.Value, fmter fmter) []byte {
+	rtype := rval.Type()
+
+	fun := runtime.FuncForPC(rval.Pointer())
+	if fun == nil {
+		if fmter.stubsFuncs() {
+			return appendFuncStub(out, rval, fmter)
+		}
+		fmter.warn(rtype, `non-nil value is printed as nil`)
+		return appendFuncNil(out, rtype, fmter)
+	}
+
+	path, sym := splitFuncName(fun.Name())
+	pkg := guessPackageName(path)
+
+	// Functions have unnamed types, which only require conversions to named
+	// types.
+	if path != `` && isTopLevelFunc(sym) && token.IsExported(sym) {
+		if rtype.Name() == `` {
+			return appendQualifiedPath(out, path, pkg, sym, fmter)
+		}
+		out = appendCastPrefix(out, rval, fmter)
+		out = appendQualifiedPath(out, path, pkg, sym, fmter)
+		return appendCastSuffix(out, rval, fmter)
+	}
+
+	if fmter.stubsFuncs() {
+		return appendFuncStub(out, rval, fmter)
+	}
+
+	fmter.warn(rtype, `non-nil value is printed as nil`)
+	out = appendFuncNil(out, rtype, fmter)
+	out = append(out, ` /* `...)
+	out = appendCommentText(out, describeFunc(rval))
+	return append(out, ` */`...)
+}
+
+/*
+Describes a func which can't be referenced by name, for comments:
+
+	func(int) error closure defined at handlers.go:123
+	method value (*pkg.Server).Handle
+	pkg.unexported
+*/
+func describeFunc(rval reflect.Value) string {
+	fun := runtime.FuncForPC(rval.Pointer())
+	if fun == nil {
+		return rval.Type().String()
+	}
+
+	path, sym := splitFuncName(fun.Name())
+	pkg := guessPackageName(path)
+
+	switch {
+	case strings.HasSuffix(sym, `-fm`):
+		return `method value ` + qualifyFuncSym(pkg, strings.TrimSuffix(sym, `-fm`))
+
+	case isClosureSym(sym):
+		file, line := fun.FileLine(fun.Entry())
+		return rval.Type().String() + ` closure defined at ` + filepath.Base(file) + `:` + strconv.Itoa(line)
+
+	default:
+		return qualifyFuncSym(pkg, sym)
+	}
+}
+
+// Prints the parameters and results of the func type, such as
+// "(string, ...int) (int, error)", qualifying type names like other types.
+func appendFuncSignature(out []byte, rtype reflect.Type, fmter fmter) []byte {
+	out = append(out, '(')
+	for i := 0; i < rtype.NumIn(); i++ {
+		if i > 0 {
+			out = append(out, ',', ' ')
+		}
+		param := rtype.In(i)
+		if rtype.IsVariadic() && i == rtype.NumIn()-1 {
+			out = append(out, `...`...)
+			param = param.Elem()
+		}
+		out = appendTypeName(out, param, fmter)
+	}
+	out = append(out, ')')
+
+	switch rtype.NumOut() {
+	case 0:
+		return out
+	case 1:
+		out = append(out, ' ')
+		return appendTypeName(out, rtype.Out(0), fmter)
+	}
+
+	out = append(out, ' ', '(')
+	for i := 0; i < rtype.NumOut(); i++ {
+		if i > 0 {
+			out = append(out, ',', ' ')
+		}
+		out = appendTypeName(out, rtype.Out(i), fmter)
+	}
+	return append(out, ')')
+}
+
+// Unnamed func types are parenthesized, see "appendTypedNil".
+func appendFuncNil(out []byte, rtype reflect.Type, fmter fmter) []byte {
+	if fmter.elideType {
+		return append(out, `nil`...)
+	}
+	return appendTypedNil(out, rtype, fmter)
+}
+
+// Splits a runtime symbol name into the package path and the symbol within
+// the package. Type arguments of generic functions may contain slashes, and
+// are ignored when looking for the package path.
+func splitFuncName(name string) (string, string) {
+	prefix := name
+	if index := strings.IndexByte(prefix, '['); index >= 0 {
+		prefix = prefix[:index]
+	}
+
+	start := strings.LastIndexByte(prefix, '/') + 1
+	dot := strings.IndexByte(prefix[start:], '.')
+	if dot < 0 {
+		return ``, name
+	}
+	dot += start
+	return strings.Replace(name[:dot], `%2e`, `.`, -1), name[dot+1:]
+}
+
+// Guesses the package name from its path: the last element, ignoring major
+// version suffixes such as "/v2" and extensions such as ".v3".
+func guessPackageName(path string) string {
+	elems := strings.Split(path, `/`)
+	name := elems[len(elems)-1]
+	if len(elems) > 1 && isMajorVersion(name) {
+		name = elems[len(elems)-2]
+	}
+	if index := strings.IndexByte(name, '.'); index > 0 {
+		name = name[:index]
+	}
+	return strings.Replace(name, `-`, `_`, -1)
+}
+
+func isMajorVersion(str string) bool {
+	if len(str) < 2 || str[0] != 'v' {
+		return false
+	}
+	_, err := strconv.Atoi(str[1:])
+	return err == nil
+}
+
+func isTopLevelFunc(sym string) bool {
+	return sym != `` && !strings.ContainsAny(sym, `.[(-`)
+}
+
+// Closures are named after the enclosing function, followed by "funcN".
+func isClosureSym(sym string) bool {
+	for _, elem := range strings.Split(sym, `.`) {
+		if strings.HasPrefix(elem, `func`) && isDigits(elem[len(`func`):]) {
+			return true
+		}
+	}
+	return false
+}
+
+func isDigits(str string) bool {
+	for _, char := range str {
+		if char < '0' || char > '9' {
+			return false
+		}
+	}
+	return str != ``
+}
+
+// Qualifies method symbols such as "(*T).Method" as "(*pkg.T).Method".
+func qualifyFuncSym(pkg, sym string) string {
+	if pkg == `` {
+		return sym
+	}
+	if strings.HasPrefix(sym, `(*`) {
+		return `(*` + pkg + `.` + sym[len(`(*`):]
+	}
+	return pkg + `.` + sym
+}
@@ -0,0 +1,73 @@
+package repr
+
+import (
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestFuncNames(t *testing.T) {
+	conf := CompactConfig
+	conf.FuncNames = true
+
+	test := func(expected string, val interface{}) {
+		t.Helper()
+		actual := StringC(val, conf)
+		if actual != expected {
+			t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+		}
+	}
+
+	_, _, line, _ := runtime.Caller(0)
+	closure := func(int) error { return nil }
+
+	type Handler func(string) string
+	type Data struct {
+		Exported   func(string) string
+		Named      Handler
+		Unexported func(float64) bool
+		Method     func() string
+		Closure    func(int) error
+	}
+
+	test(
+		`repr.Data{Exported: strings.ToUpper, Named: strings.ToLower, Unexported: nil /* repr.isFinite */, Method: nil /* method value repr.Lazy.String */, Closure: nil /* func(int) error closure defined at func_test.go:`+strconv.Itoa(line+1)+` */}`,
+		Data{
+			Exported:   strings.ToUpper,
+			Named:      strings.ToLower,
+			Unexported: isFinite,
+			Method:     Lazy{}.String,
+			Closure:    closure,
+		},
+	)
+
+	test(`[]interface {}{strings.ToUpper, repr.Handler(strings.ToLower), (func(int) error)(nil) /* func(int) error closure defined at func_test.go:`+strconv.Itoa(line+1)+` */}`,
+		[]interface{}{strings.ToUpper, Handler(strings.ToLower), closure})
+
+	conf.FuncNames = false
+	test(`[]interface {}{(func(string) string)(nil)}`, []interface{}{strings.ToUpper})
+}
+
+func TestSplitFuncName(t *testing.T) {
+	test := func(name, path, sym string) {
+		t.Helper()
+		actualPath, actualSym := splitFuncName(name)
+		if actualPath != path || actualSym != sym {
+			t.Fatalf(`expected %q %q, got %q %q`, path, sym, actualPath, actualSym)
+		}
+	}
+
+	test(`main.main`, `main`, `main`)
+	test(`strings.ToUpper`, `strings`, `ToUpper`)
+	test(`github.com/mitranim/repr.(*Lazy).String-fm`, `github.com/mitranim/repr`, `(*Lazy).String-fm`)
+	test(`gopkg.in/yaml%2ev3.Marshal`, `gopkg.in/yaml.v3`, `Marshal`)
+	test(`example.com/pkg.Map[go.shape.*example.com/other.T]`, `example.com/pkg`, `Map[go.shape.*example.com/other.T]`)
+
+	if name := guessPackageName(`gopkg.in/yaml.v3`); name != `yaml` {
+		t.Fatalf(`unexpected package name: %v`, name)
+	}
+	if name := guessPackageName(`example.com/go-thing/v2`); name != `go_thing` {
+		t.Fatalf(`unexpected package name: %v`, name)
+	}
+}
@@ -0,0 +1,411 @@
+package repr
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"unicode"
+)
+
+/*
+Single case for "TestTable". "In" and "Want" are arbitrary values, typically
+captured from a running program.
+*/
+type TestCase struct {
+	Name string
+	In   interface{}
+	Want interface{}
+}
+
+/*
+Formats the cases as a table for a table-driven test, using the provided config:
+
+	[]struct {
+		name string
+		in   SomeInput
+		want SomeOutput
+	}{
+		{
+			name: "some case",
+			in: SomeInput{...},
+			want: SomeOutput{...},
+		},
+	}
+
+The types of "in" and "want" are taken from the cases. If they differ between
+cases, the corresponding field is declared as "interface{}".
+*/
+func TestTable(cases []TestCase, conf Config) []byte {
+	inType := commonType(cases, func(val TestCase) interface{} { return val.In })
+	wantType := commonType(cases, func(val TestCase) interface{} { return val.Want })
+	fmter := fmter{conf: &conf}
+	fmter.ownConf()
+	fmter.conf.LinePrefix = ``
+
+	var out []byte
+
+	if conf.SingleLine() {
+		out = append(out, `[]struct{name string; in `...)
+		out = appendTypeExpr(out, inType, fmter)
+		out = append(out, `; want `...)
+		out = appendTypeExpr(out, wantType, fmter)
+		out = append(out, `}{`...)
+
+		for i, val := range cases {
+			if i > 0 {
+				out = append(out, ',', ' ')
+			}
+			out = appendBraceOpen(out, true, fmter)
+			out = append(out, `name`...)
+			out = appendColon(out, fmter)
+			out = strconv.AppendQuote(out, val.Name)
+			out = append(out, `, in`...)
+			out = appendColon(out, fmter)
+			out = appendTestTableField(out, val.In, inType, fmter)
+			out = append(out, `, want`...)
+			out = appendColon(out, fmter)
+			out = appendTestTableField(out, val.Want, wantType, fmter)
+			out = appendBraceClose(out, true, fmter)
+		}
+
+		out = append(out, '}')
+		return out
+	}
+
+	out = append(out, `[]struct {`...)
+	out = appendNewline(out, fmter)
+	out = append(out, conf.Indent...)
+	out = append(out, `name string`...)
+	out = appendNewline(out, fmter)
+	out = append(out, conf.Indent...)
+	out = append(out, `in   `...)
+	out = appendTypeExpr(out, inType, fmter)
+	out = appendNewline(out, fmter)
+	out = append(out, conf.Indent...)
+	out = append(out, `want `...)
+	out = appendTypeExpr(out, wantType, fmter)
+	out = appendNewline(out, fmter)
+	out = append(out, `}{`...)
+
+	if len(cases) > 0 {
+		out = appendNewline(out, fmter)
+	}
+
+	fmter.indent = 2
+	for _, val := range cases {
+		out = append(out, conf.Indent...)
+		out = append(out, '{')
+		out = appendNewline(out, fmter)
+
+		out = appendIndent(out, fmter)
+		out = append(out, `name`...)
+		out = appendColon(out, fmter)
+		out = strconv.AppendQuote(out, val.Name)
+		out = append(out, ',')
+		out = appendNewline(out, fmter)
+
+		out = appendIndent(out, fmter)
+		out = append(out, `in`...)
+		out = appendColon(out, fmter)
+		out = appendTestTableField(out, val.In, inType, fmter)
+		out = append(out, ',')
+		out = appendNewline(out, fmter)
+
+		out = appendIndent(out, fmter)
+		out = append(out, `want`...)
+		out = appendColon(out, fmter)
+		out = appendTestTableField(out, val.Want, wantType, fmter)
+		out = append(out, ',')
+		out = appendNewline(out, fmter)
+
+		out = append(out, conf.Indent...)
+		out = append(out, '}', ',')
+		out = appendNewline(out, fmter)
+	}
+
+	out = append(out, '}')
+	return out
+}
+
+// Mirrors the type elision rules for struct fields, see "appendStruct".
+func appendTestTableField(out []byte, val interface{}, rtype reflect.Type, fmter fmter) []byte {
+	rval := reflect.ValueOf(val)
+	fmter.elideType = rval.IsValid() && !isInterface(rtype) && (isPrimitive(rtype) || isNil(rval))
+	return appendAny(out, val, fmter)
+}
+
+func appendTypeExpr(out []byte, rtype reflect.Type, fmter fmter) []byte {
+	if rtype == nil || rtype == interfaceType {
+		if fmter.conf.langAtLeast(18) {
+			return append(out, `any`...)
+		}
+		return append(out, `interface{}`...)
+	}
+	return appendTypeName(out, rtype, fmter)
+}
+
+// Returns the type shared by all values, or "interface{}" if they differ.
+func commonType(cases []TestCase, get func(TestCase) interface{}) reflect.Type {
+	var out reflect.Type
+	for i, val := range cases {
+		rtype := reflect.TypeOf(get(val))
+		if i == 0 {
+			out = rtype
+		} else if rtype != out {
+			return interfaceType
+		}
+	}
+	if out == nil {
+		return interfaceType
+	}
+	return out
+}
+
+/*
+Formats a regression test for a counterexample of a property, such as a failure
+reported by "testing/quick", using the provided config for the arguments:
+
+	func TestSomeRegression(t *testing.T) {
+		if !someProperty(
+			SomeInput{...},
+			10,
+		) {
+			t.Fatal("property failed for counterexample")
+		}
+	}
+
+"name" is the name of the test function, and "property" is the expression for
+the property function, which must return "bool". See "QuickRegression".
+*/
+func Regression(name, property string, args []interface{}, conf Config) []byte {
+	return appendRegression(nil, name, `if !`+property, args, false, conf)
+}
+
+/*
+Like "Regression", but takes the error returned by "quick.Check", which must be
+"*quick.CheckError", and uses its inputs as the arguments:
+
+	err := quick.Check(someProperty, nil)
+	if err != nil {
+		code, _ := repr.QuickRegression("TestSomeRegression", "someProperty", err, repr.Default)
+		t.Fatalf("%v\n\n%s", err, code)
+	}
+
+Failures of "quick.CheckEqual" compare two functions, and are rejected with an
+error. Use "Regression" with a property comparing them instead.
+*/
+func QuickRegression(name, property string, err error, conf Config) ([]byte, error) {
+	args, ok := quickInputs(err)
+	if !ok {
+		return nil, fmt.Errorf(`repr: expected *quick.CheckError, got %T`, err)
+	}
+	return Regression(name, property, args, conf), nil
+}
+
+// Extracts "quick.CheckError.In" via reflection. Importing "testing/quick"
+// would register its command line flags in every program using this package.
+func quickInputs(err error) ([]interface{}, bool) {
+	rval := reflect.ValueOf(err)
+	if rval.Kind() != reflect.Ptr || rval.IsNil() {
+		return nil, false
+	}
+
+	rtype := rval.Type().Elem()
+	if rtype.PkgPath() != `testing/quick` || rtype.Name() != `CheckError` {
+		return nil, false
+	}
+
+	args, ok := rval.Elem().FieldByName(`In`).Interface().([]interface{})
+	return args, ok
+}
+
+/*
+Formats a regression test for a fuzzing failure, which calls the provided
+function with "t" and the arguments. Fuzz targets are usually closures passed
+to "testing.F.Fuzz", so the function is typically the body of such a target
+extracted into a named function:
+
+	func TestSomeRegression(t *testing.T) {
+		fuzzSomething(
+			t,
+			[]uint8{0x01, 0x02},
+			"input",
+		)
+	}
+
+The arguments may be taken from the corpus file of a failing input, which
+"go test" writes to "testdata/fuzz".
+*/
+func FuzzRegression(name, target string, args []interface{}, conf Config) []byte {
+	return appendRegression(nil, name, target, args, true, conf)
+}
+
+// The call is the last statement of the test in fuzz mode, and the condition
+// of an "if" statement otherwise.
+func appendRegression(out []byte, name, call string, args []interface{}, withT bool, conf Config) []byte {
+	fmter := fmter{conf: &conf}
+	fmter.ownConf()
+	fmter.conf.LinePrefix = ``
+
+	out = append(out, `func `...)
+	out = append(out, name...)
+	out = append(out, `(t *testing.T) {`...)
+
+	if conf.SingleLine() {
+		out = append(out, ' ')
+		out = append(out, call...)
+		out = append(out, '(')
+		if withT {
+			out = append(out, 't')
+		}
+		for i, arg := range args {
+			if withT || i > 0 {
+				out = append(out, ',', ' ')
+			}
+			out = appendAny(out, arg, fmter)
+		}
+		out = append(out, ')')
+		if !withT {
+			out = append(out, ` { t.Fatal("property failed for counterexample") }`...)
+		}
+		return append(out, ` }`...)
+	}
+
+	fmter.indent = 1
+	out = appendNewline(out, fmter)
+	out = appendIndent(out, fmter)
+	out = append(out, call...)
+	out = append(out, '(')
+
+	fmter.indent = 2
+	if withT {
+		out = appendNewline(out, fmter)
+		out = appendIndent(out, fmter)
+		out = append(out, 't', ',')
+	}
+	for _, arg := range args {
+		out = appendNewline(out, fmter)
+		out = appendIndent(out, fmter)
+		out = appendAny(out, arg, fmter)
+		out = append(out, ',')
+	}
+
+	fmter.indent = 1
+	if withT || len(args) > 0 {
+		out = appendNewline(out, fmter)
+		out = appendIndent(out, fmter)
+	}
+	out = append(out, ')')
+
+	if !withT {
+		out = append(out, ` {`...)
+		fmter.indent = 2
+		out = appendNewline(out, fmter)
+		out = appendIndent(out, fmter)
+		out = append(out, `t.Fatal("property failed for counterexample")`...)
+		fmter.indent = 1
+		out = appendNewline(out, fmter)
+		out = appendIndent(out, fmter)
+		out = append(out, '}')
+	}
+
+	fmter.indent = 0
+	out = appendNewline(out, fmter)
+	return append(out, '}')
+}
+
+/*
+Formats the values as a seed corpus file for native Go fuzzing, starting with
+the "go test fuzz v1" header. The values must have the exact types accepted by
+fuzz targets: "[]byte", "string", "bool", "rune", "byte" and the other built-in
+numeric types. Named types are rejected, since fuzz targets can't accept them.
+*/
+func FuzzCorpus(vals ...interface{}) ([]byte, error) {
+	out := []byte("go test fuzz v1\n")
+	for i, val := range vals {
+		var err error
+		out, err = appendFuzzValue(out, val)
+		if err != nil {
+			return nil, fmt.Errorf(`repr: fuzz corpus value %v: %v`, i, err)
+		}
+		out = append(out, '\n')
+	}
+	return out, nil
+}
+
+/*
+Writes the values as a seed corpus entry for the given fuzz target, in the
+layout expected by "go test": "<dir>/testdata/fuzz/<target>/<hash>". Returns
+the path of the written file. See "FuzzCorpus" for the supported types.
+*/
+func WriteFuzzCorpus(dir, target string, vals ...interface{}) (string, error) {
+	out, err := FuzzCorpus(vals...)
+	if err != nil {
+		return ``, err
+	}
+
+	sum := sha256.Sum256(out)
+	dir = filepath.Join(dir, `testdata`, `fuzz`, target)
+	path := filepath.Join(dir, hex.EncodeToString(sum[:])[:16])
+
+	err = os.MkdirAll(dir, os.ModePerm)
+	if err != nil {
+		return ``, err
+	}
+	return path, ioutil.WriteFile(path, out, 0666)
+}
+
+// Mirrors the encoding used by "go test" for corpus files.
+func appendFuzzValue(out []byte, val interface{}) ([]byte, error) {
+	switch val := val.(type) {
+	case []byte:
+		out = strconv.AppendQuote(append(out, `[]byte(`...), bytesToMutableString(val))
+	case string:
+		out = strconv.AppendQuote(append(out, `string(`...), val)
+	case bool:
+		out = strconv.AppendBool(append(out, `bool(`...), val)
+	case byte:
+		out = strconv.AppendQuoteRune(append(out, `byte(`...), rune(val))
+	case rune:
+		if unicode.IsPrint(val) {
+			out = strconv.AppendQuoteRune(append(out, `rune(`...), val)
+		} else {
+			out = strconv.AppendInt(append(out, `int32(`...), int64(val), 10)
+		}
+	case int:
+		out = strconv.AppendInt(append(out, `int(`...), int64(val), 10)
+	case int8:
+		out = strconv.AppendInt(append(out, `int8(`...), int64(val), 10)
+	case int16:
+		out = strconv.AppendInt(append(out, `int16(`...), int64(val), 10)
+	case int64:
+		out = strconv.AppendInt(append(out, `int64(`...), val, 10)
+	case uint:
+		out = strconv.AppendUint(append(out, `uint(`...), uint64(val), 10)
+	case uint16:
+		out = strconv.AppendUint(append(out, `uint16(`...), uint64(val), 10)
+	case uint32:
+		out = strconv.AppendUint(append(out, `uint32(`...), uint64(val), 10)
+	case uint64:
+		out = strconv.AppendUint(append(out, `uint64(`...), val, 10)
+	case float32:
+		if math.IsNaN(float64(val)) {
+			return append(out, fmt.Sprintf(`math.Float32frombits(0x%x)`, math.Float32bits(val))...), nil
+		}
+		out = append(append(out, `float32(`...), fmt.Sprint(val)...)
+	case float64:
+		if math.IsNaN(val) {
+			return append(out, fmt.Sprintf(`math.Float64frombits(0x%x)`, math.Float64bits(val))...), nil
+		}
+		out = append(append(out, `float64(`...), fmt.Sprint(val)...)
+	default:
+		return out, fmt.Errorf(`unsupported type %T`, val)
+	}
+	return append(out, ')'), nil
+}
@@ -0,0 +1,212 @@
+package repr
+
+import (
+	"go/format"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/quick"
+
+	"github.com/mitranim/repr/test"
+)
+
+func TestTestTable(t *testing.T) {
+	cases := []TestCase{
+		{Name: "uint", In: "uint256", Want: test.AbiType{Type: "uint256", Kind: test.AbiKindUint}},
+		{Name: "bool", In: "bool", Want: test.AbiType{Type: "bool", Kind: test.AbiKindBool}},
+	}
+
+	actual := string(TestTable(cases, Default))
+	expected := `[]struct {
+	name string
+	in   string
+	want test.AbiType
+}{
+	{
+		name: "uint",
+		in: "uint256",
+		want: test.AbiType{
+			Type: "uint256",
+			Kind: 2,
+		},
+	},
+	{
+		name: "bool",
+		in: "bool",
+		want: test.AbiType{
+			Type: "bool",
+			Kind: 1,
+		},
+	},
+}`
+	if actual != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+	}
+
+	actual = string(TestTable(cases, Config{}))
+	expected = `[]struct{name string; in string; want test.AbiType}{{name: "uint", in: "uint256", want: test.AbiType{Type: "uint256", Kind: 2}}, {name: "bool", in: "bool", want: test.AbiType{Type: "bool", Kind: 1}}}`
+	if actual != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+	}
+}
+
+func TestTestTableMixedTypes(t *testing.T) {
+	cases := []TestCase{
+		{Name: "int", In: 1, Want: nil},
+		{Name: "string", In: "two", Want: []int(nil)},
+	}
+
+	code := TestTable(cases, Default)
+	_, err := format.Source(append([]byte("package p\nvar _ = "), code...))
+	if err != nil {
+		t.Fatalf("failed to format via gofmt: %v\n%s", err, code)
+	}
+
+	actual := string(code)
+	expected := `[]struct {
+	name string
+	in   interface{}
+	want interface{}
+}{
+	{
+		name: "int",
+		in: 1,
+		want: nil,
+	},
+	{
+		name: "string",
+		in: "two",
+		want: []int(nil),
+	},
+}`
+	if actual != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+	}
+}
+
+func TestRegression(t *testing.T) {
+	args := []interface{}{test.AbiType{Type: "uint256", Kind: test.AbiKindUint}, -5}
+
+	code := Regression(`TestAbiRegression`, `isValidAbi`, args, Default)
+	_, err := format.Source(append([]byte("package p\n"), code...))
+	if err != nil {
+		t.Fatalf("failed to format via gofmt: %v\n%s", err, code)
+	}
+
+	actual := string(code)
+	expected := `func TestAbiRegression(t *testing.T) {
+	if !isValidAbi(
+		test.AbiType{
+			Type: "uint256",
+			Kind: 2,
+		},
+		-5,
+	) {
+		t.Fatal("property failed for counterexample")
+	}
+}`
+	if actual != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+	}
+
+	actual = string(Regression(`TestAbiRegression`, `isValidAbi`, args, Config{}))
+	expected = `func TestAbiRegression(t *testing.T) { if !isValidAbi(test.AbiType{Type: "uint256", Kind: 2}, -5) { t.Fatal("property failed for counterexample") } }`
+	if actual != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+	}
+}
+
+func TestQuickRegression(t *testing.T) {
+	err := quick.Check(func(val uint8) bool { return val < 200 }, &quick.Config{MaxCount: 10000})
+	if err == nil {
+		t.Fatalf(`expected the property to fail`)
+	}
+
+	code, err := QuickRegression(`TestSmall`, `isSmall`, err, Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(code), `func TestSmall(t *testing.T) { if !isSmall(0x`) {
+		t.Fatalf(`unexpected output: %s`, code)
+	}
+
+	_, err = QuickRegression(`TestSmall`, `isSmall`, &quick.CheckEqualError{}, Config{})
+	if err == nil {
+		t.Fatalf(`expected an error for quick.CheckEqualError`)
+	}
+}
+
+func TestFuzzRegression(t *testing.T) {
+	actual := string(FuzzRegression(`TestParseRegression`, `fuzzParse`, []interface{}{[]byte{1, 2}, "input"}, Default))
+	expected := `func TestParseRegression(t *testing.T) {
+	fuzzParse(
+		t,
+		[]uint8{0x01, 0x02},
+		"input",
+	)
+}`
+	if actual != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+	}
+
+	actual = string(FuzzRegression(`TestParseRegression`, `fuzzParse`, []interface{}{"input"}, Config{}))
+	expected = `func TestParseRegression(t *testing.T) { fuzzParse(t, "input") }`
+	if actual != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+	}
+}
+
+func TestFuzzCorpus(t *testing.T) {
+	out, err := FuzzCorpus([]byte("\x00hi"), "str", true, byte('b'), 'r', rune(0), -5, uint64(7), 1.5, float32(2))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	actual := string(out)
+	expected := `go test fuzz v1
+[]byte("\x00hi")
+string("str")
+bool(true)
+byte('b')
+rune('r')
+int32(0)
+int(-5)
+uint64(7)
+float64(1.5)
+float32(2)
+`
+	if actual != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+	}
+
+	_, err = FuzzCorpus(test.AbiKindInt)
+	if err == nil {
+		t.Fatalf("expected an error for a named type")
+	}
+}
+
+func TestWriteFuzzCorpus(t *testing.T) {
+	dir, err := ioutil.TempDir(``, `repr`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path, err := WriteFuzzCorpus(dir, `FuzzParse`, "seed")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if filepath.Dir(path) != filepath.Join(dir, `testdata`, `fuzz`, `FuzzParse`) {
+		t.Fatalf("unexpected corpus path %q", path)
+	}
+
+	actual, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(actual) != "go test fuzz v1\nstring(\"seed\")\n" {
+		t.Fatalf("unexpected corpus file content:\n%s", actual)
+	}
+}
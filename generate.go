@@ -0,0 +1,130 @@
+package repr
+
+import (
+	"fmt"
+	"os"
+)
+
+/*
+Options for "Generate". Exactly one of "Value", "Expr" or "JSON" must be set.
+*/
+type GenerateOptions struct {
+	/**
+	Path of the generated file. If empty, the file is written to stdout.
+	*/
+	Output string
+
+	/**
+	Package name of the generated file. Defaults to the "GOPACKAGE" environment
+	variable, which is set by "go generate".
+	*/
+	Package string
+
+	/**
+	Name of the generated variable. Required.
+	*/
+	Var string
+
+	/**
+	Value to generate. Used when calling "Generate" from Go code.
+	*/
+	Value interface{}
+
+	/**
+	Go expression to generate, parsed via "Parse" into an "interface{}". Type
+	names in the expression are resolved via "Types".
+	*/
+	Expr  string
+	Types Types
+
+	/**
+	JSON to generate, decoded into an "interface{}". Formatted like "BytesJSON":
+	"Config.TypedNumbers" is always enabled, see "JSONConfig".
+	*/
+	JSON []byte
+
+	/**
+	Format settings. "Config.SortKeys" is always enabled, for reproducible output.
+	*/
+	Config Config
+
+	/**
+	Header comment of the generated file. See "File.Header".
+	*/
+	Header Header
+
+	/**
+	Data source of the value, such as the path of the JSON file, recorded in a
+	comment. See "Decls.AddFrom".
+	*/
+	Source string
+}
+
+/*
+Generates a Go file declaring a single variable, suitable for use with
+"go:generate" directives. The output is formatted via "go/format" and written
+atomically, so a failed run never leaves a partially written file. See the
+"cmd/repr" command for a command-line interface.
+*/
+func Generate(opts GenerateOptions) error {
+	val, err := opts.value()
+	if err != nil {
+		return err
+	}
+
+	if opts.Var == `` {
+		return fmt.Errorf(`repr: Generate requires a variable name`)
+	}
+
+	file := File{Package: opts.Package, Header: opts.Header}
+	if file.Package == `` {
+		file.Package = os.Getenv(`GOPACKAGE`)
+	}
+	file.Decls.Config = opts.Config
+	file.Decls.Config.SortKeys = true
+	if opts.JSON != nil {
+		file.Decls.Config.TypedNumbers = true
+	}
+	file.Decls.AddFrom(opts.Var, opts.Source, val)
+
+	out, err := file.Bytes()
+	if err != nil {
+		return err
+	}
+
+	if opts.Output == `` {
+		_, err = os.Stdout.Write(out)
+		return err
+	}
+	return writeFileAtomic(opts.Output, out)
+}
+
+func (self GenerateOptions) value() (interface{}, error) {
+	var count int
+	for _, ok := range []bool{self.Value != nil, self.Expr != ``, self.JSON != nil} {
+		if ok {
+			count++
+		}
+	}
+	if count != 1 {
+		return nil, fmt.Errorf(`repr: Generate requires exactly one of Value, Expr or JSON`)
+	}
+
+	var val interface{}
+	switch {
+	case self.Expr != ``:
+		err := Parse([]byte(self.Expr), &val, self.Types)
+		if err != nil {
+			return nil, err
+		}
+	case self.JSON != nil:
+		var err error
+		val, err = decodeJSON(self.JSON)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		val = self.Value
+	}
+	return val, nil
+}
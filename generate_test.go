@@ -0,0 +1,82 @@
+package repr
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	dir, err := ioutil.TempDir(``, `repr`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, `fixtures.go`)
+	err = Generate(GenerateOptions{
+		Output:  path,
+		Package: `fixtures`,
+		Var:     `fixtures`,
+		JSON:    []byte(`{"one": [1, 2.5], "two": "three", "four": {"five": true, "six": null}}`),
+		Config:  Default,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	actual, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `package fixtures
+
+var fixtures = map[string]interface{}{
+	"four": map[string]interface{}{
+		"five": true,
+		"six":  nil,
+	},
+	"one": []interface{}{
+		float64(1),
+		2.5,
+	},
+	"two": "three",
+}
+`
+	if string(actual) != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%s", expected, actual)
+	}
+}
+
+func TestGenerateExpr(t *testing.T) {
+	opts := GenerateOptions{Package: `fixtures`, Var: `nums`, Expr: `[]int{1, 2, 3}`}
+	val, err := opts.value()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	actual := String(val)
+	expected := `[]int{1, 2, 3}`
+	if actual != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+	}
+}
+
+func TestGenerateInvalidOptions(t *testing.T) {
+	err := Generate(GenerateOptions{Package: `fixtures`, Var: `val`})
+	if err == nil {
+		t.Fatalf("expected an error without input")
+	}
+
+	err = Generate(GenerateOptions{Package: `fixtures`, Var: `val`, Value: 1, Expr: `1`})
+	if err == nil {
+		t.Fatalf("expected an error with multiple inputs")
+	}
+
+	err = Generate(GenerateOptions{Package: `fixtures`, Value: 1})
+	if err == nil {
+		t.Fatalf("expected an error without a variable name")
+	}
+}
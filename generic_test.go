@@ -0,0 +1,47 @@
+//go:build go1.18
+// +build go1.18
+
+package repr
+
+import (
+	"testing"
+)
+
+type testPair[T any] struct{ Val T }
+
+func TestLangVersionGeneric(t *testing.T) {
+	val := []testPair[int]{{1}}
+
+	conf := CompactConfig
+	expected := `[]repr.testPair[int]{{Val: 1}}`
+	for _, ver := range []string{``, `go1.18`, `go1.21rc1`} {
+		conf.LangVersion = ver
+		actual, err := StringE(val, conf)
+		if err != nil || actual != expected {
+			t.Fatalf("unexpected output for %q:\n%v\nerror: %v", ver, actual, err)
+		}
+	}
+
+	conf.LangVersion = `go1.17`
+	file := File{Package: `fixtures`, Decls: Decls{Config: conf}}
+	file.Decls.AddNamed(`pairs`, val)
+	_, err := file.Bytes()
+	if err == nil {
+		t.Fatalf(`expected an error for a generic type in a file before go1.18`)
+	}
+
+	out, err := AppendE([]byte(`prefix`), val, conf)
+	if err == nil {
+		t.Fatalf(`expected an error for a generic type before go1.18`)
+	}
+	if string(out) != `prefix` {
+		t.Fatalf(`expected the buffer to be unchanged, got %q`, out)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatalf(`expected a panic for a generic type before go1.18`)
+		}
+	}()
+	StringC(testPair[int]{1}, conf)
+}
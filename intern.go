@@ -0,0 +1,127 @@
+package repr
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+/*
+Strings declared as shared constants, see "Decls.InternStrings", keyed by
+their literals as printed.
+*/
+type interning struct {
+	// Single-line config for printing literals.
+	conf  Config
+	names map[string]string
+}
+
+func newInterning(conf Config) *interning {
+	conf.Indent = ``
+	conf.PathOverrides = nil
+	conf.OverrideFunc = nil
+	return &interning{conf: conf, names: map[string]string{}}
+}
+
+/*
+Returns the literal printed for the string, or false if the string is printed
+as something else, such as the name of a constant via "Config.ConstName", a
+"GoString" result, or a truncated string with a comment.
+*/
+func (self *interning) literal(val interface{}) (string, bool) {
+	code := string(appendAny(nil, val, fmter{conf: &self.conf, elideType: true}))
+	_, err := strconv.Unquote(code)
+	return code, err == nil
+}
+
+/*
+Returns a reference to the constant equal to the string, if any. Named string
+types are converted where the type isn't implied: "URL(homeURL)".
+*/
+func (self fmter) internedName(val interface{}) (string, bool) {
+	rtype := reflect.TypeOf(val)
+	if rtype == nil || rtype.Kind() != reflect.String {
+		return ``, false
+	}
+
+	code, ok := self.state.interning.literal(val)
+	if !ok {
+		return ``, false
+	}
+	name, ok := self.state.interning.names[code]
+	if !ok || self.elideType || rtype == stringType {
+		return name, ok
+	}
+	return string(appendTypeName(nil, rtype, self)) + `(` + name + `)`, true
+}
+
+/*
+Selects repeated strings to declare as constants, see "Decls.InternStrings".
+Returns their declarations, in the order of first occurrence, and the mapping
+used to refer to them. Names of the given declarations are avoided.
+*/
+func (self *Decls) interned(taken []decl) ([]decl, *interning) {
+	interning := newInterning(self.Config)
+	counts := map[string]int{}
+
+	type candidate struct {
+		code string
+		path string
+	}
+	var candidates []candidate
+
+	for _, decl := range self.list {
+		Walk(decl.val, func(path string, rval reflect.Value) bool {
+			if path == `` || rval.Kind() != reflect.String || rval.Len() < self.InternStrings || !rval.CanInterface() {
+				return true
+			}
+			code, ok := interning.literal(rval.Interface())
+			if !ok {
+				return true
+			}
+			counts[code]++
+			if counts[code] == 1 {
+				candidates = append(candidates, candidate{code, path})
+			}
+			return true
+		}, self.Config)
+	}
+
+	// Names are reserved in a copy, keeping repeated calls deterministic.
+	names := Decls{names: make(map[string]struct{}, len(self.names))}
+	for name := range self.names {
+		names.reserve(name)
+	}
+	for _, decl := range taken {
+		names.reserve(decl.name)
+	}
+
+	var list []decl
+	for _, cand := range candidates {
+		if counts[cand.code] < 2 {
+			continue
+		}
+		val, _ := strconv.Unquote(cand.code)
+		name := names.unique(internedStringName(cand.path))
+		interning.names[cand.code] = name
+		list = append(list, decl{name: name, val: val})
+	}
+	return list, interning
+}
+
+// Names the constant after the innermost struct field containing the first
+// occurrence of the string, such as "homeURL" for ".Links[0].HomeURL", or "str"
+// if there's none.
+func internedStringName(path string) string {
+	segments := splitPath(path)
+	for i := len(segments) - 1; i >= 0; i-- {
+		if strings.HasPrefix(segments[i], `.`) {
+			name := lowerInitial(segments[i][len(`.`):])
+			if isReservedIdent(name) {
+				name += `Str`
+			}
+			return name
+		}
+	}
+	return `str`
+}
@@ -0,0 +1,39 @@
+package repr
+
+import (
+	"testing"
+)
+
+func TestDeclsInternStrings(t *testing.T) {
+	type URL string
+	type Link struct {
+		Title   string
+		HomeURL URL
+	}
+
+	const home = `https://example.com`
+
+	decls := Decls{Config: CompactConfig, InternStrings: 8}
+	decls.AddNamed(`links`, []Link{{`Home`, home}, {`About`, home}})
+	decls.AddNamed(`urls`, []interface{}{home, URL(home), `short`, `short`})
+	decls.AddNamed(`root`, home)
+
+	actual := decls.String()
+	expected := `const homeURL = "https://example.com"
+
+var (
+	links = []repr.Link{{Title: "Home", HomeURL: homeURL}, {Title: "About", HomeURL: homeURL}}
+
+	urls = []interface {}{homeURL, repr.URL(homeURL), "short", "short"}
+
+	root = "https://example.com"
+)
+`
+	if actual != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+	}
+
+	if internedStringName(`.Items[0].Type`) != `typeStr` || internedStringName(`[1]`) != `str` {
+		t.Fatalf(`unexpected names`)
+	}
+}
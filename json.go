@@ -0,0 +1,63 @@
+package repr
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+/*
+Preset for trees decoded from JSON into "interface{}", consisting of
+"map[string]interface{}", "[]interface{}", "string", "float64", "bool" and nil.
+Such trees have a single deterministic rendering, which is also valid Go code
+equal to the original tree:
+
+	map[string]interface {}{
+		"list": []interface {}{
+			float64(1),
+			0.5,
+			"two",
+		},
+		"null": nil,
+		"ok": true,
+	}
+
+• Keys are sorted at every level, by their Go literals, see "Config.SortKeys".
+
+• Numbers keep the type "float64", see "Config.TypedNumbers". Integers are
+wrapped in conversions, other numbers are printed as the shortest literals
+which decode to the same values.
+
+• Nested maps and slices are printed with their types, since they're stored in
+interfaces.
+*/
+var JSONConfig = Config{
+	Indent:       "\t",
+	PackageMap:   map[string]string{`main`: ``},
+	SortKeys:     true,
+	TypedNumbers: true,
+}
+
+/*
+Decodes JSON into "interface{}" via "encoding/json", and formats the result with
+the provided config, usually "JSONConfig". "Config.SortKeys" and
+"Config.TypedNumbers" are always enabled, which makes the output depend only on
+the JSON and the layout settings of the config. See "JSONConfig".
+*/
+func BytesJSON(src []byte, conf Config) ([]byte, error) {
+	val, err := decodeJSON(src)
+	if err != nil {
+		return nil, err
+	}
+	conf.SortKeys = true
+	conf.TypedNumbers = true
+	return BytesE(val, conf)
+}
+
+func decodeJSON(src []byte) (interface{}, error) {
+	var val interface{}
+	err := json.Unmarshal(src, &val)
+	if err != nil {
+		return nil, fmt.Errorf(`repr: failed to decode JSON: %v`, err)
+	}
+	return val, nil
+}
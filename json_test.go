@@ -0,0 +1,64 @@
+package repr
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBytesJSON(t *testing.T) {
+	src := []byte(`{"ok": true, "null": null, "list": [1, 0.5, "two", {"b": 2, "a": [], "c": {}}], "num": -3, "big": 1e21}`)
+
+	actual, err := BytesJSON(src, JSONConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `map[string]interface {}{
+	"big": float64(1000000000000000000000),
+	"list": []interface {}{
+		float64(1),
+		0.5,
+		"two",
+		map[string]interface {}{
+			"a": []interface {}{},
+			"b": float64(2),
+			"c": map[string]interface {}{},
+		},
+	},
+	"null": nil,
+	"num": float64(-3),
+	"ok": true,
+}`
+	if string(actual) != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%s", expected, actual)
+	}
+
+	// Map iteration order is random, the output must not be.
+	for i := 0; i < 16; i++ {
+		again, err := BytesJSON(src, Default)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(again) != expected {
+			t.Fatalf("expected stable output, got:\n%s", again)
+		}
+	}
+
+	val, err := decodeJSON(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var parsed interface{}
+	err = Parse(actual, &parsed, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(parsed, val) {
+		t.Fatalf("parsed output differs from the decoded JSON:\n%v", String(parsed))
+	}
+
+	_, err = BytesJSON([]byte(`{`), JSONConfig)
+	if err == nil {
+		t.Fatalf(`expected an error for invalid JSON`)
+	}
+}
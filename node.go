@@ -0,0 +1,32 @@
+package repr
+
+import (
+	"go/ast"
+	"go/token"
+)
+
+/*
+Formats the value as an AST node for use with "go/printer", without a parse
+step. Useful for inserting values into files assembled by AST-based code
+generators. The result is a "*ast.BasicLit" holding the formatted code;
+go/printer emits such literals verbatim, so the output is identical to
+"BytesC".
+
+"pos" should be the position of the node being replaced, which keeps comments
+preceding the node in place, or "token.NoPos" for new nodes. "indent" is the
+indentation level of the line where the node begins: go/printer doesn't
+re-indent verbatim text, so nested lines are pre-indented accordingly.
+
+Panics if validation is enabled and fails, like "BytesC".
+*/
+func Node(val interface{}, conf Config, pos token.Pos, indent int) *ast.BasicLit {
+	conf.LinePrefix = ``
+	out := appendAny(nil, val, fmter{conf: &conf, indent: indent})
+	if conf.Validate {
+		err := validate(out)
+		if err != nil {
+			panic(err)
+		}
+	}
+	return &ast.BasicLit{ValuePos: pos, Kind: token.STRING, Value: bytesToMutableString(out)}
+}
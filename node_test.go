@@ -0,0 +1,66 @@
+package repr
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"testing"
+
+	"github.com/mitranim/repr/test"
+)
+
+func TestNode(t *testing.T) {
+	const src = `package fixtures
+
+// Doc comment.
+var abiType = 0
+
+func init() {
+	// Inner comment.
+	abiType = 0
+}
+`
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, ``, src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	val := test.AbiType{Type: "bool", Kind: test.AbiKindBool}
+
+	spec := file.Decls[0].(*ast.GenDecl).Specs[0].(*ast.ValueSpec)
+	spec.Values[0] = Node(val, Default, spec.Values[0].Pos(), 0)
+
+	stmt := file.Decls[1].(*ast.FuncDecl).Body.List[0].(*ast.AssignStmt)
+	stmt.Rhs[0] = Node(val, Default, stmt.Rhs[0].Pos(), 1)
+
+	var buf bytes.Buffer
+	err = printer.Fprint(&buf, fset, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual := buf.String()
+	expected := `package fixtures
+
+// Doc comment.
+var abiType = test.AbiType{
+	Type: "bool",
+	Kind: 1,
+}
+
+func init() {
+	// Inner comment.
+	abiType = test.AbiType{
+		Type: "bool",
+		Kind: 1,
+	}
+}
+`
+	if actual != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+	}
+}
@@ -0,0 +1,182 @@
+package repr
+
+import (
+	"reflect"
+	"strconv"
+)
+
+/*
+Formats a page of a large collection: elements of a slice or array, or entries
+of a map, with indexes in the range [from, to). Other elements are not
+visited, which allows interactive tools to show the first page of a huge
+collection instantly and fetch more on demand. Skipped elements are
+represented by comments:
+
+	[]int{
+		/* 100 elements before *\/
+		100,
+		101,
+		/* 898 more elements *\/
+	}
+
+Map entries are ordered as with "Config.SortKeys", which is always enabled for
+consistent paging. This requires visiting all keys, but values are visited only
+to order entries with equivalent keys, see "Config.SortKeys". Out of range
+bounds are clamped. Values of other kinds are formatted in full. Limits such as
+"Config.MaxElems" don't apply to the top-level collection.
+*/
+func RenderRange(val interface{}, from, to int, conf Config) []byte {
+	fmter := fmter{conf: &conf}
+	fmter.ownConf()
+	fmter.conf.SortKeys = true
+
+	rval := reflect.ValueOf(val)
+	switch rval.Kind() {
+	case reflect.Array, reflect.Slice, reflect.Map:
+	default:
+		return BytesC(val, conf)
+	}
+	if rval.Kind() != reflect.Array && rval.IsNil() {
+		return BytesC(val, conf)
+	}
+
+	total := rval.Len()
+	from, to = clampRange(from, to, total)
+
+	var out []byte
+	out = append(out, conf.LinePrefix...)
+	out = appendTypeName(out, rval.Type(), fmter)
+	fmter = fmter.enter()
+
+	if rval.Kind() == reflect.Map {
+		return appendMapPage(out, rval, from, to, fmter)
+	}
+	return appendListPage(out, rval, from, to, fmter)
+}
+
+func clampRange(from, to, total int) (int, int) {
+	if to > total {
+		to = total
+	}
+	if from < 0 {
+		from = 0
+	}
+	if from > to {
+		from = to
+	}
+	return from, to
+}
+
+func appendListPage(out []byte, rval reflect.Value, from, to int, fmter fmter) []byte {
+	elemType := rval.Type().Elem()
+	fmter.elideType = canElideType(elemType, fmter)
+
+	perRow := 1
+	appendElem := func(out []byte, i int) []byte {
+		return appendAny(out, fmter.elemValue(rval.Index(i)).Interface(), fmter.atIndex(i))
+	}
+
+	if elemType == byteType {
+		perRow = fmter.conf.bytesPerRow()
+	} else if columns := fmter.columns(elemType); columns > 0 {
+		perRow = columns
+		digits := elemType.Bits() / 4
+		appendElem = func(out []byte, i int) []byte {
+			return appendHex(out, rval.Index(i).Uint(), digits)
+		}
+	}
+
+	return appendPage(out, rval.Len(), from, to, perRow, `element`, `elements`, fmter, appendElem)
+}
+
+func appendMapPage(out []byte, rval reflect.Value, from, to int, fmter fmter) []byte {
+	rtype := rval.Type()
+	keyFmter := fmter
+	keyFmter.elideType = canElideType(rtype.Key(), fmter)
+	elemFmter := fmter
+	elemFmter.elideType = canElideType(rtype.Elem(), fmter)
+
+	entries := mapEntries(rval, keyFmter)
+
+	return appendPage(out, len(entries), from, to, 1, `entry`, `entries`, fmter, func(out []byte, i int) []byte {
+		out = appendAny(out, entries[i].key.Interface(), keyFmter)
+		out = appendColon(out, fmter)
+		out = appendAny(out, entries[i].val.Interface(), elemFmter.atKey(entries[i].key))
+		return out
+	})
+}
+
+// Similar to "appendRows", but prints only the elements in the range, with
+// comments about skipped elements on both sides.
+func appendPage(
+	out []byte, total, from, to, perRow int, singular, plural string, fmter fmter,
+	appendElem func([]byte, int) []byte,
+) []byte {
+	if fmter.conf.SingleLine() {
+		fmter.indent = 0
+		out = appendBraceOpen(out, total > 0, fmter)
+		if from > 0 {
+			out = appendSkipped(out, from, singular, plural)
+			if to > from {
+				out = append(out, ' ')
+			}
+		}
+		for i := from; i < to; i++ {
+			out = appendElem(out, i)
+			if i < to-1 {
+				out = append(out, ',', ' ')
+			}
+		}
+		out = appendOmitted(out, total-to, total, singular, plural, fmter)
+		out = appendBraceClose(out, total > 0, fmter)
+		return out
+	}
+
+	out = append(out, '{')
+	if total == 0 {
+		return append(out, '}')
+	}
+	out = appendNewline(out, fmter)
+	fmter.indent++
+
+	if from > 0 {
+		out = appendIndent(out, fmter)
+		out = appendSkipped(out, from, singular, plural)
+		out = appendNewline(out, fmter)
+	}
+
+	for i := from; i < to; i++ {
+		if (i-from)%perRow == 0 {
+			out = appendIndent(out, fmter)
+		} else {
+			out = append(out, ' ')
+		}
+		out = appendElem(out, i)
+		out = append(out, ',')
+		if (i-from)%perRow == perRow-1 || i == to-1 {
+			out = appendNewline(out, fmter)
+		}
+	}
+
+	if total > to {
+		out = appendIndent(out, fmter)
+		out = appendOmittedComment(out, total-to, total, singular, plural, fmter)
+		out = appendNewline(out, fmter)
+	}
+
+	fmter.indent--
+	out = appendIndent(out, fmter)
+	return append(out, '}')
+}
+
+func appendSkipped(out []byte, count int, singular, plural string) []byte {
+	out = append(out, `/* `...)
+	out = strconv.AppendInt(out, int64(count), 10)
+	out = append(out, ' ')
+	if count == 1 {
+		out = append(out, singular...)
+	} else {
+		out = append(out, plural...)
+	}
+	return append(out, ` before */`...)
+}
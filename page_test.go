@@ -0,0 +1,46 @@
+package repr
+
+import (
+	"testing"
+)
+
+func TestRenderRange(t *testing.T) {
+	test := func(expected string, val interface{}, from, to int, conf Config) {
+		t.Helper()
+		actual := string(RenderRange(val, from, to, conf))
+		if actual != expected {
+			t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+		}
+	}
+
+	list := make([]int, 1000)
+	for i := range list {
+		list[i] = i
+	}
+
+	test(`[]int{
+	/* 100 elements before */
+	100,
+	101,
+	/* 898 more elements */
+}`, list, 100, 102, Default)
+
+	test(`[]int{0, 1 /* 998 more elements */}`, list, -10, 2, CompactConfig)
+	test(`[]int{/* 998 elements before */ 998, 999}`, list, 998, 2000, CompactConfig)
+	test(`[]int{/* 1000 elements before */}`, list, 1000, 1000, CompactConfig)
+	test(`[]int{}`, []int{}, 0, 10, Default)
+
+	test(`[10]uint8{
+	/* 1 element before */
+	0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08,
+	0x09,
+}`, [10]byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, 1, 10, Default)
+
+	test(`map[string]int{
+	/* 1 entry before */
+	"three": 3,
+	/* 1 more entry */
+}`, map[string]int{`one`: 1, `two`: 2, `three`: 3}, 1, 2, Default)
+
+	test(`"str"`, `str`, 0, 1, Default)
+}
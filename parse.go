@@ -0,0 +1,848 @@
+package repr
+
+import (
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/parser"
+	"go/token"
+	"math"
+	"reflect"
+)
+
+/*
+Maps type names, as they appear in the output, to the corresponding types. Used
+by "Parse" to resolve type names in literals. Only required for types that can't
+be inferred from the destination, such as concrete types stored in interfaces.
+Built-in types such as "int" or "string" are always known.
+
+Use "TypesOf" to build a registry from sample values.
+*/
+type Types map[string]reflect.Type
+
+/*
+Builds a type registry from the given sample values, using the provided config
+to determine how type names are printed. Registers the type of each value, as
+well as every named type reachable from it through fields, elements and
+pointers. Concrete types stored in interfaces are not reachable this way and
+must be passed explicitly.
+*/
+func TypesOf(conf Config, vals ...interface{}) Types {
+	types := Types{}
+	for _, val := range vals {
+		types.add(reflect.TypeOf(val), conf)
+	}
+	return types
+}
+
+func (self Types) add(rtype reflect.Type, conf Config) {
+	if rtype == nil {
+		return
+	}
+
+	if rtype.Name() != `` {
+		name := string(appendTypeName(nil, rtype, fmter{conf: &conf}))
+		if self[name] == rtype {
+			return
+		}
+		self[name] = rtype
+	}
+
+	switch rtype.Kind() {
+	case reflect.Array, reflect.Slice, reflect.Ptr:
+		self.add(rtype.Elem(), conf)
+	case reflect.Map:
+		self.add(rtype.Key(), conf)
+		self.add(rtype.Elem(), conf)
+	case reflect.Struct:
+		for i := 0; i < rtype.NumField(); i++ {
+			self.add(rtype.Field(i).Type, conf)
+		}
+	}
+}
+
+/*
+Parses code produced by this package, decoding it into the value pointed to by
+"out". The inverse of "Bytes". Type names in the code are resolved via the
+provided registry, see "Types". Useful for round-trip tests:
+
+	types := repr.TypesOf(repr.Default, SomeType{})
+	var out SomeType
+	err := repr.Parse(repr.Bytes(val), &out, types)
+
+Supports the subset of Go expressions emitted by this package: composite
+literals, conversions, the "&" operator on composite literals, constant
+expressions, and the calls used for NaN, infinities and negative zero, such as
+"math.NaN()".
+*/
+func Parse(src []byte, out interface{}, types Types) error {
+	rval := reflect.ValueOf(out)
+	if rval.Kind() != reflect.Ptr || rval.IsNil() {
+		return fmt.Errorf(`repr: Parse requires a non-nil pointer, got %T`, out)
+	}
+
+	expr, err := parser.ParseExpr(string(src))
+	if err != nil {
+		return err
+	}
+	return evaluator{types}.eval(expr, rval.Elem())
+}
+
+/*
+Maximum length of slices and arrays created by "Parse". Indexed elements allow
+short code to describe long lists, such as "[]int{1000000000000: 1}", which
+would otherwise exhaust memory. Longer lists are reported as errors. Not
+synchronized: modify before use, such as in "init".
+*/
+var MaxParseLen = 1 << 24
+
+type evaluator struct{ types Types }
+
+func (self evaluator) eval(expr ast.Expr, dst reflect.Value) error {
+	if dst.Kind() == reflect.Interface {
+		return self.evalInterface(expr, dst)
+	}
+
+	switch expr := expr.(type) {
+	case *ast.CompositeLit:
+		return self.evalComposite(expr, dst)
+
+	case *ast.UnaryExpr:
+		if expr.Op == token.AND {
+			return self.evalAddr(expr, dst)
+		}
+
+	case *ast.CallExpr:
+		if isNumberCall(expr) {
+			return self.evalNumberCall(expr, dst)
+		}
+		return self.evalConversion(expr, dst)
+
+	case *ast.ParenExpr:
+		if !isConstExpr(expr) {
+			return self.eval(expr.X, dst)
+		}
+
+	case *ast.Ident:
+		if expr.Name == `nil` {
+			if !isNilableKind(dst.Kind()) {
+				return errAt(expr, fmt.Errorf(`can't assign nil to %v`, dst.Type()))
+			}
+			dst.Set(reflect.Zero(dst.Type()))
+			return nil
+		}
+	}
+
+	val, err := evalConst(expr)
+	if err != nil {
+		return err
+	}
+	return errAt(expr, setConst(dst, val))
+}
+
+func (self evaluator) evalInterface(expr ast.Expr, dst reflect.Value) error {
+	if ident, _ := expr.(*ast.Ident); ident != nil && ident.Name == `nil` {
+		dst.Set(reflect.Zero(dst.Type()))
+		return nil
+	}
+
+	rtype, err := self.exprType(expr)
+	if err != nil {
+		return err
+	}
+	if !rtype.AssignableTo(dst.Type()) {
+		return errAt(expr, fmt.Errorf(`%v is not assignable to %v`, rtype, dst.Type()))
+	}
+
+	val := reflect.New(rtype).Elem()
+	err = self.eval(expr, val)
+	if err != nil {
+		return err
+	}
+	dst.Set(val)
+	return nil
+}
+
+// Determines the type of an expression in a context that doesn't provide one,
+// such as an element of an interface slice.
+func (self evaluator) exprType(expr ast.Expr) (reflect.Type, error) {
+	switch expr := expr.(type) {
+	case *ast.CompositeLit:
+		if expr.Type == nil {
+			return nil, errAt(expr, fmt.Errorf(`can't infer the type of a literal without a type name`))
+		}
+		return self.resolveType(expr.Type)
+
+	case *ast.UnaryExpr:
+		if expr.Op == token.AND {
+			rtype, err := self.exprType(expr.X)
+			if err != nil {
+				return nil, err
+			}
+			return reflect.PtrTo(rtype), nil
+		}
+
+	case *ast.CallExpr:
+		if isNumberCall(expr) {
+			val, err := evalNumber(expr)
+			if err != nil {
+				return nil, err
+			}
+			return val.Type(), nil
+		}
+		return self.resolveType(expr.Fun)
+
+	case *ast.ParenExpr:
+		if !isConstExpr(expr) {
+			return self.exprType(expr.X)
+		}
+	}
+
+	val, err := evalConst(expr)
+	if err != nil {
+		return nil, err
+	}
+	return constDefaultType(expr, val)
+}
+
+func (self evaluator) evalComposite(expr *ast.CompositeLit, dst reflect.Value) error {
+	if expr.Type != nil {
+		rtype, err := self.resolveType(expr.Type)
+		if err != nil {
+			return err
+		}
+		if rtype != dst.Type() {
+			return errAt(expr, fmt.Errorf(`type mismatch: literal of type %v, expected %v`, rtype, dst.Type()))
+		}
+	}
+
+	switch dst.Kind() {
+	case reflect.Struct:
+		return self.evalStruct(expr, dst)
+	case reflect.Array:
+		return self.evalList(expr, dst)
+	case reflect.Slice:
+		size, err := sliceLitLen(expr)
+		if err != nil {
+			return err
+		}
+		dst.Set(reflect.MakeSlice(dst.Type(), size, size))
+		return self.evalList(expr, dst)
+	case reflect.Map:
+		return self.evalMap(expr, dst)
+	case reflect.Ptr:
+		// Elided "&T" in a list of pointers.
+		val := reflect.New(dst.Type().Elem())
+		err := self.evalComposite(expr, val.Elem())
+		if err != nil {
+			return err
+		}
+		dst.Set(val)
+		return nil
+	default:
+		return errAt(expr, fmt.Errorf(`unexpected composite literal for %v`, dst.Type()))
+	}
+}
+
+func (self evaluator) evalStruct(expr *ast.CompositeLit, dst reflect.Value) error {
+	dst.Set(reflect.Zero(dst.Type()))
+
+	for i, elt := range expr.Elts {
+		pair, _ := elt.(*ast.KeyValueExpr)
+		if pair == nil {
+			if i >= dst.NumField() {
+				return errAt(elt, fmt.Errorf(`too many values for %v`, dst.Type()))
+			}
+			if !dst.Field(i).CanSet() {
+				return errAt(elt, fmt.Errorf(`unexported field %q in %v`, dst.Type().Field(i).Name, dst.Type()))
+			}
+			err := self.eval(elt, dst.Field(i))
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		key, _ := pair.Key.(*ast.Ident)
+		if key == nil {
+			return errAt(pair.Key, fmt.Errorf(`expected field name in %v literal`, dst.Type()))
+		}
+
+		field := dst.FieldByName(key.Name)
+		if !field.IsValid() || !field.CanSet() {
+			return errAt(key, fmt.Errorf(`unknown or unexported field %q in %v`, key.Name, dst.Type()))
+		}
+
+		err := self.eval(pair.Value, field)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (self evaluator) evalList(expr *ast.CompositeLit, dst reflect.Value) error {
+	if dst.Kind() == reflect.Array {
+		dst.Set(reflect.Zero(dst.Type()))
+	}
+
+	index := 0
+	for _, elt := range expr.Elts {
+		if pair, _ := elt.(*ast.KeyValueExpr); pair != nil {
+			val, err := evalConst(pair.Key)
+			if err != nil {
+				return err
+			}
+			key, ok := constant.Int64Val(constant.ToInt(val))
+			if !ok {
+				return errAt(pair.Key, fmt.Errorf(`invalid index`))
+			}
+			index = int(key)
+			elt = pair.Value
+		}
+
+		if index < 0 || index >= dst.Len() {
+			return errAt(elt, fmt.Errorf(`index %v out of range for %v`, index, dst.Type()))
+		}
+
+		err := self.eval(elt, dst.Index(index))
+		if err != nil {
+			return err
+		}
+		index++
+	}
+	return nil
+}
+
+// Length of a slice literal, accounting for indexed elements.
+func sliceLitLen(expr *ast.CompositeLit) (int, error) {
+	size, index := 0, 0
+	for _, elt := range expr.Elts {
+		if pair, _ := elt.(*ast.KeyValueExpr); pair != nil {
+			val, err := evalConst(pair.Key)
+			if err != nil {
+				return 0, err
+			}
+			key, ok := constant.Int64Val(constant.ToInt(val))
+			if !ok || key < 0 {
+				return 0, errAt(pair.Key, fmt.Errorf(`invalid index`))
+			}
+			if key >= int64(MaxParseLen) {
+				return 0, errAt(pair.Key, fmt.Errorf(`index %v exceeds MaxParseLen %v`, key, MaxParseLen))
+			}
+			index = int(key)
+		}
+		index++
+		if index > size {
+			size = index
+		}
+	}
+	return size, nil
+}
+
+func (self evaluator) evalMap(expr *ast.CompositeLit, dst reflect.Value) error {
+	rtype := dst.Type()
+	dst.Set(reflect.MakeMapWithSize(rtype, len(expr.Elts)))
+
+	for _, elt := range expr.Elts {
+		pair, _ := elt.(*ast.KeyValueExpr)
+		if pair == nil {
+			return errAt(elt, fmt.Errorf(`expected key-value pair in %v literal`, rtype))
+		}
+
+		key := reflect.New(rtype.Key()).Elem()
+		err := self.eval(pair.Key, key)
+		if err != nil {
+			return err
+		}
+
+		val := reflect.New(rtype.Elem()).Elem()
+		err = self.eval(pair.Value, val)
+		if err != nil {
+			return err
+		}
+
+		dst.SetMapIndex(key, val)
+	}
+	return nil
+}
+
+func (self evaluator) evalAddr(expr *ast.UnaryExpr, dst reflect.Value) error {
+	if dst.Kind() != reflect.Ptr {
+		return errAt(expr, fmt.Errorf(`unexpected "&" for %v`, dst.Type()))
+	}
+
+	val := reflect.New(dst.Type().Elem())
+	err := self.eval(expr.X, val.Elem())
+	if err != nil {
+		return err
+	}
+	dst.Set(val)
+	return nil
+}
+
+func (self evaluator) evalConversion(expr *ast.CallExpr, dst reflect.Value) error {
+	if len(expr.Args) != 1 {
+		return errAt(expr, fmt.Errorf(`expected a conversion with exactly one argument`))
+	}
+
+	rtype, err := self.resolveType(expr.Fun)
+	if err != nil {
+		return err
+	}
+
+	val := reflect.New(rtype).Elem()
+	err = self.eval(expr.Args[0], val)
+	if err != nil {
+		return err
+	}
+
+	if !val.Type().ConvertibleTo(dst.Type()) {
+		return errAt(expr, fmt.Errorf(`can't convert %v to %v`, rtype, dst.Type()))
+	}
+	dst.Set(val.Convert(dst.Type()))
+	return nil
+}
+
+func (self evaluator) resolveType(expr ast.Expr) (reflect.Type, error) {
+	switch expr := expr.(type) {
+	case *ast.Ident:
+		rtype := builtinTypes[expr.Name]
+		if rtype == nil {
+			rtype = self.types[expr.Name]
+		}
+		if rtype == nil {
+			return nil, errAt(expr, fmt.Errorf(`unknown type %q`, expr.Name))
+		}
+		return rtype, nil
+
+	case *ast.SelectorExpr:
+		pkg, _ := expr.X.(*ast.Ident)
+		if pkg == nil {
+			break
+		}
+		name := pkg.Name + `.` + expr.Sel.Name
+		rtype := self.types[name]
+		if rtype == nil {
+			return nil, errAt(expr, fmt.Errorf(`unknown type %q`, name))
+		}
+		return rtype, nil
+
+	case *ast.ParenExpr:
+		return self.resolveType(expr.X)
+
+	case *ast.StarExpr:
+		elem, err := self.resolveType(expr.X)
+		if err != nil {
+			return nil, err
+		}
+		return reflect.PtrTo(elem), nil
+
+	case *ast.ArrayType:
+		elem, err := self.resolveType(expr.Elt)
+		if err != nil {
+			return nil, err
+		}
+		if expr.Len == nil {
+			return reflect.SliceOf(elem), nil
+		}
+
+		val, err := evalConst(expr.Len)
+		if err != nil {
+			return nil, err
+		}
+		size, ok := constant.Int64Val(constant.ToInt(val))
+		if !ok || size < 0 {
+			return nil, errAt(expr.Len, fmt.Errorf(`invalid array length`))
+		}
+		if size > int64(MaxParseLen) {
+			return nil, errAt(expr.Len, fmt.Errorf(`array length %v exceeds MaxParseLen %v`, size, MaxParseLen))
+		}
+		return reflect.ArrayOf(int(size), elem), nil
+
+	case *ast.MapType:
+		key, err := self.resolveType(expr.Key)
+		if err != nil {
+			return nil, err
+		}
+		if !key.Comparable() {
+			return nil, errAt(expr.Key, fmt.Errorf(`invalid map key type %v`, key))
+		}
+		elem, err := self.resolveType(expr.Value)
+		if err != nil {
+			return nil, err
+		}
+		return reflect.MapOf(key, elem), nil
+
+	case *ast.InterfaceType:
+		if expr.Methods == nil || len(expr.Methods.List) == 0 {
+			return interfaceType, nil
+		}
+
+	case *ast.ChanType:
+		elem, err := self.resolveType(expr.Value)
+		if err != nil {
+			return nil, err
+		}
+		dir := reflect.BothDir
+		if expr.Dir == ast.SEND {
+			dir = reflect.SendDir
+		} else if expr.Dir == ast.RECV {
+			dir = reflect.RecvDir
+		}
+		return reflect.ChanOf(dir, elem), nil
+
+	case *ast.FuncType:
+		params, variadic, err := self.resolveFields(expr.Params)
+		if err != nil {
+			return nil, err
+		}
+		results, _, err := self.resolveFields(expr.Results)
+		if err != nil {
+			return nil, err
+		}
+		return reflect.FuncOf(params, results, variadic), nil
+	}
+
+	return nil, errAt(expr, fmt.Errorf(`unsupported type expression`))
+}
+
+// Resolves the types of func parameters or results. A trailing "..." parameter
+// is resolved as a slice, as expected by "reflect.FuncOf".
+func (self evaluator) resolveFields(fields *ast.FieldList) ([]reflect.Type, bool, error) {
+	if fields == nil {
+		return nil, false, nil
+	}
+
+	var out []reflect.Type
+	variadic := false
+
+	for _, field := range fields.List {
+		typeExpr := field.Type
+		if ellipsis, _ := typeExpr.(*ast.Ellipsis); ellipsis != nil {
+			typeExpr = &ast.ArrayType{Lbrack: ellipsis.Pos(), Elt: ellipsis.Elt}
+			variadic = true
+		}
+
+		rtype, err := self.resolveType(typeExpr)
+		if err != nil {
+			return nil, false, err
+		}
+
+		count := len(field.Names)
+		if count == 0 {
+			count = 1
+		}
+		for i := 0; i < count; i++ {
+			out = append(out, rtype)
+		}
+	}
+	return out, variadic, nil
+}
+
+func (self evaluator) evalNumberCall(expr *ast.CallExpr, dst reflect.Value) error {
+	val, err := evalNumber(expr)
+	if err != nil {
+		return err
+	}
+
+	switch dst.Kind() {
+	case reflect.Float32, reflect.Float64:
+		if val.Kind() == reflect.Float64 {
+			dst.SetFloat(val.Float())
+			return nil
+		}
+	case reflect.Complex64, reflect.Complex128:
+		if val.Kind() == reflect.Complex128 {
+			dst.SetComplex(val.Complex())
+			return nil
+		}
+	}
+	return errAt(expr, fmt.Errorf(`can't assign %v to %v`, val.Type(), dst.Type()))
+}
+
+/*
+True for the calls printed for numbers without literals, such as NaN and
+negative zero, see "appendFloatCall" and "appendComplexCall": "math.NaN()",
+"math.Inf(1)", "math.Copysign(0, -1)" and "complex(re, im)".
+*/
+func isNumberCall(expr *ast.CallExpr) bool {
+	switch fun := expr.Fun.(type) {
+	case *ast.Ident:
+		return fun.Name == `complex`
+	case *ast.SelectorExpr:
+		pkg, _ := fun.X.(*ast.Ident)
+		if pkg == nil || pkg.Name != `math` {
+			return false
+		}
+		switch fun.Sel.Name {
+		case `NaN`, `Inf`, `Copysign`:
+			return true
+		}
+	}
+	return false
+}
+
+// Evaluates a call accepted by "isNumberCall", returning a "float64" or a
+// "complex128".
+func evalNumber(expr *ast.CallExpr) (reflect.Value, error) {
+	args := make([]float64, len(expr.Args))
+	for i, arg := range expr.Args {
+		num, err := evalFloat(arg)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		args[i] = num
+	}
+
+	arity := func(count int) error {
+		if len(args) != count {
+			return errAt(expr, fmt.Errorf(`expected %v arguments, got %v`, count, len(args)))
+		}
+		return nil
+	}
+
+	if _, ok := expr.Fun.(*ast.Ident); ok {
+		if err := arity(2); err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(complex(args[0], args[1])), nil
+	}
+
+	var num float64
+	switch expr.Fun.(*ast.SelectorExpr).Sel.Name {
+	case `NaN`:
+		if err := arity(0); err != nil {
+			return reflect.Value{}, err
+		}
+		num = math.NaN()
+	case `Inf`:
+		if err := arity(1); err != nil {
+			return reflect.Value{}, err
+		}
+		num = math.Inf(int(args[0]))
+	default:
+		if err := arity(2); err != nil {
+			return reflect.Value{}, err
+		}
+		num = math.Copysign(args[0], args[1])
+	}
+	return reflect.ValueOf(num), nil
+}
+
+// Evaluates an argument of a call accepted by "isNumberCall".
+func evalFloat(expr ast.Expr) (float64, error) {
+	if call, _ := expr.(*ast.CallExpr); call != nil && isNumberCall(call) {
+		val, err := evalNumber(call)
+		if err != nil {
+			return 0, err
+		}
+		if val.Kind() != reflect.Float64 {
+			return 0, errAt(expr, fmt.Errorf(`expected a real number`))
+		}
+		return val.Float(), nil
+	}
+
+	val, err := evalConst(expr)
+	if err != nil {
+		return 0, err
+	}
+	val = constant.ToFloat(val)
+	if val.Kind() != constant.Float {
+		return 0, errAt(expr, fmt.Errorf(`expected a number`))
+	}
+	num, _ := constant.Float64Val(val)
+	return num, nil
+}
+
+func evalConst(expr ast.Expr) (constant.Value, error) {
+	switch expr := expr.(type) {
+	case *ast.BasicLit:
+		val := constant.MakeFromLiteral(expr.Value, expr.Kind, 0)
+		if val.Kind() == constant.Unknown {
+			return nil, errAt(expr, fmt.Errorf(`invalid literal %v`, expr.Value))
+		}
+		return val, nil
+
+	case *ast.Ident:
+		switch expr.Name {
+		case `true`:
+			return constant.MakeBool(true), nil
+		case `false`:
+			return constant.MakeBool(false), nil
+		}
+
+	case *ast.ParenExpr:
+		return evalConst(expr.X)
+
+	case *ast.UnaryExpr:
+		val, err := evalConst(expr.X)
+		if err != nil {
+			return nil, err
+		}
+		switch expr.Op {
+		case token.ADD, token.SUB, token.XOR, token.NOT:
+			if !isConstOperand(expr.Op, val) || val.Kind() == constant.String {
+				return nil, errAt(expr, fmt.Errorf(`invalid operation %v on %v`, expr.Op, val))
+			}
+			return constant.UnaryOp(expr.Op, val, 0), nil
+		}
+
+	case *ast.BinaryExpr:
+		left, err := evalConst(expr.X)
+		if err != nil {
+			return nil, err
+		}
+		right, err := evalConst(expr.Y)
+		if err != nil {
+			return nil, err
+		}
+		switch expr.Op {
+		case token.ADD, token.SUB, token.MUL, token.QUO:
+			if !isConstOperand(expr.Op, left) || !isConstOperand(expr.Op, right) ||
+				(left.Kind() == constant.String) != (right.Kind() == constant.String) {
+				return nil, errAt(expr, fmt.Errorf(`invalid operation %v %v %v`, left, expr.Op, right))
+			}
+			if expr.Op == token.QUO && constant.Sign(right) == 0 {
+				return nil, errAt(expr, fmt.Errorf(`division by zero`))
+			}
+			return constant.BinaryOp(left, expr.Op, right), nil
+		}
+	}
+
+	return nil, errAt(expr, fmt.Errorf(`unsupported expression`))
+}
+
+// Operations which "go/constant" doesn't support panic, and must be reported
+// as errors instead.
+func isConstOperand(op token.Token, val constant.Value) bool {
+	switch val.Kind() {
+	case constant.Int:
+		return op != token.NOT
+	case constant.Float, constant.Complex:
+		return op == token.ADD || op == token.SUB || op == token.MUL || op == token.QUO
+	case constant.String:
+		return op == token.ADD
+	case constant.Bool:
+		return op == token.NOT
+	default:
+		return false
+	}
+}
+
+func isConstExpr(expr ast.Expr) bool {
+	_, err := evalConst(expr)
+	return err == nil
+}
+
+func setConst(dst reflect.Value, val constant.Value) error {
+	switch dst.Kind() {
+	case reflect.Bool:
+		if val.Kind() == constant.Bool {
+			dst.SetBool(constant.BoolVal(val))
+			return nil
+		}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		num, ok := constant.Int64Val(constant.ToInt(val))
+		if ok && !dst.OverflowInt(num) {
+			dst.SetInt(num)
+			return nil
+		}
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		num, ok := constant.Uint64Val(constant.ToInt(val))
+		if ok && !dst.OverflowUint(num) {
+			dst.SetUint(num)
+			return nil
+		}
+
+	case reflect.Float32, reflect.Float64:
+		val = constant.ToFloat(val)
+		if val.Kind() == constant.Float {
+			num, _ := constant.Float64Val(val)
+			dst.SetFloat(num)
+			return nil
+		}
+
+	case reflect.Complex64, reflect.Complex128:
+		val = constant.ToComplex(val)
+		if val.Kind() == constant.Complex {
+			re, _ := constant.Float64Val(constant.Real(val))
+			im, _ := constant.Float64Val(constant.Imag(val))
+			dst.SetComplex(complex(re, im))
+			return nil
+		}
+
+	case reflect.String:
+		if val.Kind() == constant.String {
+			dst.SetString(constant.StringVal(val))
+			return nil
+		}
+	}
+
+	return fmt.Errorf(`can't assign constant %v to %v`, val, dst.Type())
+}
+
+// Mirrors the default types of untyped constants in Go.
+func constDefaultType(expr ast.Expr, val constant.Value) (reflect.Type, error) {
+	switch val.Kind() {
+	case constant.Bool:
+		return builtinTypes[`bool`], nil
+	case constant.String:
+		return builtinTypes[`string`], nil
+	case constant.Int:
+		if lit, _ := expr.(*ast.BasicLit); lit != nil && lit.Kind == token.CHAR {
+			return builtinTypes[`rune`], nil
+		}
+		return builtinTypes[`int`], nil
+	case constant.Float:
+		return builtinTypes[`float64`], nil
+	case constant.Complex:
+		return builtinTypes[`complex128`], nil
+	default:
+		return nil, errAt(expr, fmt.Errorf(`unsupported constant %v`, val))
+	}
+}
+
+func isNilableKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Chan, reflect.Func, reflect.Interface, reflect.Map, reflect.Ptr, reflect.Slice:
+		return true
+	default:
+		return false
+	}
+}
+
+func errAt(node ast.Node, err error) error {
+	if err == nil {
+		return nil
+	}
+	return fmt.Errorf(`repr: offset %v: %v`, node.Pos()-1, err)
+}
+
+var interfaceType = reflect.TypeOf((*interface{})(nil)).Elem()
+
+var builtinTypes = map[string]reflect.Type{
+	`bool`:       reflect.TypeOf(false),
+	`string`:     reflect.TypeOf(``),
+	`int`:        reflect.TypeOf(int(0)),
+	`int8`:       reflect.TypeOf(int8(0)),
+	`int16`:      reflect.TypeOf(int16(0)),
+	`int32`:      reflect.TypeOf(int32(0)),
+	`rune`:       reflect.TypeOf(rune(0)),
+	`int64`:      reflect.TypeOf(int64(0)),
+	`uint`:       reflect.TypeOf(uint(0)),
+	`uint8`:      reflect.TypeOf(uint8(0)),
+	`byte`:       reflect.TypeOf(byte(0)),
+	`uint16`:     reflect.TypeOf(uint16(0)),
+	`uint32`:     reflect.TypeOf(uint32(0)),
+	`uint64`:     reflect.TypeOf(uint64(0)),
+	`uintptr`:    reflect.TypeOf(uintptr(0)),
+	`float32`:    reflect.TypeOf(float32(0)),
+	`float64`:    reflect.TypeOf(float64(0)),
+	`complex64`:  reflect.TypeOf(complex64(0)),
+	`complex128`: reflect.TypeOf(complex128(0)),
+	`error`:      reflect.TypeOf((*error)(nil)).Elem(),
+	`any`:        interfaceType,
+}
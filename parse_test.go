@@ -0,0 +1,169 @@
+package repr
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/mitranim/repr/test"
+)
+
+func TestParseRoundTrip(t *testing.T) {
+	types := TypesOf(Default, test.Abi{}, test.AbiFunction{}, test.AbiEvent{}, test.AbiConstructor{})
+
+	for _, conf := range []Config{Default, {}} {
+		var actual test.Abi
+		err := Parse(BytesC(testStructure, conf), &actual, types)
+		if err != nil {
+			t.Fatalf("failed to parse: %v", err)
+		}
+		if !reflect.DeepEqual(actual, testStructure) {
+			t.Fatalf("round-trip mismatch:\nexpected: %v\nactual: %v", String(testStructure), String(actual))
+		}
+	}
+}
+
+func TestParseScalars(t *testing.T) {
+	type Data struct {
+		Int     int
+		Uint    uint64
+		Float   float32
+		Complex complex128
+		String  string
+		Bool    bool
+		Kind    test.AbiKind
+		Ptr     uintptr
+		List    []interface{}
+		Dict    map[string]int
+		Nil     []int
+	}
+
+	val := Data{
+		Int:     -123,
+		Uint:    18446744073709551615,
+		Float:   -1.5,
+		Complex: complex(1.5, -2),
+		String:  "hello\nworld",
+		Bool:    true,
+		Kind:    test.AbiKindInt,
+		Ptr:     0xff,
+		List:    []interface{}{1, 2.5, "three", test.AbiKindBool, nil, []int{4}},
+		Dict:    map[string]int{"one": 1, "two": 2},
+	}
+
+	conf := Default
+	conf.ZeroFields = true
+	types := TypesOf(conf, val)
+
+	var actual Data
+	err := Parse(BytesC(val, conf), &actual, types)
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	if !reflect.DeepEqual(actual, val) {
+		t.Fatalf("round-trip mismatch:\nexpected: %v\nactual: %v", String(val), String(actual))
+	}
+}
+
+func TestParseNonFinite(t *testing.T) {
+	type Data struct {
+		Float   float32
+		Floats  map[float64]float64
+		Complex complex64
+		List    []interface{}
+	}
+
+	negZero := math.Copysign(0, -1)
+	val := Data{
+		Float:   float32(math.Inf(-1)),
+		Floats:  map[float64]float64{math.Inf(1): negZero, math.NaN(): math.NaN()},
+		Complex: complex64(complex(negZero, math.Inf(1))),
+		List:    []interface{}{math.NaN(), float32(negZero), complex(math.NaN(), 1)},
+	}
+
+	conf := Default
+	conf.SortKeys = true
+	src := BytesC(val, conf)
+
+	var actual Data
+	err := Parse(src, &actual, TypesOf(conf, val))
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+
+	// NaN isn't equal to itself, which rules out "reflect.DeepEqual".
+	if out := BytesC(actual, conf); string(out) != string(src) {
+		t.Fatalf("round-trip mismatch:\nexpected: %s\nactual: %s", src, out)
+	}
+
+	err = Parse([]byte(`math.NaN()`), new(string), nil)
+	if err == nil {
+		t.Fatalf("expected an error for a type mismatch")
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	var out test.AbiType
+
+	err := Parse([]byte(`test.AbiType{Missing: 1}`), &out, TypesOf(Default, out))
+	if err == nil {
+		t.Fatalf("expected an error for an unknown field")
+	}
+
+	err = Parse([]byte(`test.AbiParam{}`), &out, TypesOf(Default, test.AbiParam{}))
+	if err == nil {
+		t.Fatalf("expected an error for a type mismatch")
+	}
+
+	err = Parse([]byte(`test.AbiType{}`), out, nil)
+	if err == nil {
+		t.Fatalf("expected an error for a non-pointer output")
+	}
+}
+
+func TestParseMalformed(t *testing.T) {
+	type Private struct {
+		Name  string
+		count int
+		Extra int
+	}
+
+	for _, src := range []string{
+		`1/0`,
+		`1.5/0.0`,
+		`1 + "one"`,
+		`"one" - "two"`,
+		`-"one"`,
+		`!1`,
+		`^1.5`,
+		`-true`,
+		`map[[]int]int{}`,
+		`map[func()]int{}`,
+		`repr.Private{"one", 2, 3}`,
+	} {
+		var val interface{}
+		err := Parse([]byte(src), &val, TypesOf(Default, Private{}))
+		if err == nil {
+			t.Fatalf(`expected an error for %v, got %#v`, src, val)
+		}
+	}
+}
+
+func TestParseMaxLen(t *testing.T) {
+	var list []int
+	err := Parse([]byte(`[]int{1000000000000: 1}`), &list, nil)
+	if err == nil {
+		t.Fatalf("expected an error for an excessive index")
+	}
+
+	var val interface{}
+	err = Parse([]byte(`[1000000000000]int{}`), &val, nil)
+	if err == nil {
+		t.Fatalf("expected an error for an excessive array length")
+	}
+
+	err = Parse([]byte(`[]int{1023: 1}`), &list, nil)
+	if err != nil || len(list) != 1024 || list[1023] != 1 {
+		t.Fatalf("unexpected result for a valid index: %v", err)
+	}
+}
@@ -0,0 +1,200 @@
+package repr
+
+import (
+	"reflect"
+	"strconv"
+)
+
+// True if values must track their paths. See "Config.PathOverrides" and the
+// hooks which receive paths.
+func (self Config) tracksPaths() bool {
+	return len(self.PathOverrides) > 0 || self.OverrideFunc != nil || self.filtersPaths() ||
+		self.ConstName != nil || self.FieldName != nil || self.FieldValue != nil || self.SortKey != nil
+}
+
+// See "Config.Include" and "Config.Exclude".
+func (self Config) filtersPaths() bool {
+	return len(self.Include) > 0 || len(self.Exclude) > 0
+}
+
+// True if values must track their paths, either for the config or for
+// warnings collected by "Format".
+func (self fmter) tracksPaths() bool {
+	return self.conf.tracksPaths() || (self.state != nil && self.state.paths)
+}
+
+func (self fmter) atField(name string) fmter {
+	if self.tracksPaths() {
+		self.path += `.` + name
+	}
+	return self
+}
+
+func (self fmter) atIndex(index int) fmter {
+	if self.tracksPaths() {
+		self.path += `[` + strconv.Itoa(index) + `]`
+	}
+	return self
+}
+
+func (self fmter) atKey(key reflect.Value) fmter {
+	if self.tracksPaths() {
+		self.path += keySegment(key, *self.conf)
+	}
+	return self
+}
+
+// Map keys are formatted in single-line mode with elided types.
+func keySegment(key reflect.Value, conf Config) string {
+	conf.Indent = ``
+	conf.PathOverrides = nil
+	conf.OverrideFunc = nil
+	conf.Include = nil
+	conf.Exclude = nil
+	keyFmter := fmter{conf: &conf, elideType: true}
+	return `[` + string(appendAny(nil, key.Interface(), keyFmter)) + `]`
+}
+
+// See "Config.PathOverrides" and "Config.OverrideFunc".
+func (self fmter) override(rval reflect.Value) (string, bool) {
+	if !self.conf.tracksPaths() {
+		return ``, false
+	}
+	if expr, ok := self.conf.PathOverrides[self.path]; ok {
+		return expr, true
+	}
+	if self.conf.OverrideFunc != nil {
+		return self.conf.OverrideFunc(self.path, rval)
+	}
+	return ``, false
+}
+
+// Reports whether the value at the current path should be printed. See
+// "Config.Include" and "Config.Exclude".
+func (self fmter) visible() bool {
+	if !self.conf.filtersPaths() {
+		return true
+	}
+
+	segments := splitPath(self.path)
+
+	for _, pattern := range self.conf.Exclude {
+		if matchPath(splitPath(pattern), segments, false) {
+			return false
+		}
+	}
+
+	if len(self.conf.Include) == 0 {
+		return true
+	}
+	for _, pattern := range self.conf.Include {
+		if matchPath(splitPath(pattern), segments, true) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+Matches path segments against pattern segments, where ".*" and "[*]" match any
+field or any index or key respectively. Paths under a matching path also
+match. With "ancestors", paths leading to a potential match also match.
+*/
+func matchPath(pattern, path []string, ancestors bool) bool {
+	if len(path) < len(pattern) && !ancestors {
+		return false
+	}
+
+	for i, seg := range pattern {
+		if i >= len(path) {
+			return true
+		}
+		if !matchSegment(seg, path[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchSegment(pattern, seg string) bool {
+	switch pattern {
+	case `.*`:
+		return len(seg) > 0 && seg[0] == '.'
+	case `[*]`:
+		return len(seg) > 0 && seg[0] == '['
+	default:
+		return pattern == seg
+	}
+}
+
+/*
+Splits a path into segments such as ".Name" or "[10]". Brackets in map keys
+are balanced, and string and rune literals are skipped, so keys such as
+"["a].b"]" remain a single segment.
+*/
+func splitPath(path string) []string {
+	var out []string
+	start := 0
+
+	for i := 0; i < len(path); {
+		char := path[i]
+
+		if i > start && (char == '.' || char == '[') {
+			out = append(out, path[start:i])
+			start = i
+		}
+
+		if char == '[' {
+			i = skipBrackets(path, i)
+			continue
+		}
+		i++
+	}
+
+	if start < len(path) {
+		out = append(out, path[start:])
+	}
+	return out
+}
+
+// Returns the index after the bracketed expression starting at the given
+// index, or the length of the path if the brackets are unbalanced.
+func skipBrackets(path string, i int) int {
+	depth := 0
+
+	for i < len(path) {
+		switch char := path[i]; char {
+		case '[', '{', '(':
+			depth++
+		case ']', '}', ')':
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		case '"', '\'', '`':
+			i = skipQuoted(path, i)
+			continue
+		}
+		i++
+	}
+	return i
+}
+
+// Returns the index after the quoted literal starting at the given index.
+func skipQuoted(path string, i int) int {
+	quote := path[i]
+	i++
+
+	for i < len(path) {
+		char := path[i]
+		if char == '\\' && quote != '`' {
+			i += 2
+			continue
+		}
+		i++
+		if char == quote {
+			break
+		}
+	}
+	return i
+}
@@ -0,0 +1,64 @@
+package repr
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mitranim/repr/test"
+)
+
+func TestSplitPath(t *testing.T) {
+	check := func(path string, expected ...string) {
+		t.Helper()
+		actual := splitPath(path)
+		if !reflect.DeepEqual(actual, expected) {
+			t.Fatalf("expected segments:\n%#v\nactual segments:\n%#v", expected, actual)
+		}
+	}
+
+	check(``)
+	check(`.One`, `.One`)
+	check(`.One[2].Three`, `.One`, `[2]`, `.Three`)
+	check(`.Index["a].b"].Elems[0]`, `.Index`, `["a].b"]`, `.Elems`, `[0]`)
+	check(`[{X: 1, Y: "]"}][']']`, `[{X: 1, Y: "]"}]`, `[']']`)
+	check(".*[*][`\\`]", `.*`, `[*]`, "[`\\`]")
+}
+
+func TestIncludeExclude(t *testing.T) {
+	val := test.AbiFunction{
+		Name: `transfer`,
+		Inputs: []test.AbiParam{
+			{Name: `to`, Type: `address`, AbiType: test.AbiType{Kind: test.AbiKindAddress}},
+			{Name: `amount`, Type: `uint256`, AbiType: test.AbiType{Kind: test.AbiKindUint}},
+		},
+	}
+
+	check := func(conf Config, expected string) {
+		t.Helper()
+		actual := StringC(val, conf)
+		if actual != expected {
+			t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+		}
+	}
+
+	conf := CompactConfig
+	conf.Include = []string{`.Inputs[*].Name`}
+	check(conf, `test.AbiFunction{Inputs: []test.AbiParam{{Name: "to"}, {Name: "amount"}}}`)
+
+	conf = CompactConfig
+	conf.Exclude = []string{`.*[*].AbiType`, `.Name`}
+	check(conf, `test.AbiFunction{Inputs: []test.AbiParam{{Name: "to", Type: "address"}, {Name: "amount", Type: "uint256"}}}`)
+
+	conf = CompactConfig
+	conf.Include = []string{`.Inputs`}
+	conf.Exclude = []string{`.Inputs[1]`, `.Inputs[*].Type`}
+	check(conf, `test.AbiFunction{Inputs: []test.AbiParam{{Name: "to", AbiType: test.AbiType{Kind: 4}}, {}}}`)
+
+	conf = CompactConfig
+	conf.Exclude = []string{`["two"]`}
+	actual := StringC(map[string]int{`one`: 1, `two`: 2}, conf)
+	expected := `map[string]int{"one": 1}`
+	if actual != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+	}
+}
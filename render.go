@@ -0,0 +1,306 @@
+package repr
+
+import (
+	"go/scanner"
+	"go/token"
+	"os"
+)
+
+/*
+Classifies fragments of the output for syntax highlighting. See "Tokenize".
+*/
+type TokenKind byte
+
+const (
+	TokenSpace   TokenKind = iota // Whitespace, including newlines.
+	TokenPunct                    // Operators, delimiters and braces.
+	TokenType                     // Type names, including package qualifiers.
+	TokenField                    // Field names in struct literals.
+	TokenString                   // String and rune literals.
+	TokenNumber                   // Numeric literals.
+	TokenConst                    // Predeclared constants: "nil", "true", "false".
+	TokenKeyword                  // Keywords such as "map" or "func".
+	TokenComment                  // Comments.
+)
+
+// Returns a short lowercase name such as "type" or "string".
+func (self TokenKind) String() string {
+	switch self {
+	case TokenSpace:
+		return `space`
+	case TokenPunct:
+		return `punct`
+	case TokenType:
+		return `type`
+	case TokenField:
+		return `field`
+	case TokenString:
+		return `string`
+	case TokenNumber:
+		return `number`
+	case TokenConst:
+		return `const`
+	case TokenKeyword:
+		return `keyword`
+	case TokenComment:
+		return `comment`
+	default:
+		return `unknown`
+	}
+}
+
+/*
+Splits Go code, typically produced by this package, into classified fragments,
+invoking the callback for each. Concatenating the fragments reproduces the
+input exactly. Used for syntax highlighting; see "Renderer".
+*/
+func Tokenize(src []byte, fun func(TokenKind, []byte)) {
+	type tok struct {
+		pos int
+		tok token.Token
+		lit string
+	}
+
+	fset := token.NewFileSet()
+	file := fset.AddFile(``, -1, len(src))
+
+	var scan scanner.Scanner
+	scan.Init(file, src, func(token.Position, string) {}, scanner.ScanComments)
+
+	var toks []tok
+	for {
+		pos, kind, lit := scan.Scan()
+		if kind == token.EOF {
+			break
+		}
+		// Skip automatically inserted semicolons, which have no source text.
+		if kind == token.SEMICOLON && lit == "\n" {
+			continue
+		}
+		toks = append(toks, tok{file.Offset(pos), kind, lit})
+	}
+
+	cursor := 0
+	for i, val := range toks {
+		if val.pos > cursor {
+			fun(TokenSpace, src[cursor:val.pos])
+		}
+
+		var next token.Token
+		if i+1 < len(toks) {
+			next = toks[i+1].tok
+		}
+
+		end := val.pos + len(val.tok.String())
+		if val.lit != `` {
+			end = val.pos + len(val.lit)
+		}
+		if end > len(src) {
+			end = len(src)
+		}
+
+		fun(tokenKind(val.tok, val.lit, next), src[val.pos:end])
+		cursor = end
+	}
+
+	if cursor < len(src) {
+		fun(TokenSpace, src[cursor:])
+	}
+}
+
+func tokenKind(tok token.Token, lit string, next token.Token) TokenKind {
+	switch {
+	case tok == token.IDENT:
+		switch lit {
+		case `nil`, `true`, `false`:
+			return TokenConst
+		}
+		if next == token.COLON {
+			return TokenField
+		}
+		return TokenType
+	case tok == token.STRING, tok == token.CHAR:
+		return TokenString
+	case tok == token.INT, tok == token.FLOAT, tok == token.IMAG:
+		return TokenNumber
+	case tok == token.COMMENT:
+		return TokenComment
+	case tok.IsKeyword():
+		return TokenKeyword
+	default:
+		return TokenPunct
+	}
+}
+
+/*
+Backend for rendering formatted output, receiving classified fragments of code.
+Backends such as "PlainRenderer", "AnsiRenderer" and "HTMLRenderer" share the
+same traversal and tokenization, and differ only in how fragments are emitted.
+See "Render" and "RenderC".
+*/
+type Renderer interface {
+	Token(kind TokenKind, text []byte)
+}
+
+/*
+Adapter that allows an ordinary function to be used as a "Renderer".
+*/
+type RendererFunc func(TokenKind, []byte)
+
+// Implements "Renderer" by calling itself.
+func (self RendererFunc) Token(kind TokenKind, text []byte) { self(kind, text) }
+
+/*
+Feeds Go code, typically produced by this package, to the renderer, one
+fragment at a time. See "Tokenize".
+*/
+func Render(src []byte, renderer Renderer) {
+	Tokenize(src, renderer.Token)
+}
+
+/*
+Formats the value using the provided config, feeding the output to the
+renderer. Short for "Render(BytesC(val, conf), renderer)".
+*/
+func RenderC(val interface{}, conf Config, renderer Renderer) {
+	Render(BytesC(val, conf), renderer)
+}
+
+/*
+"Renderer" that reproduces the code as-is, appending it to "Buf".
+*/
+type PlainRenderer struct{ Buf []byte }
+
+// Implements "Renderer".
+func (self *PlainRenderer) Token(_ TokenKind, text []byte) {
+	self.Buf = append(self.Buf, text...)
+}
+
+/*
+Maps token kinds to ANSI escape sequences used by "AnsiRenderer". Kinds
+missing from the palette are left uncolored.
+*/
+type Palette map[TokenKind]string
+
+/*
+Palette used by "AnsiRenderer" when none is provided.
+*/
+var DefaultPalette = Palette{
+	TokenType:    "\x1b[36m", // cyan
+	TokenField:   "\x1b[34m", // blue
+	TokenString:  "\x1b[32m", // green
+	TokenNumber:  "\x1b[33m", // yellow
+	TokenConst:   "\x1b[35m", // magenta
+	TokenKeyword: "\x1b[35m", // magenta
+	TokenComment: "\x1b[90m", // gray
+}
+
+const ansiReset = "\x1b[0m"
+
+/*
+"Renderer" that highlights code with ANSI escape sequences for terminal output,
+appending it to "Buf". A nil palette means "DefaultPalette".
+*/
+type AnsiRenderer struct {
+	Palette Palette
+	Buf     []byte
+}
+
+// Implements "Renderer".
+func (self *AnsiRenderer) Token(kind TokenKind, text []byte) {
+	palette := self.Palette
+	if palette == nil {
+		palette = DefaultPalette
+	}
+
+	color := palette[kind]
+	if color == `` {
+		self.Buf = append(self.Buf, text...)
+		return
+	}
+	self.Buf = append(self.Buf, color...)
+	self.Buf = append(self.Buf, text...)
+	self.Buf = append(self.Buf, ansiReset...)
+}
+
+/*
+Highlights Go code, typically produced by this package, with ANSI escape
+sequences for terminal output. This is a separate pass over the output, which
+keeps regular formatting free of its overhead. A nil palette means
+"DefaultPalette". See "AnsiRenderer".
+*/
+func Colorize(src []byte, palette Palette) []byte {
+	renderer := AnsiRenderer{Palette: palette, Buf: make([]byte, 0, len(src)*2)}
+	Render(src, &renderer)
+	return renderer.Buf
+}
+
+/*
+"Renderer" that converts code to HTML, appending it to "Buf". Every fragment
+other than whitespace and punctuation is wrapped in a span with a CSS class
+named after its kind, such as "repr-type" or "repr-string". See "TokenKind".
+Text is HTML-escaped.
+*/
+type HTMLRenderer struct{ Buf []byte }
+
+// Implements "Renderer".
+func (self *HTMLRenderer) Token(kind TokenKind, text []byte) {
+	if kind == TokenSpace || kind == TokenPunct {
+		self.Buf = appendHTMLEscaped(self.Buf, text)
+		return
+	}
+	self.Buf = append(self.Buf, `<span class="repr-`...)
+	self.Buf = append(self.Buf, kind.String()...)
+	self.Buf = append(self.Buf, `">`...)
+	self.Buf = appendHTMLEscaped(self.Buf, text)
+	self.Buf = append(self.Buf, `</span>`...)
+}
+
+/*
+Renders Go code, typically produced by this package, as HTML for embedding in
+web pages and reports. The output is a "<pre class="repr">" element containing
+the output of "HTMLRenderer".
+*/
+func HTML(src []byte) []byte {
+	renderer := HTMLRenderer{Buf: make([]byte, 0, len(src)*3)}
+	renderer.Buf = append(renderer.Buf, `<pre class="repr">`...)
+	Render(src, &renderer)
+	return append(renderer.Buf, `</pre>`...)
+}
+
+func appendHTMLEscaped(out []byte, text []byte) []byte {
+	for _, char := range text {
+		switch char {
+		case '<':
+			out = append(out, `&lt;`...)
+		case '>':
+			out = append(out, `&gt;`...)
+		case '&':
+			out = append(out, `&amp;`...)
+		case '"':
+			out = append(out, `&#34;`...)
+		case '\'':
+			out = append(out, `&#39;`...)
+		default:
+			out = append(out, char)
+		}
+	}
+	return out
+}
+
+/*
+Similar to "PrintlnC", but highlights the output with "DefaultPalette" when
+stdout is a terminal.
+*/
+func PrintlnColor(val interface{}, conf Config) (int, error) {
+	out := BytesC(val, conf)
+	if isTerminal(os.Stdout) {
+		out = Colorize(out, nil)
+	}
+	return os.Stdout.Write(append(out, '\n'))
+}
+
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
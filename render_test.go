@@ -0,0 +1,95 @@
+package repr
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mitranim/repr/test"
+)
+
+func TestTokenize(t *testing.T) {
+	src := String(testStructure)
+
+	var buf []byte
+	Tokenize([]byte(src), func(_ TokenKind, text []byte) { buf = append(buf, text...) })
+	if string(buf) != src {
+		t.Fatalf("concatenated tokens don't reproduce the input:\n%s", buf)
+	}
+
+	var kinds []TokenKind
+	var texts []string
+	Tokenize([]byte(`map[string]*test.AbiType{"one": &{Kind: 2, Elem: nil}} /* c */`), func(kind TokenKind, text []byte) {
+		if kind != TokenSpace {
+			kinds = append(kinds, kind)
+			texts = append(texts, string(text))
+		}
+	})
+
+	expected := map[string]TokenKind{
+		`map`:     TokenKeyword,
+		`string`:  TokenType,
+		`test`:    TokenType,
+		`AbiType`: TokenType,
+		`"one"`:   TokenString,
+		`Kind`:    TokenField,
+		`2`:       TokenNumber,
+		`nil`:     TokenConst,
+		`{`:       TokenPunct,
+		`/* c */`: TokenComment,
+	}
+	for i, text := range texts {
+		kind, ok := expected[text]
+		if ok && kind != kinds[i] {
+			t.Fatalf("expected %q to be classified as %v, got %v", text, kind, kinds[i])
+		}
+	}
+}
+
+func TestColorize(t *testing.T) {
+	actual := string(Colorize([]byte(StringC(test.AbiType{Type: "bool"}, Config{})), nil))
+	expected := "\x1b[36mtest\x1b[0m.\x1b[36mAbiType\x1b[0m{\x1b[34mType\x1b[0m: \x1b[32m\"bool\"\x1b[0m}"
+	if actual != expected {
+		t.Fatalf("expected output:\n%q\nactual output:\n%q", expected, actual)
+	}
+
+	actual = string(Colorize([]byte(`1`), Palette{}))
+	if actual != `1` {
+		t.Fatalf("expected an empty palette to leave the output uncolored, got %q", actual)
+	}
+
+	actual = string(Colorize([]byte(`"a"`), Palette{TokenString: "<s>"}))
+	if !strings.Contains(actual, "<s>\"a\""+ansiReset) {
+		t.Fatalf("expected a custom palette to be used")
+	}
+}
+
+func TestHTML(t *testing.T) {
+	src := StringC(map[string]*test.AbiType{"<a&b>": {Kind: 2}}, Config{})
+
+	actual := string(HTML([]byte(src)))
+	expected := `<pre class="repr"><span class="repr-keyword">map</span>[<span class="repr-type">string</span>]*<span class="repr-type">test</span>.<span class="repr-type">AbiType</span>{<span class="repr-string">&#34;&lt;a&amp;b&gt;&#34;</span>: {<span class="repr-field">Kind</span>: <span class="repr-number">2</span>}}</pre>`
+	if actual != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+	}
+}
+
+func TestRenderC(t *testing.T) {
+	val := test.AbiType{Type: "bool", Kind: test.AbiKindBool}
+	conf := Default
+
+	var plain PlainRenderer
+	RenderC(val, conf, &plain)
+	if string(plain.Buf) != StringC(val, conf) {
+		t.Fatalf("expected plain renderer to reproduce the output, got:\n%s", plain.Buf)
+	}
+
+	var fields []string
+	RenderC(val, conf, RendererFunc(func(kind TokenKind, text []byte) {
+		if kind == TokenField {
+			fields = append(fields, string(text))
+		}
+	}))
+	if strings.Join(fields, ` `) != `Type Kind` {
+		t.Fatalf("unexpected fields: %q", fields)
+	}
+}
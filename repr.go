@@ -26,6 +26,9 @@ Features
 
 Supports single-line and multiline modes. Defaults to multiline.
 
+Besides "Default", provides presets for common use cases: "DebugConfig",
+"CodegenConfig" and "CompactConfig".
+
 The output looks like something you'd write by hand, and is almost exactly
 compliant with gofmt. Unlike gofmt, it doesn't align field values in struct
 literals. Use "go/format" to fix that, at a 50x performance cost:
@@ -54,18 +57,27 @@ per row:
 Supports package renaming, which is useful for code generation. See Config for
 details.
 
+Output can be parsed back into values of known types, which allows round-trip
+tests. See "Parse" for details.
+
+Output for a given "Config.OutputVersion" is stable across library upgrades;
+changes to formatting heuristics only apply to newer versions. Pin a version
+to keep golden files and generated code byte-identical when upgrading.
+
 Limitations
 
 Some of these limitations may be lifted in future versions.
 
 • Fancy types such as "big.Int" or "time.Time" are printed as empty structs;
-ideally they would be printed as constructor calls.
+ideally they would be printed as constructor calls. See "Config.AnnotateOpaque"
+for making this visible.
 
-• Funcs are treated as nil.
+• Funcs are treated as nil, unless "Config.FuncNames" is set.
 
 • Chans are treated as nil.
 
-• Pointers to primitive types are not supported and cause a panic.
+• Pointers to primitive types are not supported and cause a panic, unless
+"Config.PtrFunc" is set.
 
 • "byte" is printed as "uint8".
 
@@ -73,17 +85,26 @@ ideally they would be printed as constructor calls.
 
 • Runes are printed as integers, not character literals.
 
-• Enum-style constants are not mapped back to identifers.
+• Enum-style constants are not mapped back to identifiers, unless
+"Config.ConstName" is set. Reflection alone can't find constant names; the
+"github.com/mitranim/repr/source" module provides a hook backed by the source
+code of the defining packages.
 
 • On structs, only exported fields are included.
 
-• Cyclic structures cause infinite recursion.
+• References to values which contain them, such as in cyclic linked lists or
+trees with parent pointers, are printed as nil with a comment.
+
+• Values of types which can't be referenced by other packages, such as types
+from internal packages of the standard library or cgo-generated types, are
+printed as "nil" with a comment naming the type.
 
 • Doesn't support `fmt.GoStringer` yet.
 
 Note: pointers to composite types such as structs, arrays, slices and maps are
 supported by prefixing literals with "&", but Go currently doesn't support this
-for primitive literals.
+for primitive literals. Where the type of a literal is elided, such as for
+elements of "[]*T", the "&" is elided along with it.
 
 Installation
 
@@ -119,9 +140,20 @@ Example:
 package repr
 
 import (
+	"bytes"
+	"crypto/sha256"
 	"fmt"
+	"go/parser"
+	"io"
+	"math"
+	"math/big"
 	"reflect"
+	"regexp"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
 	"unsafe"
 )
 
@@ -147,15 +179,153 @@ type Config struct {
 		nilable = nil
 		array   = every byte is 0
 		struct  = every byte is 0
+
+	Types registered in "ZeroChecks", or with a method "IsZero() bool", use
+	those instead.
 	*/
 	ZeroFields bool
 
+	/**
+	If true, and "ZeroFields" is set, zero fields are printed after non-zero
+	fields, separated by a blank line in multiline mode, which makes the
+	meaningful content of exhaustive dumps easier to scan.
+	*/
+	ZeroFieldsLast bool
+
+	/**
+	In multiline mode, composite map keys, such as structs and arrays, are
+	printed on a single line if their single-line form takes at most this many
+	bytes, and on multiple lines otherwise, with the value following the
+	closing brace of the key:
+
+		map[Point]string{
+			{X: 1, Y: 2}: "short",
+			{
+				X: 1234567890,
+				Y: 1234567890,
+				Label: "long label",
+			}: "long",
+		}
+
+	0 (default) means that composite keys are laid out like other values.
+	*/
+	InlineKeyLen int
+
+	/**
+	Types whose values are never printed, useful for pruning backpointers to
+	huge parent objects, loggers, database handles and other noise. Struct
+	fields holding such values are omitted. Elsewhere, such values are printed
+	as "nil" with a comment naming the type. Types must match exactly:
+	"reflect.TypeOf((*sql.DB)(nil))" matches "*sql.DB" but not "sql.DB".
+	*/
+	SkipTypes []reflect.Type
+
+	/**
+	Maps types to fixed expressions printed verbatim instead of their values,
+	such as "db" or "testLogger()". Useful for generating fixtures which
+	reference known variables or singletons, rather than printing their
+	internals. Doesn't apply to nil values. Types must match exactly, as in
+	"SkipTypes". Imports required by the expressions are not tracked.
+	*/
+	TypeExpr map[reflect.Type]string
+
+	/**
+	Maps paths of values to expressions printed verbatim at those positions,
+	for cases where one deeply nested value needs hand-written code while the
+	rest is generated. Paths start at the root value, which has an empty path,
+	and consist of struct field names, list indexes and map keys, where keys
+	are formatted like in single-line mode, with elided types:
+
+		.Inputs[2].AbiType
+		.Index["key"].Elems[0]
+
+	Pointers and interfaces don't add path segments. Struct fields with
+	overrides are printed even if zero.
+	*/
+	PathOverrides map[string]string
+
+	/**
+	If non-nil, called for every value with its path, as described in
+	"PathOverrides", which takes priority. Returning true replaces the value
+	with the returned expression, like "PathOverrides". Useful for rules which
+	depend on both the location and the value, such as masking every field
+	named "Password" regardless of nesting:
+
+		conf.OverrideFunc = func(path string, val reflect.Value) (string, bool) {
+			if strings.HasSuffix(path, ".Password") {
+				return `"***"`, true
+			}
+			return "", false
+		}
+
+	May be called more than once for the same struct field, which is also
+	checked before deciding whether to omit the field as zero.
+	*/
+	OverrideFunc func(path string, val reflect.Value) (string, bool)
+
+	/**
+	Path patterns for focusing the output on a part of a large structure. If
+	non-empty, only values at matching paths are printed, along with their
+	ancestors and descendants. Paths are described in "PathOverrides".
+	Patterns use the same syntax, where ".*" matches any field and "[*]"
+	matches any index or key:
+
+		.Inputs[*].Name
+
+	Applies to struct fields and map entries; lists are printed with all
+	elements, whose own fields are filtered.
+	*/
+	Include []string
+
+	/**
+	Path patterns for values which are never printed, with the same syntax and
+	scope as "Include". Takes priority over "Include":
+
+		.*.Selector
+		.Inputs[*].AbiType.Elem
+	*/
+	Exclude []string
+
+	/**
+	If true, structs without exported fields, which are printed as empty
+	literals, are followed by a comment with the number of omitted fields,
+	which makes the loss of their state visible:
+
+		pkg.Handle{} /* opaque: 2 unexported fields *\/
+
+	Regardless of this option, such structs are reported in "Result.Warnings".
+	*/
+	AnnotateOpaque bool
+
+	/**
+	If true, non-nil funcs which point to exported top-level functions are
+	printed as references to them, such as "strings.ToUpper". Other non-nil
+	funcs are printed as nil, followed by a comment describing them, which
+	retains at least the definition site of closures:
+
+		nil /* func(int) error closure defined at handlers.go:123 *\/
+		nil /* method value (*pkg.Server).Handle *\/
+
+	If false (default), all funcs are printed as nil.
+	*/
+	FuncNames bool
+
 	/**
 	If true, always print constructor names for elements in arrays and slices. If
 	false (default), elide them wherever possible.
 	*/
 	ForceConstructorName bool
 
+	/**
+	If true, map keys of named non-composite types are always printed with
+	conversions, even where the map type implies them: "map[ID]int{ID("a"): 1}"
+	instead of "map[ID]int{"a": 1}". Useful for output searched by type name.
+	Regardless of this setting, keys of interface types are printed with
+	conversions wherever their literals would have different types, since
+	otherwise distinct keys such as "3" and "int64(3)" would collide.
+	*/
+	KeyConversions bool
+
 	/**
 	Maps fully-qualified packages to short aliases. Useful for code generation.
 	An empty string causes the package name to be stripped. The default config
@@ -170,522 +340,3030 @@ type Config struct {
 		}
 	*/
 	PackageMap map[string]string
-}
 
-func (self Config) SingleLine() bool { return self.Indent == `` }
+	/**
+	If true, the output is parsed via "go/parser" before being returned, and an
+	error is reported if it's not a syntactically valid Go expression. The *E
+	functions such as "BytesE" return the error, while other functions panic.
+	Useful when generating code from arbitrary runtime data.
+	*/
+	Validate bool
 
-/*
-Global/default settings. Used by functions like "String". Custom configs can be
-passed to functions like "StringC".
-*/
-var Default = Config{
-	Indent:     "\t",
-	PackageMap: map[string]string{`main`: ``},
-}
+	/**
+	If true, "Format" records the byte range of every printed value in
+	"Result.Positions", keyed by path. Useful for editor integrations which
+	highlight or fold parts of the output without parsing it. Doesn't affect
+	the output.
+	*/
+	Positions bool
 
-/*
-Formats the value using the "Default" config. See "Config" for details.
-*/
-func String(val interface{}) string {
-	return bytesToMutableString(appendAny(nil, val, fmter{conf: Default}))
-}
+	/**
+	If true, map entries are sorted by the code representing their keys, making
+	the output deterministic. If false (default), entries follow Go's randomized
+	map order.
+
+	Keys of a type with a method "Less(T) bool", where "T" is the key type, are
+	sorted via that method. Otherwise, numeric keys are sorted numerically,
+	string keys lexicographically, and "false" goes before "true". Float keys
+	have -0 before +0 and NaN keys last. Keys of other types, such as structs,
+	are sorted by the code representing them. Entries whose keys can't be
+	ordered otherwise, such as NaN keys, are ordered by the code representing
+	their values. See "SortKey" for customizing the order.
+	*/
+	SortKeys bool
 
-/*
-Short for "String with config". Formats the value using the provided config. See
-"Config" for details.
-*/
-func StringC(val interface{}, conf Config) string {
-	return bytesToMutableString(appendAny(nil, val, fmter{conf: conf}))
-}
+	/**
+	If non-nil and "SortKeys" is set, called for every map key, with the path
+	of the map as described in "PathOverrides", to obtain the value used to
+	order map entries, instead of the key itself. For example, for keys of a
+	struct type, it may return one of the fields. Results are ordered like
+	keys, see "SortKeys". Results of different types, or nil, are considered
+	equivalent, falling back on the code representing the keys.
+	*/
+	SortKey func(path string, key interface{}) interface{}
 
-/*
-Formats the value using the "Default" config. See "Config" for details.
-*/
-func Bytes(val interface{}) []byte {
-	return appendAny(nil, val, fmter{conf: Default})
-}
+	/**
+	If true, maps are printed as slices of key-value pairs, in the order of
+	their entries, making the order explicit in generated code:
+
+		[]struct {
+			Key   string
+			Value int
+		}{
+			{Key: "one", Value: 10},
+			{Key: "two", Value: 20},
+		}
 
-/*
-"Short for "Bytes with config". Formats the value using the provided config. See
-"Config" for details.
-*/
-func BytesC(val interface{}, conf Config) []byte {
-	return appendAny(nil, val, fmter{conf: conf})
-}
+	Combine with "SortKeys" for reproducible output. The output is no longer
+	assignable to the map type. See "MapPairsFunc".
+	*/
+	MapPairs bool
 
-/*
-Formats the value using the "Default" config, appending the output to the
-provided buffer. See "Config" for details.
-*/
-func Append(out []byte, val interface{}) []byte {
-	return appendAny(nil, val, fmter{conf: Default})
-}
+	/**
+	If non-empty and "MapPairs" is set, the slice of pairs is wrapped in a call
+	to the function with this name, such as "newRoutes([]struct{...}{...})",
+	which is expected to build the map or an equivalent structure.
+	*/
+	MapPairsFunc string
 
-/*
-Short for "Append with config". Formats the value using the provided config,
-appending the output to the provided buffer. See "Config" for details.
-*/
-func AppendC(out []byte, val interface{}, conf Config) []byte {
-	return appendAny(out, val, fmter{conf: conf})
-}
+	/**
+	If true, nil pointers, funcs and channels stored in interfaces, such as
+	fields of type "error" or elements of "[]interface{}", are printed with their
+	type, such as "(*pkg.T)(nil)", while nil interfaces are printed as "nil".
+	Such "typed nils" are not equal to nil, which is a common source of bugs.
+	If false (default), both are printed as "nil". Nil slices and maps always
+	include their type in such positions.
+	*/
+	TypedNils bool
 
-/*
-Shortcut for `fmt.Println(repr.String(val))`.
-*/
-func Println(val interface{}) (int, error) {
-	return fmt.Println(String(val))
-}
+	/**
+	Line separator for multiline output. Defaults to "\n" if empty. Use "\r\n"
+	for CRLF line endings.
+	*/
+	Newline string
 
-/*
-Shortcut for `fmt.Println(repr.StringC(val, conf))`.
-*/
-func PrintlnC(val interface{}, conf Config) (int, error) {
-	return fmt.Println(StringC(val, conf))
-}
+	/**
+	Separator between keys and values in struct and map literals. Defaults to
+	": " if empty. Must be a colon with optional surrounding spaces, such as ":"
+	or " : ". Other separators are rejected, since they would make the output
+	invalid Go.
+	*/
+	Colon string
 
-var (
-	byteType = reflect.TypeOf((*byte)(nil)).Elem()
-)
+	/**
+	If true, non-empty composite literals printed on a single line have spaces
+	inside their braces: "{ 1, 2 }" rather than "{1, 2}".
+	*/
+	BraceSpace bool
 
-type fmter struct {
-	conf      Config
-	indent    int
-	elideType bool
-}
+	/**
+	Prepended to every line of the output, including the first. For example,
+	"// " makes the output suitable for embedding in Go comments. Only applies
+	to functions formatting a single value, such as "String"; code generation
+	helpers such as "Decls" ignore it.
+	*/
+	LinePrefix string
 
-func appendAny(out []byte, val interface{}, fmter fmter) []byte {
-	impl, _ := val.(fmt.GoStringer)
-	if impl != nil {
-		return append(out, impl.GoString()...)
-	}
+	/**
+	If true, values implementing "fmt.Stringer" are annotated with a comment
+	containing the result of their "String" method, such as
+	"Kind: 2 /* AbiKindUint *\/". Useful for debugging.
+	*/
+	Stringers bool
 
-	// Well-known types
-	switch val := val.(type) {
-	case bool:
-		if val {
-			return append(out, `true`...)
-		}
-		return append(out, `false`...)
-	case uint8: // = byte
-		return appendByteHex(out, val)
-	case uint16:
-		return strconv.AppendUint(out, uint64(val), 10)
-	case uint32:
-		return strconv.AppendUint(out, uint64(val), 10)
-	case uint64:
-		return strconv.AppendUint(out, uint64(val), 10)
-	case uint:
-		return strconv.AppendUint(out, uint64(val), 10)
-	case uintptr:
-		return strconv.AppendUint(append(out, '0', 'x'), uint64(val), 16)
-	case unsafe.Pointer:
-		return strconv.AppendUint(append(out, '0', 'x'), uint64(uintptr(val)), 16)
-	case int8:
-		return strconv.AppendInt(out, int64(val), 10)
-	case int16:
-		return strconv.AppendInt(out, int64(val), 10)
-	case int32: // = rune
-		return strconv.AppendInt(out, int64(val), 10)
-	case int64:
-		return strconv.AppendInt(out, int64(val), 10)
-	case int:
-		return strconv.AppendInt(out, int64(val), 10)
-	case float32:
-		return strconv.AppendFloat(out, float64(val), 'f', -1, 32)
-	case float64:
-		return strconv.AppendFloat(out, float64(val), 'f', -1, 64)
-	case complex64:
-		return appendComplex128(out, complex128(val))
-	case complex128:
-		return appendComplex128(out, val)
-	case string:
-		return strconv.AppendQuote(out, val)
-	case []byte:
-		if !fmter.elideType {
-			out = append(out, `[]uint8`...)
-		}
-		out = appendBytes(out, val, fmter)
-		return out
-	}
+	/**
+	If positive, composite literals nested deeper than this are printed as
+	"{/* depth limit *\/}".
+	*/
+	MaxDepth int
 
-	rval := reflect.ValueOf(val)
-	if !rval.IsValid() {
-		out = append(out, `nil`...)
-		return out
-	}
+	/**
+	If positive, arrays, slices and maps print at most this many elements,
+	followed by a comment such as "/* 10 more elements *\/". Truncated output is
+	still syntactically valid, but no longer equivalent to the original value.
+	Truncated maps are sorted as if "SortKeys" was set, retaining the first
+	entries in that order, which keeps the output deterministic.
+	*/
+	MaxElems int
 
-	rtype := rval.Type()
+	/**
+	If positive, arrays and slices truncated due to "MaxElems" also keep this
+	many last elements, following a comment with the number of omitted
+	elements, since the tail of a time series or a log is often the
+	interesting part:
 
-	switch rtype.Kind() {
-	case reflect.Bool:
-		out = appendCastPrefix(out, rval, fmter)
-		if rval.Bool() {
-			out = append(out, `true`...)
-		} else {
-			out = append(out, `false`...)
-		}
-		out = appendCastSuffix(out, rval, fmter)
+		[]int{0, 1, 2, /* … 95 elements omitted … *\/ 98, 99}
 
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		out = appendCastPrefix(out, rval, fmter)
-		out = strconv.AppendInt(out, rval.Int(), 10)
-		out = appendCastSuffix(out, rval, fmter)
+	Doesn't apply to maps, or to lists printed with index keys.
+	*/
+	TailElems int
 
-	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		out = appendCastPrefix(out, rval, fmter)
-		out = strconv.AppendUint(out, rval.Uint(), 10)
-		out = appendCastSuffix(out, rval, fmter)
+	/**
+	If positive, strings longer than this many bytes are truncated at a UTF-8
+	character boundary and followed by a comment with the original length, such
+	as "/* 4096 bytes total *\/".
+	*/
+	MaxStringLen int
 
-	case reflect.Uintptr:
-		out = appendCastPrefix(out, rval, fmter)
-		out = strconv.AppendUint(append(out, '0', 'x'), rval.Uint(), 16)
-		out = appendCastSuffix(out, rval, fmter)
+	/**
+	If true, strings truncated due to "MaxStringLen" keep both the start and
+	the end, each taking half of the limit, joined by concatenation with a gap
+	marker. Useful for long URLs and tokens, which are usually identified by
+	their ends:
 
-	case reflect.Float32:
-		out = appendCastPrefix(out, rval, fmter)
-		out = strconv.AppendFloat(out, rval.Float(), 'f', -1, 32)
-		out = appendCastSuffix(out, rval, fmter)
+		"https://exa" + /* … *\/ "/file.txt" /* 4096 bytes total *\/
+	*/
+	TruncateMiddle bool
 
-	case reflect.Float64:
-		out = appendCastPrefix(out, rval, fmter)
-		out = strconv.AppendFloat(out, rval.Float(), 'f', -1, 64)
-		out = appendCastSuffix(out, rval, fmter)
+	/**
+	If non-nil, called for every composite literal with its nesting depth,
+	starting at 0 for the outermost literal, and the config of the enclosing
+	literal. The returned config applies to the literal and its contents, until
+	overridden again at a deeper level. For example, to print the first two
+	levels multiline and everything below single-line:
+
+		conf.ByDepth = func(depth int, conf repr.Config) repr.Config {
+			if depth >= 2 {
+				conf.Indent = ""
+			}
+			return conf
+		}
+	*/
+	ByDepth func(depth int, conf Config) Config
 
-	case reflect.Complex64, reflect.Complex128:
-		out = appendCastPrefix(out, rval, fmter)
-		out = appendComplex128(out, rval.Convert(reflect.TypeOf(complex128(0))).Complex())
-		out = appendCastSuffix(out, rval, fmter)
+	/**
+	Values per row for multiline arrays and slices of unsigned integers, keyed
+	by element kind. Supports "reflect.Uint16", "reflect.Uint32" and
+	"reflect.Uint64", whose values are then printed as hex, zero-padded to the
+	width of the type, so that rows line up in columns:
+
+		[]uint32{
+			0x6a09e667, 0xbb67ae85, 0x3c6ef372, 0xa54ff53a,
+			0x510e527f, 0x9b05688c, 0x1f83d9ab, 0x5be0cd19,
+		}
 
-	case reflect.String:
-		out = appendCastPrefix(out, rval, fmter)
-		out = strconv.AppendQuote(out, rval.String())
-		out = appendCastSuffix(out, rval, fmter)
+	Also supports "reflect.Uint8", overriding the default of 8 bytes per row.
+	Elements with their own "GoString" method, or annotated via "Stringers",
+	are printed as usual.
+	*/
+	Columns map[reflect.Kind]int
 
-	case reflect.Chan:
-		out = appendCastPrefix(out, rval, fmter)
-		out = append(out, `nil`...)
-		out = appendCastSuffix(out, rval, fmter)
+	/**
+	Byte arrays and slices with at most this many bytes are printed on a single
+	line even in multiline mode, which keeps typical hashes compact in fixtures
+	while larger blobs wrap into rows. 0 (default) means the number of bytes
+	per row, which is 8 unless overridden via "Columns".
+	*/
+	InlineBytes int
 
-	case reflect.Func:
-		out = appendCastPrefix(out, rval, fmter)
-		out = append(out, `nil`...)
-		out = appendCastSuffix(out, rval, fmter)
+	/**
+	Optional function which splits byte arrays and slices printed on multiple
+	lines into segments, returning the offsets where segments start, in
+	ascending order. Each segment starts on a new row and is split into rows of
+	the usual length, so rows depend only on the contents of their segment.
+	Inserting or removing a byte then changes only the rows of one segment,
+	instead of shifting every subsequent row, which keeps diffs of binary
+	fixtures small. Offsets out of order or out of range are ignored. See
+	"ContentBreaks" for boundaries derived from the bytes themselves, which
+	suits arbitrary binary data. Not applied when "MaxElems" omits the middle
+	of the bytes, or when "ForceMultiline" is set.
+	*/
+	ByteBreaks func([]byte) []int
 
-	// Pretty sure this should never match
-	case reflect.Interface:
-		panic(`repr currently doesn't support printing an interface`)
+	/**
+	In multiline mode, arrays and slices with fewer than this many elements are
+	printed on a single line, if their elements can be inlined; see
+	"InlineType". 0 (default) means 48. Negative disables inlining.
+	*/
+	InlineElems int
 
-	case reflect.UnsafePointer:
-		out = appendCastPrefix(out, rval, fmter)
-		ptr := rval.Convert(reflect.TypeOf(unsafe.Pointer(nil))).Interface().(unsafe.Pointer)
-		out = strconv.AppendUint(append(out, '0', 'x'), uint64(uintptr(ptr)), 16)
-		out = appendCastSuffix(out, rval, fmter)
+	/**
+	Optional predicate for element types of arrays and slices which may be
+	printed on a single line; see "InlineElems". By default, elements of all
+	kinds except arrays, chans, funcs, interfaces, maps, slices, strings and
+	structs can be inlined.
+	*/
+	InlineType func(reflect.Type) bool
+
+	/**
+	If true, every composite literal is printed on multiple lines with one
+	element per line, including empty literals and short lists of numbers,
+	which makes the output maximally diff-friendly: adding an element is always
+	a one-line diff. Has no effect in single-line mode.
+	*/
+	ForceMultiline bool
+
+	/**
+	If true, structs whose exported fields all have primitive types, such as
+	numbers and strings, are printed on a single line in multiline mode, which
+	matches how nested fixtures are typically written by hand:
+
+		[]AbiParam{
+			{Name: "amount", Type: "uint256"},
+			{Name: "owner", Type: "address"},
+		}
+
+	Ignored when "ForceMultiline" is set.
+	*/
+	InlineLeafStructs bool
+
+	/**
+	If positive, maps whose keys and values have primitive types, such as
+	numbers and strings, are printed on a single line in multiline mode if their
+	single-line form takes at most this many bytes, which keeps small lookup
+	tables compact:
+
+		Config{
+			Limits: map[string]int{"max": 10, "min": 1},
+		}
+
+	The length is measured without the type, from "{" to "}". Ignored when
+	"ForceMultiline" is set.
+	*/
+	InlineMaps int
+
+	/**
+	If positive, composite literals in multiline mode are printed on a single
+	line if the entire line fits within this many columns, including the
+	indentation, the preceding text such as the field name, and the trailing
+	comma. Widths are measured before rendering, and measurement stops as soon
+	as the limit is exceeded, which keeps the cost proportional to the limit
+	rather than to the size of the value. Columns are counted in bytes, so a
+	tab in "Indent" counts as one column. Ignored when "ForceMultiline" is set.
+	*/
+	MaxWidth int
+
+	/**
+	Bundles layout heuristics for multiline mode into a single level, from 0
+	(default) to 3. Each level includes the previous ones:
+
+		0 - Default layout.
+		1 - Leaf structs on a single line, see "InlineLeafStructs". Byte lists
+		    of up to 16 bytes, such as typical hashes, on a single line.
+		2 - Lists of strings on a single line. Lists of primitives with fewer
+		    than 96 elements on a single line. Byte lists of up to 32 bytes on
+		    a single line.
+		3 - Lists of primitives and leaf structs on a single line regardless of
+		    length. Byte lists of up to 64 bytes on a single line, and 16 bytes
+		    per row otherwise.
+
+	Explicitly set fields such as "InlineElems", "InlineType", "InlineBytes"
+	and "Columns" take priority over the level. Ignored when "ForceMultiline"
+	is set.
+	*/
+	Compactness int
+
+	/**
+	If true, arrays and slices with at least 8 elements, of which at most a
+	quarter are non-zero, are printed with index keys, omitting zero elements:
+
+		[256]int{5: 7, 130: 9}
+
+	Slices also include their last element, preserving their length. Limits
+	such as "MaxElems" apply to the printed elements.
+	*/
+	Sparse bool
+
+	/**
+	If true, elements of arrays and slices are always printed with index keys,
+	which shows their positions at a glance: "[]string{0: "a", 1: "b"}".
+	Combined with "Sparse", sparse lists omit zero elements as usual.
+	*/
+	IndexKeys bool
+
+	/**
+	If true, numbers in positions where their type isn't implied, such as
+	elements of "[]interface{}" or values of "map[string]interface{}", are
+	wrapped in conversions whenever the type of the number differs from the
+	default type of its literal: "float64(1)", "int64(5)", "uint8(0xff)". This
+	preserves dynamic types when the output is compiled, for example for data
+	decoded from JSON, where all numbers are "float64".
+	*/
+	TypedNumbers bool
+
+	/**
+	Qualified name of a generic helper which returns a pointer to its argument,
+	such as "k8s.io/utils/ptr.To", used for printing pointers to non-composite
+	types, which have no literal form in Go:
+
+		ptr.To(10)
+		ptr.To(uint8(0xff))
+
+	The package name is derived from the import path, respecting "PackageMap",
+	and the package is reported in imports. A name without a path refers to the
+	current package. Pointers to composite types are still printed as "&T{}".
+	If empty (default), pointers to non-composite types cause a panic.
+	*/
+	PtrFunc string
+
+	/**
+	Optional hook for printing values of named non-composite types, such as
+	enums, as identifiers of constants instead of literals. Called with the
+	path of the value, as described in "PathOverrides", and values whose type
+	is named and whose kind is a boolean, number or string. A
+	non-empty result is treated as the name of a constant declared in the
+	package of the value's type, and is qualified like type names, respecting
+	"PackageMap": "test.AbiKindUint" instead of "0x02". Takes priority over
+	"GoString" methods. Reflection alone can't find constant names; see the
+	"github.com/mitranim/repr/source" module for a hook backed by the source
+	code of the defining packages.
+	*/
+	ConstName func(path string, val interface{}) string
+
+	/**
+	Optional hook for transforming names of struct fields, for example to
+	print a literal of a parallel struct whose fields match by JSON tags. Called
+	for every exported field which is about to be printed, with the path of the
+	field as described in "PathOverrides". Returning an empty string omits the
+	field.
+	*/
+	FieldName func(path string, field reflect.StructField) string
+
+	/**
+	Optional hook for substituting values of struct fields, for example to
+	normalize timestamps or replace random IDs with placeholders, without
+	modifying the original value. Called for every exported field with the
+	path of the field as described in "PathOverrides", the type of the struct,
+	the field, and its value. If the second result is
+	true, the first result is printed instead of the field value, and is
+	subject to the usual rules, such as omitting zero values. A nil
+	replacement is treated as the zero value of the field type.
+	*/
+	FieldValue func(path string, owner reflect.Type, field reflect.StructField, val reflect.Value) (interface{}, bool)
+
+	/**
+	Patterns for masking secrets, such as API keys, bearer tokens or emails,
+	embedded in strings. Every match in every printed string, including map
+	keys, values of named string types and comments added by "Stringers", is
+	replaced with "***" before applying "MaxStringLen". Doesn't apply to output
+	of "GoString" methods or to byte slices.
+	*/
+	Redact []*regexp.Regexp
+
+	/**
+	If positive, strings and byte slices longer than this many bytes are
+	replaced with empty placeholders, followed by a comment with the length and
+	the SHA-256 prefix of the content, which identifies the blob without
+	printing it:
+
+		[]uint8(nil) /* 4.2 MiB, sha256:ab12cd34ef56ab78… *\/
+
+	Takes priority over "MaxStringLen" and "Redact".
+	*/
+	DigestLen int
+
+	/**
+	Controls printing of non-zero "uintptr" and "unsafe.Pointer" values, which
+	typically hold memory addresses and make the output irreproducible. See
+	"AddressPolicy". By default, addresses are printed as hex numbers.
+	*/
+	Addresses AddressPolicy
+
+	/**
+	Controls struct fields holding empty composites, see "EmptyPolicy". By
+	default, zero structs are omitted unless "ZeroFields" is set, while empty
+	slices and maps are printed as literals. "EmptyKeep" also prints zero
+	structs. "EmptyNil" treats empty slices and maps as nil, omitting them
+	unless "ZeroFields" is set. "EmptyOmit" omits all empty composites, even
+	with "ZeroFields".
+	*/
+	EmptyFields EmptyPolicy
+
+	/**
+	Controls elements of arrays and slices holding empty composites, see
+	"EmptyPolicy". By default, they're printed as literals. Elements can't be
+	omitted without shifting the following elements, so "EmptyOmit" is the same
+	as "EmptyNil".
+	*/
+	EmptyElems EmptyPolicy
+
+	/**
+	Controls map values holding empty composites, see "EmptyPolicy". By
+	default, they're printed as literals. "EmptyOmit" omits such entries.
+	*/
+	EmptyMapValues EmptyPolicy
+
+	/**
+	Version of formatting heuristics. For a given version, the output for any
+	value and the rest of the config is byte-identical across library upgrades,
+	except for bug fixes that turn invalid output into valid code. Changes to
+	formatting apply only to newer versions. 0 (default) means
+	"LatestOutputVersion", which may change between upgrades. Functions such as
+	"BytesE" and "File.Bytes" return an error for unknown versions, while
+	others panic.
+	*/
+	OutputVersion int
+
+	/**
+	Oldest version of Go which must compile the output, such as "go1.18" or
+	"1.18", usually the "go" directive of the target module. Newer syntax is
+	used only where this version allows it: "any" instead of "interface {}"
+	requires "go1.18". Output which can't be expressed in this version, such as
+	calls of a generic "PtrFunc" or names of instantiated generic types before
+	"go1.18", is an error for functions such as "BytesE", and a panic for
+	others. Empty (default) means the oldest syntax without any checks.
+
+	Other syntax newer than "go1.13" is not currently emitted, regardless of
+	this version: "[N]byte(str)" conversions of strings to arrays, underscores
+	in number literals, and hexadecimal float literals.
+	*/
+	LangVersion string
+
+	/**
+	If true, functions such as "String" and "Bytes" format into a buffer taken
+	from an internal pool, then copy the result, instead of growing a fresh
+	buffer. This reduces garbage when formatting many values, at the cost of a
+	copy. Doesn't affect functions such as "Append" which use the provided
+	buffer.
+	*/
+	Pooled bool
+}
+
+func (self Config) SingleLine() bool { return self.Indent == `` }
+
+/*
+Versions for "Config.OutputVersion". New versions are added when formatting
+heuristics change.
+*/
+const (
+	OutputV1 = 1
+
+	LatestOutputVersion = OutputV1
+)
+
+/*
+Policies for "Config.Addresses", applied to values of kinds "uintptr" and
+"unsafe.Pointer", including named types.
+*/
+type AddressPolicy byte
+
+const (
+	AddressShow        AddressPolicy = iota // Print as hex numbers, such as "0xc000012345".
+	AddressPlaceholder                      // Print as zero with a comment, such as "nil /* address */".
+	AddressOmit                             // Omit struct fields, print as zero elsewhere.
+)
+
+/*
+Policies for empty composites: slices and maps which are empty but not nil, and
+structs whose fields are all zero. Used for "Config.EmptyFields",
+"Config.EmptyElems" and "Config.EmptyMapValues", which allows to control each
+context separately. Zero structs have no nil form, and are printed as literals
+unless omitted.
+*/
+type EmptyPolicy byte
+
+const (
+	EmptyDefault EmptyPolicy = iota // Depends on the context, see the fields of "Config".
+	EmptyKeep                       // Print as literals, such as "[]int{}" or "T{}".
+	EmptyNil                        // Print empty slices and maps as nil.
+	EmptyOmit                       // Omit struct fields and map entries, print as nil elsewhere.
+)
+
+// Replaces an empty non-nil slice or map with nil, according to the policy.
+func (self EmptyPolicy) value(rval reflect.Value) reflect.Value {
+	if self >= EmptyNil && isEmptyCollection(rval) {
+		return reflect.Zero(rval.Type())
+	}
+	return rval
+}
+
+// True for empty values to which "EmptyPolicy" applies.
+func isEmptyComposite(rval reflect.Value) bool {
+	if rval.Kind() == reflect.Struct {
+		return isZeroOrShouldOmit(rval)
+	}
+	return isEmptyCollection(rval)
+}
+
+func isEmptyCollection(rval reflect.Value) bool {
+	switch rval.Kind() {
+	case reflect.Slice, reflect.Map:
+		return !rval.IsNil() && rval.Len() == 0
+	default:
+		return false
+	}
+}
+
+// Value of the element in the output. See "Config.EmptyElems".
+func (self fmter) elemValue(rval reflect.Value) reflect.Value {
+	return self.conf.EmptyElems.value(rval)
+}
+
+// Resolves "Config.OutputVersion". New formatting heuristics should be gated
+// via "conf.outputVersion() >= OutputVN".
+func (self Config) outputVersion() int {
+	if self.OutputVersion == 0 {
+		return LatestOutputVersion
+	}
+	return self.OutputVersion
+}
+
+/*
+Minor version of Go 1 from "Config.LangVersion", or 0 if unspecified. Also
+accepts patch versions and pre-release suffixes: "go1.21.0", "go1.21rc1".
+*/
+func (self Config) langVersion() (int, error) {
+	str := strings.TrimPrefix(self.LangVersion, `go`)
+	if str == `` {
+		return 0, nil
+	}
+
+	if !strings.HasPrefix(str, `1.`) {
+		return 0, fmt.Errorf(`repr: invalid language version %q`, self.LangVersion)
+	}
+	str = str[len(`1.`):]
+
+	end := 0
+	for end < len(str) && str[end] >= '0' && str[end] <= '9' {
+		end++
+	}
+	minor, err := strconv.Atoi(str[:end])
+	if err != nil {
+		return 0, fmt.Errorf(`repr: invalid language version %q`, self.LangVersion)
+	}
+	return minor, nil
+}
+
+// True if "Config.LangVersion" is specified and allows syntax introduced in
+// the given minor version of Go 1. Invalid versions are reported by
+// "checkLangVersion".
+func (self Config) langAtLeast(minor int) bool {
+	ver, _ := self.langVersion()
+	return ver >= minor
+}
+
+// True if "Config.LangVersion" is specified and predates the given minor
+// version of Go 1.
+func (self Config) langBelow(minor int) bool {
+	ver, _ := self.langVersion()
+	return ver > 0 && ver < minor
+}
+
+// Reports invalid settings. Used by "AppendE" and "Decls" before formatting.
+func (self Config) check() error {
+	if self.OutputVersion < 0 || self.OutputVersion > LatestOutputVersion {
+		return fmt.Errorf(`repr: unsupported output version %v`, self.OutputVersion)
+	}
+	if !isValidColon(self.Colon) {
+		return fmt.Errorf(`repr: invalid key-value separator %q: expected ":" with optional spaces`, self.Colon)
+	}
+	return self.checkLangVersion()
+}
+
+// Reports settings which require a newer version than "Config.LangVersion".
+func (self Config) checkLangVersion() error {
+	ver, err := self.langVersion()
+	if err != nil || ver == 0 {
+		return err
+	}
+	if self.PtrFunc != `` && ver < 18 {
+		return fmt.Errorf(`repr: "PtrFunc" requires generics, available since go1.18, but the language version is %q`, self.LangVersion)
+	}
+	return nil
+}
+
+/*
+Global/default settings. Used by functions like "String". Custom configs can be
+passed to functions like "StringC".
+*/
+var Default = Config{
+	Indent:     "\t",
+	PackageMap: map[string]string{`main`: ``},
+}
+
+/*
+Preset for debug output: multiline, with sorted maps, annotations of
+"fmt.Stringer" values, and limits that keep dumps of large structures
+readable. The output is meant for humans and may not be equivalent to the
+original value.
+*/
+var DebugConfig = Config{
+	Indent:       "\t",
+	PackageMap:   map[string]string{`main`: ``},
+	SortKeys:     true,
+	Stringers:    true,
+	MaxDepth:     32,
+	MaxElems:     128,
+	MaxStringLen: 1024,
+}
+
+/*
+Preset for code generation: multiline, with sorted maps for reproducible
+output, and validation of the output. Types referenced by the output are
+tracked as imports when used with "File".
+*/
+var CodegenConfig = Config{
+	Indent:     "\t",
+	PackageMap: map[string]string{`main`: ``},
+	SortKeys:   true,
+	Validate:   true,
+}
+
+/*
+Preset for compact output: single-line, with sorted maps, zero fields omitted
+and constructor names elided wherever possible.
+*/
+var CompactConfig = Config{
+	PackageMap: map[string]string{`main`: ``},
+	SortKeys:   true,
+}
+
+/*
+Formats the value using the "Default" config. See "Config" for details.
+*/
+func String(val interface{}) string {
+	return StringC(val, Default)
+}
+
+/*
+Short for "String with config". Formats the value using the provided config. See
+"Config" for details.
+*/
+func StringC(val interface{}, conf Config) string {
+	return bytesToMutableString(BytesC(val, conf))
+}
+
+/*
+Short for "String with error". Formats the value using the provided config,
+returning an error if validation is enabled and fails. See "Config.Validate".
+*/
+func StringE(val interface{}, conf Config) (string, error) {
+	out, err := BytesE(val, conf)
+	return bytesToMutableString(out), err
+}
+
+/*
+Formats the value using the "Default" config. See "Config" for details.
+*/
+func Bytes(val interface{}) []byte {
+	return BytesC(val, Default)
+}
+
+/*
+"Short for "Bytes with config". Formats the value using the provided config. See
+"Config" for details.
+*/
+func BytesC(val interface{}, conf Config) []byte {
+	out, err := BytesE(val, conf)
+	if err != nil {
+		panic(err)
+	}
+	return out
+}
+
+/*
+Short for "Bytes with error". Formats the value using the provided config,
+returning an error if validation is enabled and fails. See "Config.Validate".
+*/
+func BytesE(val interface{}, conf Config) ([]byte, error) {
+	if !conf.Pooled {
+		return AppendE(nil, val, conf)
+	}
+
+	buf := bufPool.Get().(*[]byte)
+	defer putBuffer(buf)
+
+	out, err := AppendE((*buf)[:0], val, conf)
+	*buf = out
+	return append(make([]byte, 0, len(out)), out...), err
+}
+
+var bufPool = sync.Pool{New: func() interface{} { return new([]byte) }}
+
+// Large buffers are dropped rather than pooled, to avoid retaining memory after
+// formatting an occasional huge value.
+func putBuffer(buf *[]byte) {
+	if cap(*buf) <= 1<<16 {
+		bufPool.Put(buf)
+	}
+}
+
+/*
+Formats the value using the "Default" config, appending the output to the
+provided buffer. See "Config" for details.
+*/
+func Append(out []byte, val interface{}) []byte {
+	return AppendC(out, val, Default)
+}
+
+/*
+Short for "Append with config". Formats the value using the provided config,
+appending the output to the provided buffer. See "Config" for details. Panics
+if validation is enabled and fails; see "AppendE" for a non-panicking version.
+*/
+func AppendC(out []byte, val interface{}, conf Config) []byte {
+	out, err := AppendE(out, val, conf)
+	if err != nil {
+		panic(err)
+	}
+	return out
+}
+
+/*
+Short for "Append with error". Formats the value using the provided config,
+appending the output to the provided buffer. Returns an error if validation is
+enabled and fails, if the output version is unknown, or if the output can't be
+expressed in the language version. See "Config.Validate",
+"Config.OutputVersion" and "Config.LangVersion".
+*/
+func AppendE(out []byte, val interface{}, conf Config) ([]byte, error) {
+	return appendE(out, val, conf, nil)
+}
+
+func appendE(out []byte, val interface{}, conf Config, state *state) (res []byte, err error) {
+	if err := conf.check(); err != nil {
+		return out, err
+	}
+	if conf.langBelow(18) {
+		prev := out
+		defer func() {
+			if val := recover(); val != nil {
+				res, err = prev, langVersionErr(val)
+			}
+		}()
+	}
+
+	start := len(out)
+	out = append(out, conf.LinePrefix...)
+	out = appendRoot(out, val, fmter{conf: &conf, state: state})
+	if conf.Validate {
+		return out, validate(stripLinePrefix(out[start:], conf))
+	}
+	return out, nil
+}
+
+/*
+Paths aren't tracked by default, which would slow down formatting. If the value
+turns out to be unsupported, it's formatted again with path tracking, so that
+the panic message locates the unsupported part. See "unsupportedError".
+*/
+func appendRoot(out []byte, val interface{}, fmter fmter) []byte {
+	defer func() {
+		err := recover()
+		if err == nil {
+			return
+		}
+		if _, ok := err.(unsupportedError); ok && !fmter.tracksPaths() {
+			fmter.state = &state{paths: true}
+			appendAny(nil, val, fmter)
+		}
+		panic(err)
+	}()
+	return appendAny(out, val, fmter)
+}
+
+/*
+Panic value for output which can't be expressed in "Config.LangVersion", found
+during formatting. Converted to an error by "appendE".
+*/
+type langVersionError struct{ error }
+
+// Unwraps a recovered "langVersionError", re-panicking with anything else.
+func langVersionErr(val interface{}) error {
+	err, ok := val.(langVersionError)
+	if !ok {
+		panic(val)
+	}
+	return err.error
+}
+
+/*
+Panic value for unsupported input. The message includes the path of the value,
+if tracked, and the innermost struct type containing it, which makes the
+problem findable in large structures:
+
+	repr currently doesn't support pointers to non-composite types: *int at .Inputs[2].Value in test.AbiParam
+*/
+type unsupportedError struct {
+	msg   string
+	rtype reflect.Type
+	path  string
+	owner reflect.Type
+}
+
+// Implements "error".
+func (self unsupportedError) Error() string {
+	out := `repr currently doesn't support ` + self.msg + `: ` + self.rtype.String()
+	if self.path != `` {
+		out += ` at ` + self.path
+	}
+	if self.owner != nil {
+		out += ` in ` + self.owner.String()
+	}
+	return out
+}
+
+func (self fmter) unsupported(rtype reflect.Type, msg string) unsupportedError {
+	return unsupportedError{msg: msg, rtype: rtype, path: self.path, owner: self.owner}
+}
+
+/*
+Shortcut for `fmt.Println(repr.String(val))`.
+*/
+func Println(val interface{}) (int, error) {
+	return fmt.Println(String(val))
+}
+
+/*
+Shortcut for `fmt.Println(repr.StringC(val, conf))`.
+*/
+func PrintlnC(val interface{}, conf Config) (int, error) {
+	return fmt.Println(StringC(val, conf))
+}
+
+/*
+Formats the value using the provided config, as a sequence of "//" line
+comments. Useful for documenting the data used by generated code. Overrides
+"Config.LinePrefix".
+*/
+func Comment(val interface{}, conf Config) string {
+	conf.LinePrefix = `// `
+	return StringC(val, conf)
+}
+
+/*
+Formats the value using the provided config, wrapped in a "/* ... *\/" block
+comment. Every "*\/" sequence in the output is escaped as "\x2a/", preventing
+it from terminating the comment. Inside string literals, this keeps the
+literals equivalent. Comments added by repr, such as "/* 2 more elements *\/"
+or cycle markers, are escaped as well, and end with "\x2a/":
+
+	/* []int{1 /* 2 more elements \x2a/} *\/
+*/
+func BlockComment(val interface{}, conf Config) string {
+	conf.LinePrefix = ``
+	out := BytesC(val, conf)
+	out = bytes.Replace(out, []byte(`*/`), []byte(`\x2a/`), -1)
+
+	var buf []byte
+	buf = append(buf, `/*`...)
+	if conf.SingleLine() {
+		buf = append(buf, ' ')
+	} else {
+		buf = append(buf, conf.newline()...)
+	}
+	buf = append(buf, out...)
+	if conf.SingleLine() {
+		buf = append(buf, ' ')
+	} else {
+		buf = append(buf, conf.newline()...)
+	}
+	buf = append(buf, `*/`...)
+	return bytesToMutableString(buf)
+}
+
+/*
+Formats the value using the provided config, wrapped in a Markdown code block
+with Go syntax highlighting. If the caption is non-empty, it's placed on its
+own line before the code block. Useful for posting dumps to issue trackers and
+chats. Backticks in the output, which can only occur inside string literals,
+are escaped as "\x60", which keeps the literals equivalent while preventing
+them from terminating the code block.
+*/
+func Markdown(val interface{}, caption string, conf Config) string {
+	conf.LinePrefix = ``
+	out := BytesC(val, conf)
+	out = bytes.Replace(out, []byte("`"), []byte(`\x60`), -1)
+
+	var buf []byte
+	if caption != `` {
+		buf = append(buf, caption...)
+		buf = append(buf, conf.newline()...)
+		buf = append(buf, conf.newline()...)
+	}
+	buf = append(buf, "```go"...)
+	buf = append(buf, conf.newline()...)
+	buf = append(buf, out...)
+	buf = append(buf, conf.newline()...)
+	buf = append(buf, "```"...)
+	return bytesToMutableString(buf)
+}
+
+var (
+	byteType       = reflect.TypeOf((*byte)(nil)).Elem()
+	float32Type    = reflect.TypeOf(float32(0))
+	float64Type    = reflect.TypeOf(float64(0))
+	complex64Type  = reflect.TypeOf(complex64(0))
+	complex128Type = reflect.TypeOf(complex128(0))
+	goStringerType = reflect.TypeOf((*fmt.GoStringer)(nil)).Elem()
+	stringType     = reflect.TypeOf(``)
+	stringerType   = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+)
+
+type fmter struct {
+	conf      *Config
+	indent    int
+	depth     int
+	elideType bool
+	path      string
+	state     *state
+
+	// Innermost struct type containing the value, for error messages.
+	owner reflect.Type
+
+	// Prevents replacing the value with a shared variable, see "Decls.Dedup".
+	// Applies only to the current value, not to its contents.
+	noShare bool
+
+	// References being printed by ancestors, see "visiting". Not part of
+	// "state", which is optional.
+	visiting visiting
+
+	// Nesting of the value in the current recursive pass, and the stack for
+	// deferring deeper values. See "valueStack".
+	nest  int
+	stack *valueStack
+}
+
+/*
+Mutable state shared by all fmters in a single formatting pass. Optional: nil
+unless the caller needs to collect information about the output.
+*/
+type state struct {
+	// Package paths referenced by the output, mapped to the names used for them.
+	imports map[string]string
+
+	stats    Stats
+	warnings []Warning
+	warned   map[string]bool
+	limits   []Limit
+
+	// Byte ranges of values, see "Config.Positions". Nil unless enabled.
+	positions map[string]Span
+
+	// Shared variables, see "Decls.Dedup". Nil unless enabled.
+	sharing *sharing
+
+	// Aliased pointers, see "Decls.PreserveAliasing". Nil unless enabled.
+	aliasing *aliasing
+
+	// Interned strings, see "Decls.InternStrings". Nil unless enabled.
+	interning *interning
+
+	// Stub functions, see "File.FuncStubs". Nil unless enabled.
+	stubs *funcStubs
+
+	// Enables path tracking for warnings, see "Warning.Path".
+	paths bool
+
+	// Maximum output length while measuring, see "fmter.measure".
+	measuring bool
+	limit     int
+
+	// Destination for incremental output, see "fmter.flush".
+	writer  io.Writer
+	written int64
+	err     error
+}
+
+// Large enough to amortize the cost of writes, and small enough for the
+// buffer to remain poolable.
+const flushSize = 1 << 15
+
+// Writes the output accumulated so far to "state.writer", if any, once it
+// exceeds "flushSize", returning the emptied buffer. Called from loops which
+// may produce unbounded output, such as "appendRows". Callers must not rely on
+// the buffer retaining previous output. Output with deferred values is kept
+// until they're spliced in, see "valueStack".
+func (self fmter) flush(out []byte) []byte {
+	if self.state == nil || self.state.writer == nil || len(out) < flushSize || self.state.err != nil || self.stack != nil {
+		return out
+	}
+	size, err := self.state.writer.Write(out)
+	self.state.written += int64(size)
+	self.state.err = err
+	return out[:0]
+}
+
+// Records a warning about a value of the given type, once per message. The type
+// may be nil for warnings about limits.
+func (self fmter) warn(rtype reflect.Type, msg string) {
+	if self.state == nil {
+		return
+	}
+
+	if rtype != nil {
+		msg = rtype.String() + `: ` + msg
+	}
+	if self.state.warned[msg] {
+		return
+	}
+	if self.state.warned == nil {
+		self.state.warned = map[string]bool{}
+	}
+	self.state.warned[msg] = true
+	self.state.warnings = append(self.state.warnings, Warning{Message: msg, Path: self.path})
+}
+
+// Records a limit applied to the current value. See "Result.Limits".
+func (self fmter) limited(kind LimitKind, size, omitted int) {
+	if self.state != nil {
+		self.state.limits = append(self.state.limits, Limit{
+			Kind: kind, Path: self.path, Size: size, Omitted: omitted,
+		})
+	}
+}
+
+func (self fmter) addImport(path, name string) {
+	if self.state == nil {
+		return
+	}
+	if self.state.imports == nil {
+		self.state.imports = map[string]string{}
+	}
+	self.state.imports[path] = name
+}
+
+func appendAny(out []byte, val interface{}, fmter fmter) []byte {
+	if fmter.nest >= maxNest && fmter.defers() {
+		return appendNested(out, val, fmter)
+	}
+	fmter.nest++
+
+	if fmter.state != nil {
+		fmter.state.stats.Values++
+		fmter.state.checkLimit(out)
+		if fmter.state.positions != nil {
+			start := len(out)
+			out = appendAnyBody(out, val, fmter)
+			fmter.state.positions[fmter.path] = Span{start, len(out)}
+			return out
+		}
+	}
+	return appendAnyBody(out, val, fmter)
+}
+
+// Like "appendAny", without the bookkeeping of "state".
+func appendAnyBody(out []byte, val interface{}, fmter fmter) []byte {
+	if expr, ok := fmter.override(reflect.ValueOf(val)); ok {
+		return append(out, expr...)
+	}
+	if fmter.state != nil && (fmter.state.sharing != nil || fmter.state.aliasing != nil || fmter.state.interning != nil) {
+		if name, ok := fmter.sharedName(val); ok {
+			return append(out, name...)
+		}
+		fmter.noShare = false
+	}
+	out = appendValue(out, val, fmter)
+	if fmter.conf.Stringers {
+		out = appendStringerComment(out, val, fmter)
+	}
+	return out
+}
+
+func appendValue(out []byte, val interface{}, fmter fmter) []byte {
+	if len(fmter.conf.TypeExpr) > 0 {
+		if expr, ok := fmter.conf.TypeExpr[reflect.TypeOf(val)]; ok && !isNil(reflect.ValueOf(val)) {
+			return append(out, expr...)
+		}
+	}
+
+	if len(fmter.conf.SkipTypes) > 0 {
+		if rtype := reflect.TypeOf(val); rtype != nil && fmter.conf.skipsType(rtype) {
+			return appendPlaceholder(out, `skipped `+rtype.String())
+		}
+	}
+
+	if fmter.conf.ConstName != nil {
+		rtype := reflect.TypeOf(val)
+		if rtype != nil && rtype.Name() != `` && rtype.PkgPath() != `` && isPrimitive(rtype) {
+			name := fmter.conf.ConstName(fmter.path, val)
+			if name != `` {
+				return appendQualified(out, rtype, name, fmter)
+			}
+		}
+	}
+
+	impl, _ := val.(fmt.GoStringer)
+	if impl != nil {
+		return append(out, impl.GoString()...)
+	}
+
+	if rtype := reflect.TypeOf(val); rtype != nil {
+		if opaque := opaqueType(rtype); opaque != nil {
+			return appendOpaque(out, rtype, opaque, fmter)
+		}
+	}
+
+	if fmter.conf.TypedNumbers && !fmter.elideType {
+		if name := numberConversion(val); name != `` {
+			fmter.elideType = true
+			out = append(out, name...)
+			out = append(out, '(')
+			out = appendValue(out, val, fmter)
+			return append(out, ')')
+		}
+	}
+
+	if fmter.conf.DigestLen > 0 {
+		if rval := reflect.ValueOf(val); isDigestable(rval, fmter.conf.DigestLen) {
+			return appendDigest(out, rval, fmter)
+		}
+	}
+
+	if fmter.conf.Addresses != AddressShow {
+		if rval := reflect.ValueOf(val); isAddressKind(rval.Kind()) && !isZeroOrShouldOmit(rval) {
+			return appendHiddenAddress(out, rval, fmter)
+		}
+	}
+
+	// Well-known types
+	switch val := val.(type) {
+	case bool:
+		if val {
+			return append(out, `true`...)
+		}
+		return append(out, `false`...)
+	case uint8: // = byte
+		return appendByteHex(out, val)
+	case uint16:
+		return appendUint(out, uint64(val))
+	case uint32:
+		return appendUint(out, uint64(val))
+	case uint64:
+		return appendUint(out, uint64(val))
+	case uint:
+		return appendUint(out, uint64(val))
+	case uintptr:
+		return strconv.AppendUint(append(out, '0', 'x'), uint64(val), 16)
+	case unsafe.Pointer:
+		return strconv.AppendUint(append(out, '0', 'x'), uint64(uintptr(val)), 16)
+	case int8:
+		return appendInt(out, int64(val))
+	case int16:
+		return appendInt(out, int64(val))
+	case int32: // = rune
+		return appendInt(out, int64(val))
+	case int64:
+		return appendInt(out, int64(val))
+	case int:
+		return appendInt(out, int64(val))
+	case float32:
+		if !isLiteralFloat(float64(val)) {
+			return appendFloatCall(out, float64(val), float32Type, fmter)
+		}
+		return strconv.AppendFloat(out, float64(val), 'f', -1, 32)
+	case float64:
+		if !isLiteralFloat(val) {
+			return appendFloatCall(out, val, float64Type, fmter)
+		}
+		return strconv.AppendFloat(out, float64(val), 'f', -1, 64)
+	case complex64:
+		if !isLiteralComplex(complex128(val)) {
+			return appendComplexCall(out, complex128(val), complex64Type, fmter)
+		}
+		return appendComplex128(out, complex128(val))
+	case complex128:
+		if !isLiteralComplex(val) {
+			return appendComplexCall(out, val, complex128Type, fmter)
+		}
+		return appendComplex128(out, val)
+	case string:
+		return appendString(out, val, fmter)
+	case []byte:
+		if !fmter.elideType {
+			out = append(out, `[]uint8`...)
+		}
+		if rval := reflect.ValueOf(val); fmter.keyedIndexes(rval) != nil {
+			return appendList(out, rval, fmter)
+		}
+		out = appendBytes(out, val, fmter)
+		return out
+	}
+
+	rval := reflect.ValueOf(val)
+	if !rval.IsValid() {
+		out = append(out, `nil`...)
+		return out
+	}
+	if mayCycle(rval) {
+		return appendVisit(out, rval, fmter)
+	}
+	return appendReflect(out, rval, fmter)
+}
+
+// Formats a value by its kind, without the special cases of "appendValue".
+func appendReflect(out []byte, rval reflect.Value, fmter fmter) []byte {
+	rtype := rval.Type()
+
+	switch rtype.Kind() {
+	case reflect.Bool:
+		out = appendCastPrefix(out, rval, fmter)
+		if rval.Bool() {
+			out = append(out, `true`...)
+		} else {
+			out = append(out, `false`...)
+		}
+		out = appendCastSuffix(out, rval, fmter)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		out = appendCastPrefix(out, rval, fmter)
+		out = appendInt(out, rval.Int())
+		out = appendCastSuffix(out, rval, fmter)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		out = appendCastPrefix(out, rval, fmter)
+		out = appendUint(out, rval.Uint())
+		out = appendCastSuffix(out, rval, fmter)
+
+	case reflect.Uintptr:
+		out = appendCastPrefix(out, rval, fmter)
+		out = strconv.AppendUint(append(out, '0', 'x'), rval.Uint(), 16)
+		out = appendCastSuffix(out, rval, fmter)
+
+	case reflect.Float32, reflect.Float64:
+		if !isLiteralFloat(rval.Float()) {
+			out = appendFloatCall(out, rval.Float(), rtype, fmter)
+			break
+		}
+		out = appendCastPrefix(out, rval, fmter)
+		out = strconv.AppendFloat(out, rval.Float(), 'f', -1, rtype.Bits())
+		out = appendCastSuffix(out, rval, fmter)
+
+	case reflect.Complex64, reflect.Complex128:
+		if !isLiteralComplex(rval.Complex()) {
+			out = appendComplexCall(out, rval.Complex(), rtype, fmter)
+			break
+		}
+		out = appendCastPrefix(out, rval, fmter)
+		out = appendComplex128(out, rval.Complex())
+		out = appendCastSuffix(out, rval, fmter)
+
+	case reflect.String:
+		out = appendCastPrefix(out, rval, fmter)
+		out = appendString(out, rval.String(), fmter)
+		out = appendCastSuffix(out, rval, fmter)
+
+	case reflect.Chan, reflect.Func:
+		if rtype.Kind() == reflect.Func && !rval.IsNil() {
+			if fmter.conf.FuncNames {
+				out = appendFunc(out, rval, fmter)
+				break
+			}
+			if fmter.stubsFuncs() {
+				out = appendFuncStub(out, rval, fmter)
+				break
+			}
+		}
+		if !rval.IsNil() {
+			fmter.warn(rtype, `non-nil value is printed as nil`)
+		}
+		if fmter.elideType {
+			out = append(out, `nil`...)
+		} else {
+			out = appendTypedNil(out, rtype, fmter)
+		}
+
+	// Reachable via reflection-only traversal, such as elements of
+	// "[]interface{}" obtained via "reflect.Value.Index". The dynamic value is
+	// printed with its type, as if it had been passed directly. Values which
+	// can't be converted back to "interface{}", such as those reached through
+	// unexported fields, skip the special cases of "appendValue".
+	case reflect.Interface:
+		if rval.IsNil() {
+			out = append(out, `nil`...)
+			break
+		}
+		fmter.elideType = false
+		elem := rval.Elem()
+		if elem.CanInterface() {
+			out = appendValue(out, elem.Interface(), fmter)
+		} else {
+			out = appendReflect(out, elem, fmter)
+		}
+
+	case reflect.UnsafePointer:
+		out = appendCastPrefix(out, rval, fmter)
+		ptr := rval.Convert(reflect.TypeOf(unsafe.Pointer(nil))).Interface().(unsafe.Pointer)
+		out = strconv.AppendUint(append(out, '0', 'x'), uint64(uintptr(ptr)), 16)
+		out = appendCastSuffix(out, rval, fmter)
 
 	case reflect.Ptr:
+		if fmter.showTypedNil(rval) {
+			out = appendTypedNil(out, rtype, fmter)
+			break
+		}
 		switch rtype.Elem().Kind() {
 		case reflect.Array, reflect.Slice, reflect.Struct, reflect.Map:
 			if isZeroOrShouldOmit(rval) {
 				out = append(out, `nil`...)
 			} else {
-				out = append(out, '&')
+				// Where the type is elided, "&T" is elided as a whole.
+				if !fmter.elideType {
+					out = append(out, '&')
+				}
+				// Sharing the target would alias it.
+				fmter.noShare = true
 				out = appendAny(out, rval.Elem().Interface(), fmter)
 			}
 		default:
-			panic(`repr currently doesn't support pointers to non-composite types`)
+			// The helper would infer the dynamic type of an interface.
+			if fmter.conf.PtrFunc == `` || rtype.Elem().Kind() == reflect.Interface {
+				panic(fmter.unsupported(rtype, `pointers to non-composite types`))
+			}
+			if rval.IsNil() {
+				out = append(out, `nil`...)
+			} else {
+				out = appendPtrCall(out, rval, fmter)
+			}
+		}
+
+	case reflect.Array:
+		if !fmter.elideType {
+			out = appendTypeName(out, rval.Type(), fmter)
+		}
+		if rtype.Elem() == byteType && fmter.keyedIndexes(rval) == nil {
+			out = appendBytes(out, byteArrayToSlice(rval), fmter)
+		} else {
+			out = appendList(out, rval, fmter)
+		}
+
+	case reflect.Slice:
+		if rval.IsNil() {
+			if fmter.elideType {
+				out = append(out, `nil`...)
+			} else {
+				out = appendTypeName(out, rval.Type(), fmter)
+				out = append(out, `(nil)`...)
+			}
+		} else {
+			out = appendTypeName(out, rval.Type(), fmter)
+			if rtype.Elem() == byteType && fmter.keyedIndexes(rval) == nil {
+				out = appendBytes(out, rval.Bytes(), fmter)
+			} else {
+				out = appendList(out, rval, fmter)
+			}
+		}
+
+	case reflect.Struct:
+		if !fmter.elideType {
+			out = appendTypeName(out, rval.Type(), fmter)
+		}
+		out = appendStruct(out, rval, fmter)
+
+	case reflect.Map:
+		if fmter.conf.MapPairs {
+			out = appendMapPairs(out, rval, fmter)
+		} else if rval.IsNil() {
+			if fmter.elideType {
+				out = append(out, `nil`...)
+			} else {
+				out = appendTypeName(out, rval.Type(), fmter)
+				out = append(out, `(nil)`...)
+			}
+		} else {
+			out = appendTypeName(out, rval.Type(), fmter)
+			out = appendMap(out, rval, fmter)
+		}
+	}
+
+	return out
+}
+
+func appendString(out []byte, val string, fmter fmter) []byte {
+	val = fmter.redact(val)
+	limit := fmter.conf.MaxStringLen
+	if limit <= 0 || len(val) <= limit {
+		return strconv.AppendQuote(out, val)
+	}
+
+	kept := 0
+	if fmter.conf.TruncateMiddle {
+		head := runeBoundary(val, limit/2)
+		tail := len(val) - (limit - head)
+		for tail < len(val) && !utf8.RuneStart(val[tail]) {
+			tail++
+		}
+		out = strconv.AppendQuote(out, val[:head])
+		out = append(out, ` + /* … */ `...)
+		out = strconv.AppendQuote(out, val[tail:])
+		kept = head + len(val) - tail
+	} else {
+		kept = runeBoundary(val, limit)
+		out = strconv.AppendQuote(out, val[:kept])
+	}
+
+	if fmter.state != nil {
+		fmter.state.stats.TruncatedStrings++
+		fmter.warn(nil, `strings are truncated due to MaxStringLen`)
+		fmter.limited(LimitStringLen, len(val), len(val)-kept)
+	}
+
+	out = append(out, ` /* `...)
+	out = strconv.AppendInt(out, int64(len(val)), 10)
+	out = append(out, ` bytes total */`...)
+	return out
+}
+
+// Strings and non-nil byte slices exceeding the limit. See "Config.DigestLen".
+func isDigestable(rval reflect.Value, limit int) bool {
+	switch rval.Kind() {
+	case reflect.String:
+		return rval.Len() > limit
+	case reflect.Slice:
+		return rval.Type().Elem() == byteType && !rval.IsNil() && rval.Len() > limit
+	default:
+		return false
+	}
+}
+
+func appendDigest(out []byte, rval reflect.Value, fmter fmter) []byte {
+	if fmter.state != nil {
+		fmter.state.stats.Digested++
+		fmter.warn(rval.Type(), `content is replaced with a digest due to DigestLen`)
+		fmter.limited(LimitDigest, rval.Len(), rval.Len())
+	}
+
+	var content []byte
+	if rval.Kind() == reflect.String {
+		content = []byte(rval.String())
+		if rval.Type() == stringType {
+			out = append(out, `""`...)
+		} else {
+			out = appendCastPrefix(out, rval, fmter)
+			out = append(out, `""`...)
+			out = appendCastSuffix(out, rval, fmter)
+		}
+	} else {
+		content = rval.Bytes()
+		if fmter.elideType {
+			out = append(out, `nil`...)
+		} else {
+			out = appendTypeName(out, rval.Type(), fmter)
+			out = append(out, `(nil)`...)
+		}
+	}
+
+	sum := sha256.Sum256(content)
+	out = append(out, ` /* `...)
+	out = appendByteSize(out, len(content))
+	out = append(out, `, sha256:`...)
+	for _, char := range sum[:8] {
+		out = append(out, hexDigits[char>>4], hexDigits[char&0xf])
+	}
+	out = append(out, `… */`...)
+	return out
+}
+
+// Appends a human-readable size such as "512 B" or "4.2 MiB".
+func appendByteSize(out []byte, size int) []byte {
+	if size < 1024 {
+		out = strconv.AppendInt(out, int64(size), 10)
+		return append(out, ` B`...)
+	}
+
+	val := float64(size)
+	unit := 0
+	for val >= 1024 && unit < len(byteUnits)-1 {
+		val /= 1024
+		unit++
+	}
+	out = strconv.AppendFloat(out, val, 'f', 1, 64)
+	out = append(out, ' ')
+	return append(out, byteUnits[unit]...)
+}
+
+var byteUnits = []string{`B`, `KiB`, `MiB`, `GiB`, `TiB`}
+
+// Moves the index back to the nearest UTF-8 character boundary.
+func runeBoundary(val string, index int) int {
+	for index > 0 && index < len(val) && !utf8.RuneStart(val[index]) {
+		index--
+	}
+	return index
+}
+
+// Replacement for matches of "Config.Redact".
+const redactedMask = `***`
+
+func (self fmter) redact(val string) string {
+	for _, reg := range self.conf.Redact {
+		val = reg.ReplaceAllLiteralString(val, redactedMask)
+	}
+	return val
+}
+
+func appendStringerComment(out []byte, val interface{}, fmter fmter) []byte {
+	impl, _ := val.(fmt.Stringer)
+	if impl == nil || reflect.TypeOf(val).Kind() == reflect.Ptr {
+		return out
+	}
+	if _, ok := val.(fmt.GoStringer); ok {
+		return out
+	}
+
+	str, ok := safeString(impl)
+	if !ok || str == `` {
+		return out
+	}
+
+	out = append(out, ` /* `...)
+	out = appendCommentText(out, fmter.redact(str))
+	out = append(out, ` */`...)
+	return out
+}
+
+// Calls "String", recovering from panics such as nil dereferences.
+func safeString(impl fmt.Stringer) (out string, ok bool) {
+	defer func() {
+		if recover() != nil {
+			ok = false
+		}
+	}()
+	return impl.String(), true
+}
+
+// Appends text for a block comment, preventing it from breaking the comment or
+// the line.
+func appendCommentText(out []byte, text string) []byte {
+	for i := 0; i < len(text); i++ {
+		char := text[i]
+		switch {
+		case char == '\n' || char == '\r':
+			out = append(out, ' ')
+		case char == '*' && i+1 < len(text) && text[i+1] == '/':
+			out = append(out, '*', ' ')
+		default:
+			out = append(out, char)
+		}
+	}
+	return out
+}
+
+// Appends a comment about omitted elements, with a leading space.
+func appendOmitted(out []byte, count, total int, singular, plural string, fmter fmter) []byte {
+	if count <= 0 {
+		return out
+	}
+	return appendOmittedComment(append(out, ' '), count, total, singular, plural, fmter)
+}
+
+// The total is the original number of elements or entries in the collection.
+func appendOmittedComment(out []byte, count, total int, singular, plural string, fmter fmter) []byte {
+	if fmter.state != nil {
+		fmter.state.stats.Omitted += count
+		fmter.warn(nil, plural+` are omitted due to MaxElems`)
+		fmter.limited(LimitElems, total, count)
+	}
+
+	out = append(out, `/* `...)
+	out = strconv.AppendInt(out, int64(count), 10)
+	out = append(out, ` more `...)
+	if count == 1 {
+		out = append(out, singular...)
+	} else {
+		out = append(out, plural...)
+	}
+	out = append(out, ` */`...)
+	return out
+}
+
+func appendDepthLimit(out []byte, fmter fmter) []byte {
+	if fmter.state != nil {
+		fmter.state.stats.DepthLimited++
+		fmter.warn(nil, `values are omitted due to MaxDepth`)
+		fmter.limited(LimitDepth, 0, 0)
+	}
+	return append(out, `{/* depth limit */}`...)
+}
+
+// Formatters share the config by pointer, which keeps them cheap to copy for
+// every value. Must be called before modifying the config.
+func (self *fmter) ownConf() {
+	conf := *self.conf
+	self.conf = &conf
+}
+
+// Called when entering a composite literal. Applies "Config.ByDepth" and
+// increments the depth.
+func (self fmter) enter() fmter {
+	if self.conf.ByDepth != nil {
+		byDepth := self.conf.ByDepth
+		conf := byDepth(self.depth, *self.conf)
+		conf.ByDepth = byDepth
+		self.conf = &conf
+	}
+	self.depth++
+	if self.state != nil && self.depth > self.state.stats.Depth {
+		self.state.stats.Depth = self.depth
+	}
+	return self
+}
+
+func (self fmter) atDepthLimit() bool {
+	return self.conf.MaxDepth > 0 && self.depth >= self.conf.MaxDepth
+}
+
+// Number of elements to print out of the given total, see "Config.MaxElems".
+func (self Config) limitElems(total int) int {
+	if self.MaxElems > 0 && total > self.MaxElems {
+		return self.MaxElems
+	}
+	return total
+}
+
+// Positions of printed elements of a list, which are the first "head"
+// elements, followed by the last "tail" elements. See "Config.TailElems".
+type window struct{ total, head, tail int }
+
+func (self Config) window(total int) window {
+	tail := self.TailElems
+	if self.MaxElems <= 0 || tail <= 0 {
+		return window{total, self.limitElems(total), 0}
+	}
+	if total <= self.MaxElems+tail {
+		return window{total, total, 0}
+	}
+	return window{total, self.MaxElems, tail}
+}
+
+func (self window) count() int { return self.head + self.tail }
+
+// Elements omitted between the head and the tail.
+func (self window) gap() int {
+	if self.tail > 0 {
+		return self.total - self.count()
+	}
+	return 0
+}
+
+// Elements omitted at the end.
+func (self window) rest() int {
+	if self.tail > 0 {
+		return 0
+	}
+	return self.total - self.head
+}
+
+// Converts a position among printed elements to an element index.
+func (self window) index(pos int) int {
+	if pos < self.head {
+		return pos
+	}
+	return self.total - self.tail + pos - self.head
+}
+
+// Converts a position among printed elements to a position in its segment.
+func (self window) offset(pos int) int {
+	if pos < self.head {
+		return pos
+	}
+	return pos - self.head
+}
+
+func appendGapComment(out []byte, win window, fmter fmter) []byte {
+	if fmter.state != nil {
+		fmter.state.stats.Omitted += win.gap()
+		fmter.warn(nil, `elements are omitted due to MaxElems`)
+		fmter.limited(LimitElems, win.total, win.gap())
+	}
+
+	out = append(out, `/* … `...)
+	out = strconv.AppendInt(out, int64(win.gap()), 10)
+	if win.gap() == 1 {
+		out = append(out, ` element omitted … */`...)
+	} else {
+		out = append(out, ` elements omitted … */`...)
+	}
+	return out
+}
+
+// Returns the name of the type of a built-in number if its literal would have a
+// different default type, otherwise an empty string. See "Config.TypedNumbers".
+func numberConversion(val interface{}) string {
+	switch val := val.(type) {
+	case int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, uintptr:
+		return reflect.TypeOf(val).String()
+
+	// Numbers without literals are printed as typed expressions, see
+	// "appendFloatCall".
+	case float32:
+		if isLiteralFloat(float64(val)) {
+			return `float32`
+		}
+	case complex64:
+		if isLiteralComplex(complex128(val)) {
+			return `complex64`
+		}
+	case float64:
+		// Non-integer literals are "float64" by default.
+		if isLiteralFloat(val) && val == math.Trunc(val) {
+			return `float64`
 		}
+	}
+	return ``
+}
 
-	case reflect.Array:
-		if !fmter.elideType {
-			out = appendTypeName(out, rval.Type(), fmter)
+/*
+NaN, infinities and negative zero have no literal representation, since the
+constant "-0" equals zero. Such numbers are printed as calls to the "math"
+package, converted to the given type unless it's "float64".
+*/
+func appendFloatCall(out []byte, val float64, rtype reflect.Type, fmter fmter) []byte {
+	if rtype != float64Type {
+		out = appendTypeName(out, rtype, fmter)
+		out = append(out, '(')
+	}
+	out = appendFloat64(out, val, fmter)
+	if rtype != float64Type {
+		out = append(out, ')')
+	}
+	return out
+}
+
+// Prints a "float64" expression, which is a call to the "math" package if the
+// number has no literal representation.
+func appendFloat64(out []byte, val float64, fmter fmter) []byte {
+	if isLiteralFloat(val) {
+		return strconv.AppendFloat(out, val, 'f', -1, 64)
+	}
+
+	fmter.addImport(`math`, `math`)
+	switch {
+	case math.IsNaN(val):
+		return append(out, `math.NaN()`...)
+	case math.IsInf(val, 1):
+		return append(out, `math.Inf(1)`...)
+	case math.IsInf(val, -1):
+		return append(out, `math.Inf(-1)`...)
+	default:
+		return append(out, `math.Copysign(0, -1)`...)
+	}
+}
+
+// Complex numbers with parts lacking literals are printed as calls to the
+// "complex" builtin, converted to the given type unless it's "complex128".
+func appendComplexCall(out []byte, val complex128, rtype reflect.Type, fmter fmter) []byte {
+	if rtype != complex128Type {
+		out = appendTypeName(out, rtype, fmter)
+		out = append(out, '(')
+	}
+	out = append(out, `complex(`...)
+	out = appendFloat64(out, real(val), fmter)
+	out = append(out, ',', ' ')
+	out = appendFloat64(out, imag(val), fmter)
+	out = append(out, ')')
+	if rtype != complex128Type {
+		out = append(out, ')')
+	}
+	return out
+}
+
+// See "appendFloatCall".
+func isLiteralFloat(num float64) bool {
+	return isFinite(num) && !(num == 0 && math.Signbit(num))
+}
+
+func isLiteralComplex(num complex128) bool {
+	return isLiteralFloat(real(num)) && isLiteralFloat(imag(num))
+}
+
+func appendComplex128(out []byte, val complex128) []byte {
+	out = append(out, '(')
+	out = strconv.AppendFloat(out, real(val), 'f', -1, 64)
+	i := imag(val)
+	if !(i < 0) {
+		out = append(out, '+')
+	}
+	out = strconv.AppendFloat(out, i, 'f', -1, 64)
+	out = append(out, 'i', ')')
+	return out
+}
+
+func appendList(out []byte, rval reflect.Value, fmter fmter) []byte {
+	if fmter.atDepthLimit() {
+		return appendDepthLimit(out, fmter)
+	}
+	parent := fmter
+	fmter = fmter.enter()
+
+	elemType := rval.Type().Elem()
+	fmter.elideType = canElideType(elemType, fmter)
+
+	if indexes := fmter.keyedIndexes(rval); indexes != nil {
+		return appendKeyedList(out, rval, indexes, fmter)
+	}
+
+	win := fmter.conf.window(rval.Len())
+	count := win.count()
+
+	if perRow := fmter.columns(elemType); perRow > 0 {
+		digits := elemType.Bits() / 4
+		return appendRows(out, win, perRow, perRow, fmter, func(out []byte, i int) []byte {
+			return appendHex(out, rval.Index(i).Uint(), digits)
+		})
+	}
+
+	lay := layout{fmter: fmter, inline: fmter.conf.SingleLine() || fmter.inlineList(elemType, count) ||
+		parent.fits(out, rval, appendList)}
+	elemFmter := lay.child()
+
+	out = append(out, '{')
+	for pos := 0; pos < count; pos++ {
+		if pos == win.head {
+			lay.gap(win)
 		}
-		if rtype.Elem() == byteType {
-			out = appendBytes(out, byteArrayToSlice(rval), fmter)
-		} else {
-			out = appendList(out, rval, fmter)
+		out = lay.begin(out)
+		out = appendAny(out, elemFmter.elemValue(rval.Index(win.index(pos))).Interface(), elemFmter.atIndex(win.index(pos)))
+		out = lay.end(out)
+	}
+	out = lay.omitted(out, win.rest(), win.total, `element`, `elements`)
+	return lay.close(out)
+}
+
+// Returns the indexes of elements to print with index keys, or nil if the list
+// should be printed normally. See "Config.Sparse" and "Config.IndexKeys".
+func (self fmter) keyedIndexes(rval reflect.Value) []int {
+	if self.conf.Sparse {
+		indexes := sparseIndexes(rval)
+		if indexes != nil {
+			return indexes
 		}
+	}
 
-	case reflect.Slice:
-		if rval.IsNil() {
-			if fmter.elideType {
-				out = append(out, `nil`...)
-			} else {
-				out = appendTypeName(out, rval.Type(), fmter)
-				out = append(out, `(nil)`...)
+	if !self.conf.IndexKeys {
+		return nil
+	}
+	indexes := make([]int, rval.Len())
+	for i := range indexes {
+		indexes[i] = i
+	}
+	return indexes
+}
+
+// Returns the indexes of non-zero elements, or nil if the list isn't sparse.
+// See "Config.Sparse".
+func sparseIndexes(rval reflect.Value) []int {
+	total := rval.Len()
+	if total < 8 {
+		return nil
+	}
+
+	var indexes []int
+	for i := 0; i < total; i++ {
+		if !isZeroOrShouldOmit(rval.Index(i)) {
+			if len(indexes) >= total/4 {
+				return nil
 			}
-		} else {
-			out = appendTypeName(out, rval.Type(), fmter)
-			if rtype.Elem() == byteType {
-				out = appendBytes(out, rval.Bytes(), fmter)
+			indexes = append(indexes, i)
+		}
+	}
+
+	last := total - 1
+	if rval.Kind() == reflect.Slice && (len(indexes) == 0 || indexes[len(indexes)-1] != last) {
+		indexes = append(indexes, last)
+	}
+	if indexes == nil {
+		indexes = []int{}
+	}
+	return indexes
+}
+
+// Mirrors "appendList", prefixing elements with their indexes.
+func appendKeyedList(out []byte, rval reflect.Value, indexes []int, fmter fmter) []byte {
+	total := len(indexes)
+	count := fmter.conf.limitElems(total)
+	indexes = indexes[:count]
+	elemType := rval.Type().Elem()
+
+	if fmter.conf.SingleLine() || fmter.inlineList(elemType, count) {
+		fmter.indent = 0
+		out = appendBraceOpen(out, count > 0, fmter)
+		for i, index := range indexes {
+			out = appendInt(out, int64(index))
+			out = appendColon(out, fmter)
+			out = appendAny(out, fmter.elemValue(rval.Index(index)).Interface(), fmter.atIndex(index))
+			if i < count-1 {
+				out = append(out, ',', ' ')
+			}
+		}
+		out = appendOmitted(out, total-count, total, `element`, `elements`, fmter)
+		out = appendBraceClose(out, count > 0, fmter)
+		return out
+	}
+
+	out = append(out, '{')
+	if count > 0 {
+		out = appendNewline(out, fmter)
+		fmter.indent++
+	}
+
+	for _, index := range indexes {
+		out = appendIndent(out, fmter)
+		out = appendInt(out, int64(index))
+		out = appendColon(out, fmter)
+		out = appendAny(out, fmter.elemValue(rval.Index(index)).Interface(), fmter.atIndex(index))
+		out = append(out, ',')
+		out = appendNewline(out, fmter)
+	}
+
+	if total > count {
+		out = appendIndent(out, fmter)
+		out = appendOmittedComment(out, total-count, total, `element`, `elements`, fmter)
+		out = appendNewline(out, fmter)
+	}
+
+	if count > 0 {
+		fmter.indent--
+		out = appendIndent(out, fmter)
+	}
+
+	out = appendEmptyMultiline(out, count, fmter)
+	out = append(out, '}')
+	return out
+}
+
+func appendStruct(out []byte, rval reflect.Value, fmter fmter) []byte {
+	if fmter.atDepthLimit() {
+		return appendDepthLimit(out, fmter)
+	}
+	parent := fmter
+	fmter = fmter.enter()
+
+	rtype := rval.Type()
+	lay := layout{fmter: fmter, inline: fmter.conf.SingleLine() || fmter.inlineStruct(rtype) ||
+		parent.fits(out, rval, appendStruct)}
+	out = append(out, '{')
+
+	fields := structMetaOf(rtype).fields
+	plain := fmter.conf.FieldName == nil && fmter.conf.colon() == defaultColon
+
+	for pass := 0; pass < fmter.fieldPasses(); pass++ {
+		lay.group()
+
+		for i := range fields {
+			field := &fields[i]
+			sfield := field.StructField
+			if !field.exported {
+				fmter.warnUnexported(rtype, sfield)
+				continue
+			}
+
+			rfield := fmter.fieldValue(rtype, sfield, rval.Field(i))
+			if fmter.skipField(sfield, rfield, pass) {
+				continue
+			}
+
+			if plain {
+				out = lay.begin(out)
+				out = append(out, field.head...)
 			} else {
-				out = appendList(out, rval, fmter)
+				name := fmter.fieldName(sfield)
+				if name == `` {
+					continue
+				}
+				out = lay.begin(out)
+				out = append(out, name...)
+				out = appendColon(out, fmter)
 			}
+
+			fmter := lay.child().atField(sfield.Name)
+			fmter.elideType = fmter.canElideField(rfield)
+			fmter.owner = rtype
+			out = appendAny(out, rfield.Interface(), fmter)
+			out = lay.end(out)
+		}
+	}
+
+	out = lay.close(out)
+	if lay.count == 0 {
+		out = appendOpaqueStruct(out, rtype, fmter)
+	}
+	return out
+}
+
+/*
+Lays out the elements of a composite literal, either on a single line or one
+per line, which allows a single traversal to serve both modes. Usage:
+
+	out = append(out, '{')
+	for ... {
+		out = lay.begin(out)
+		out = appendAny(out, elem, lay.child())
+		out = lay.end(out)
+	}
+	out = lay.close(out)
+*/
+type layout struct {
+	// Formatter of the literal itself. Elements are indented one level deeper.
+	fmter  fmter
+	inline bool
+	count  int
+
+	// Set by "group" to separate the next element with a blank line.
+	pending bool
+
+	// Set by "gap" to precede the next element with a comment about elements
+	// omitted due to "Config.TailElems".
+	gapped bool
+	win    window
+}
+
+// Formatter for elements.
+func (self *layout) child() fmter {
+	fmter := self.fmter
+	if self.inline {
+		fmter.indent = 0
+	} else {
+		fmter.indent++
+	}
+	return fmter
+}
+
+// Starts a group of elements, separated from the previous group by a blank
+// line in multiline mode. Has no effect before the first element or if the
+// group is empty.
+func (self *layout) group() {
+	self.pending = self.count > 0
+}
+
+// Precedes the next element with a comment about omitted elements, on the
+// same line in single-line mode, or on a separate line otherwise.
+func (self *layout) gap(win window) {
+	self.gapped = true
+	self.win = win
+}
+
+// Called before each element.
+func (self *layout) begin(out []byte) []byte {
+	self.count++
+
+	if self.inline {
+		if self.count > 1 {
+			out = append(out, ',', ' ')
+		} else {
+			out = appendBraceSpace(out, self.fmter)
+		}
+		if self.gapped {
+			out = appendGapComment(out, self.win, self.fmter)
+			out = append(out, ' ')
+		}
+		self.gapped = false
+		return out
+	}
+
+	if self.count == 1 || self.pending {
+		out = appendNewline(out, self.fmter)
+	}
+	if self.gapped {
+		out = appendIndent(out, self.child())
+		out = appendGapComment(out, self.win, self.fmter)
+		out = appendNewline(out, self.fmter)
+	}
+	self.pending = false
+	self.gapped = false
+	return appendIndent(out, self.child())
+}
+
+// Called after each element.
+func (self *layout) end(out []byte) []byte {
+	if self.inline {
+		return out
+	}
+	out = append(out, ',')
+	return appendNewline(out, self.fmter)
+}
+
+// Adds a comment about elements omitted due to "Config.MaxElems".
+func (self *layout) omitted(out []byte, count, total int, singular, plural string) []byte {
+	if count <= 0 {
+		return out
+	}
+	if self.inline {
+		return appendOmitted(out, count, total, singular, plural, self.fmter)
+	}
+	out = appendIndent(out, self.child())
+	out = appendOmittedComment(out, count, total, singular, plural, self.fmter)
+	return appendNewline(out, self.fmter)
+}
+
+// Appends the closing brace.
+func (self *layout) close(out []byte) []byte {
+	if self.inline {
+		return appendBraceClose(out, self.count > 0, self.fmter)
+	}
+	if self.count > 0 {
+		out = appendIndent(out, self.fmter)
+	}
+	out = appendEmptyMultiline(out, self.count, self.fmter)
+	return append(out, '}')
+}
+
+/*
+Structs without exported fields, such as "sync.Mutex", are printed as empty
+literals, which silently loses their state. This adds a warning, and with
+"Config.AnnotateOpaque", a comment.
+*/
+func appendOpaqueStruct(out []byte, rtype reflect.Type, fmter fmter) []byte {
+	count := 0
+	for i := 0; i < rtype.NumField(); i++ {
+		sfield := rtype.Field(i)
+		if isSfieldExported(sfield) {
+			return out
+		}
+		if sfield.Name != `_` {
+			count++
+		}
+	}
+	if count == 0 {
+		return out
+	}
+
+	fmter.warn(rtype, `no exported fields, printed as empty`)
+	if !fmter.conf.AnnotateOpaque {
+		return out
+	}
+
+	out = append(out, ` /* opaque: `...)
+	out = strconv.AppendInt(out, int64(count), 10)
+	if count == 1 {
+		out = append(out, ` unexported field */`...)
+	} else {
+		out = append(out, ` unexported fields */`...)
+	}
+	return out
+}
+
+// TODO: the test doesn't cover constructor elision in maps.
+func appendMap(out []byte, rval reflect.Value, fmter fmter) []byte {
+	if fmter.atDepthLimit() {
+		return appendDepthLimit(out, fmter)
+	}
+	parent := fmter
+	fmter = fmter.enter()
+
+	rtype := rval.Type()
+	keyType := rtype.Key()
+	elemType := rtype.Elem()
+	lay := layout{fmter: fmter, inline: fmter.conf.SingleLine() ||
+		parent.inlineMap(rval) || parent.fits(out, rval, appendMap)}
+
+	keyFmter := lay.child()
+	keyFmter.elideType = canElideKeyType(keyType, fmter)
+
+	elemFmter := lay.child()
+	elemFmter.elideType = canElideType(elemType, fmter)
+
+	out = append(out, '{')
+	count, total := eachMapEntry(rval, keyFmter, func(entry mapEntry) {
+		out = lay.begin(out)
+		out = appendMapKey(out, entry.key, keyFmter)
+		out = appendColon(out, fmter)
+		out = appendAny(out, entry.val.Interface(), elemFmter.atKey(entry.key))
+		out = lay.end(out)
+	})
+	out = lay.omitted(out, total-count, total, `entry`, `entries`)
+	return lay.close(out)
+}
+
+/*
+Calls the function for each entry printed by "appendMap", returning the counts
+of printed and visible entries. Sorted or truncated maps require collecting all
+entries, see "mapEntries". Otherwise the map is iterated directly, which avoids
+allocating proportionally to its size.
+*/
+func eachMapEntry(rval reflect.Value, fmter fmter, fun func(mapEntry)) (int, int) {
+	total := rval.Len()
+	if fmter.conf.SortKeys || fmter.conf.limitElems(total) < total {
+		entries := mapEntries(rval, fmter)
+		total = len(entries)
+		entries = entries[:fmter.conf.limitElems(total)]
+		for _, entry := range entries {
+			fun(entry)
 		}
+		return len(entries), total
+	}
 
-	case reflect.Struct:
-		if !fmter.elideType {
-			out = appendTypeName(out, rval.Type(), fmter)
+	count := 0
+	iter := rval.MapRange()
+	for iter.Next() {
+		key := iter.Key()
+		if fmter.conf.filtersPaths() && !fmter.atKey(key).visible() {
+			continue
 		}
-		out = appendStruct(out, rval, fmter)
+		val, ok := fmter.mapValue(iter.Value())
+		if !ok {
+			continue
+		}
+		fun(mapEntry{key, val})
+		count++
+	}
+	return count, count
+}
 
-	case reflect.Map:
-		if rval.IsNil() {
-			if fmter.elideType {
-				out = append(out, `nil`...)
-			} else {
-				out = appendTypeName(out, rval.Type(), fmter)
-				out = append(out, `(nil)`...)
-			}
-		} else {
-			out = appendTypeName(out, rval.Type(), fmter)
-			out = appendMap(out, rval, fmter)
+// In multiline mode, prints composite keys on a single line if they fit within
+// "Config.InlineKeyLen", and on multiple lines otherwise.
+func appendMapKey(out []byte, key reflect.Value, fmter fmter) []byte {
+	limit := fmter.conf.InlineKeyLen
+	if limit <= 0 || fmter.conf.SingleLine() || isPrimitive(key.Type()) {
+		return appendKey(out, key, fmter)
+	}
+
+	inline := fmter.inline()
+	if !fmter.measure(limit, key, appendInterface) {
+		return appendKey(out, key, fmter)
+	}
+	return appendKey(out, key, inline)
+}
+
+/*
+Prints a map key. Keys of interface types keep the types of built-in numbers,
+as if "Config.TypedNumbers" was set, because constant keys must be distinct:
+"map[interface{}]int{3: 1, int64(3): 2}".
+*/
+func appendKey(out []byte, key reflect.Value, fmter fmter) []byte {
+	val := key.Interface()
+	if key.Kind() == reflect.Interface && !fmter.elideType {
+		if name := numberConversion(val); name != `` {
+			fmter.elideType = true
+			out = append(out, name...)
+			out = append(out, '(')
+			out = appendAny(out, val, fmter)
+			return append(out, ')')
 		}
 	}
+	return appendAny(out, val, fmter)
+}
+
+// Same as "canElideType", but respects "Config.KeyConversions".
+func canElideKeyType(rtype reflect.Type, fmter fmter) bool {
+	if fmter.conf.KeyConversions && rtype.PkgPath() != `` && !isComposite(rtype) {
+		return false
+	}
+	return canElideType(rtype, fmter)
+}
+
+// Prints the map as a slice of pairs. See "Config.MapPairs".
+func appendMapPairs(out []byte, rval reflect.Value, fmter fmter) []byte {
+	call := fmter.conf.MapPairsFunc
+	if rval.IsNil() && fmter.elideType && call == `` {
+		return append(out, `nil`...)
+	}
+
+	if call != `` {
+		out = append(out, call...)
+		out = append(out, '(')
+	}
 
+	out = appendMapPairsType(out, rval.Type(), fmter)
+	if rval.IsNil() {
+		out = append(out, `(nil)`...)
+	} else {
+		out = appendPairs(out, rval, fmter)
+	}
+
+	if call != `` {
+		out = append(out, ')')
+	}
 	return out
 }
 
-func appendComplex128(out []byte, val complex128) []byte {
-	out = append(out, '(')
-	out = strconv.AppendFloat(out, real(val), 'f', -1, 64)
-	i := imag(val)
-	if !(i < 0) {
-		out = append(out, '+')
+func appendMapPairsType(out []byte, rtype reflect.Type, fmter fmter) []byte {
+	if fmter.conf.SingleLine() {
+		out = append(out, `[]struct{Key `...)
+		out = appendTypeName(out, rtype.Key(), fmter)
+		out = append(out, `; Value `...)
+		out = appendTypeName(out, rtype.Elem(), fmter)
+		out = append(out, '}')
+		return out
 	}
-	out = strconv.AppendFloat(out, i, 'f', -1, 64)
-	out = append(out, 'i', ')')
+
+	out = append(out, `[]struct {`...)
+	out = appendNewline(out, fmter)
+	fmter.indent++
+	out = appendIndent(out, fmter)
+	out = append(out, `Key   `...)
+	out = appendTypeName(out, rtype.Key(), fmter)
+	out = appendNewline(out, fmter)
+	out = appendIndent(out, fmter)
+	out = append(out, `Value `...)
+	out = appendTypeName(out, rtype.Elem(), fmter)
+	out = appendNewline(out, fmter)
+	fmter.indent--
+	out = appendIndent(out, fmter)
+	out = append(out, '}')
 	return out
 }
 
-func appendList(out []byte, rval reflect.Value, fmter fmter) []byte {
-	elemType := rval.Type().Elem()
-	fmter.elideType = canElideType(elemType, fmter)
-	count := rval.Len()
+// Mirrors "appendMap", printing each entry as a struct literal.
+func appendPairs(out []byte, rval reflect.Value, fmter fmter) []byte {
+	if fmter.atDepthLimit() {
+		return appendDepthLimit(out, fmter)
+	}
+	fmter = fmter.enter()
+
+	rtype := rval.Type()
+	keyFmter := fmter
+	keyFmter.elideType = canElideKeyType(rtype.Key(), fmter)
+	elemFmter := fmter
+	elemFmter.elideType = canElideType(rtype.Elem(), fmter)
+
+	entries := mapEntries(rval, keyFmter)
+	total := len(entries)
+	entries = entries[:fmter.conf.limitElems(total)]
 
-	if fmter.conf.SingleLine() || (!mayRequireMultiline(elemType) && count < 48) {
+	if fmter.conf.SingleLine() {
 		fmter.indent = 0
-		out = append(out, '{')
-		for i := 0; i < count; i++ {
-			out = appendAny(out, rval.Index(i).Interface(), fmter)
-			if i < count-1 {
+		keyFmter.indent = 0
+		elemFmter.indent = 0
+
+		out = appendBraceOpen(out, len(entries) > 0, fmter)
+		for i, entry := range entries {
+			out = appendPair(out, entry, keyFmter, elemFmter, true)
+			if i < len(entries)-1 {
 				out = append(out, ',', ' ')
 			}
 		}
-		out = append(out, '}')
+		out = appendOmitted(out, total-len(entries), total, `entry`, `entries`, fmter)
+		out = appendBraceClose(out, len(entries) > 0, fmter)
 		return out
 	}
 
+	inline := isPrimitive(rtype.Key()) && isPrimitive(rtype.Elem()) && !fmter.conf.ForceMultiline
 	out = append(out, '{')
-	if count > 0 {
-		out = append(out, '\n')
-		fmter.indent++
+	if len(entries) > 0 {
+		out = appendNewline(out, fmter)
 	}
+	fmter.indent++
+	keyFmter.indent = fmter.indent + 1
+	elemFmter.indent = fmter.indent + 1
 
-	for i := 0; i < count; i++ {
+	for _, entry := range entries {
 		out = appendIndent(out, fmter)
-		out = appendAny(out, rval.Index(i).Interface(), fmter)
-		out = append(out, ',', '\n')
+		out = appendPair(out, entry, keyFmter, elemFmter, inline)
+		out = append(out, ',')
+		out = appendNewline(out, fmter)
 	}
 
-	if count > 0 {
-		fmter.indent--
+	if total > len(entries) {
 		out = appendIndent(out, fmter)
+		out = appendOmittedComment(out, total-len(entries), total, `entry`, `entries`, fmter)
+		out = appendNewline(out, fmter)
 	}
 
+	fmter.indent--
+	if len(entries) > 0 {
+		out = appendIndent(out, fmter)
+	}
+	out = appendEmptyMultiline(out, len(entries), fmter)
 	out = append(out, '}')
 	return out
 }
 
-func appendStruct(out []byte, rval reflect.Value, fmter fmter) []byte {
-	rtype := rval.Type()
+func appendPair(out []byte, entry mapEntry, keyFmter, elemFmter fmter, inline bool) []byte {
+	elemFmter = elemFmter.atKey(entry.key)
+	if inline {
+		out = appendBraceOpen(out, true, keyFmter)
+		out = append(out, `Key`...)
+		out = appendColon(out, keyFmter)
+		out = appendKey(out, entry.key, keyFmter)
+		out = append(out, `, Value`...)
+		out = appendColon(out, elemFmter)
+		out = appendAny(out, entry.val.Interface(), elemFmter)
+		out = appendBraceClose(out, true, keyFmter)
+		return out
+	}
 
-	if fmter.conf.SingleLine() {
-		fmter.indent = 0
-		var hasFields bool
+	out = append(out, '{')
+	out = appendNewline(out, keyFmter)
+	out = appendIndent(out, keyFmter)
+	out = append(out, `Key`...)
+	out = appendColon(out, keyFmter)
+	out = appendKey(out, entry.key, keyFmter)
+	out = append(out, ',')
+	out = appendNewline(out, keyFmter)
+	out = appendIndent(out, elemFmter)
+	out = append(out, `Value`...)
+	out = appendColon(out, elemFmter)
+	out = appendAny(out, entry.val.Interface(), elemFmter)
+	out = append(out, ',')
+	out = appendNewline(out, elemFmter)
+	elemFmter.indent--
+	out = appendIndent(out, elemFmter)
+	out = append(out, '}')
+	return out
+}
 
-		out = append(out, '{')
-		for i := 0; i < rtype.NumField(); i++ {
-			sfield := rtype.Field(i)
-			if !isSfieldExported(sfield) {
-				continue
-			}
+type mapEntry struct{ key, val reflect.Value }
 
-			rfield := rval.Field(i)
-			if !fmter.conf.ZeroFields && isZeroOrShouldOmit(rfield) {
-				continue
-			}
+// Value of the map entry in the output, or false if the entry should be
+// omitted. See "Config.EmptyMapValues".
+func (self fmter) mapValue(rval reflect.Value) (reflect.Value, bool) {
+	if self.conf.EmptyMapValues == EmptyOmit && isEmptyComposite(rval) {
+		return rval, false
+	}
+	return self.conf.EmptyMapValues.value(rval), true
+}
 
-			if hasFields {
-				out = append(out, ',', ' ')
-			}
-			hasFields = true
+// Returns the map entries, sorted if "Config.SortKeys" is set or the map is
+// truncated due to "Config.MaxElems", so that truncated output retains the
+// same entries every time. See "keySorter". Uses an iterator rather than
+// "MapIndex", which can't look up NaN keys.
+func mapEntries(rval reflect.Value, fmter fmter) []mapEntry {
+	entries := make([]mapEntry, 0, rval.Len())
+	iter := rval.MapRange()
+	for iter.Next() {
+		key := iter.Key()
+		if fmter.conf.filtersPaths() && !fmter.atKey(key).visible() {
+			continue
+		}
+		val, ok := fmter.mapValue(iter.Value())
+		if !ok {
+			continue
+		}
+		entries = append(entries, mapEntry{key, val})
+	}
+	if !fmter.conf.SortKeys && fmter.conf.limitElems(len(entries)) == len(entries) {
+		return entries
+	}
+
+	fmter.ownConf()
+	fmter.conf.Indent = ``
+	fmter.indent = 0
+	fmter.state = nil
+	fmter.stack = nil
+
+	sorter := keySorter{
+		entries:  entries,
+		sortKeys: make([]reflect.Value, len(entries)),
+		rendered: make([]string, len(entries)),
+		vals:     make([]string, len(entries)),
+		fmter:    fmter,
+	}
+	for i, entry := range entries {
+		sorter.sortKeys[i] = entry.key
+		if fmter.conf.SortKey != nil {
+			sorter.sortKeys[i] = reflect.ValueOf(fmter.conf.SortKey(fmter.path, entry.key.Interface()))
+		}
+		sorter.rendered[i] = string(appendKey(nil, entry.key, fmter))
+	}
 
-			out = append(out, sfield.Name...)
-			out = append(out, ':', ' ')
+	sort.Sort(sorter)
+	return entries
+}
 
-			fmter := fmter
-			fmter.elideType = isPrimitive(rfield.Type()) || isNil(rfield)
-			out = appendAny(out, rfield.Interface(), fmter)
+/*
+Orders entries by key value where it's well-defined, falling back on the code
+representing the keys. Keys may be printed identically despite being different,
+for example NaN keys or structs with unexported fields, in which case entries
+are ordered by the code representing their values, rendered lazily. Entries
+equivalent by all these criteria print identically, which makes the output
+deterministic for any key type.
+*/
+type keySorter struct {
+	entries  []mapEntry
+	sortKeys []reflect.Value
+	rendered []string
+	vals     []string
+	fmter    fmter
+}
+
+func (self keySorter) Len() int { return len(self.entries) }
+
+func (self keySorter) Less(i, j int) bool {
+	less, ok := sortKeyLess(self.sortKeys[i], self.sortKeys[j])
+	if ok {
+		return less
+	}
+	if self.rendered[i] != self.rendered[j] {
+		return self.rendered[i] < self.rendered[j]
+	}
+	return self.val(i) < self.val(j)
+}
+
+func (self keySorter) Swap(i, j int) {
+	self.entries[i], self.entries[j] = self.entries[j], self.entries[i]
+	self.sortKeys[i], self.sortKeys[j] = self.sortKeys[j], self.sortKeys[i]
+	self.rendered[i], self.rendered[j] = self.rendered[j], self.rendered[i]
+	self.vals[i], self.vals[j] = self.vals[j], self.vals[i]
+}
+
+// The rendered value is never empty, so an empty string means "not rendered".
+func (self keySorter) val(i int) string {
+	if self.vals[i] == `` {
+		self.vals[i] = string(appendAny(nil, self.entries[i].val.Interface(), self.fmter))
+	}
+	return self.vals[i]
+}
+
+// Orders keys, or results of "Config.SortKey", of the same type via their
+// "Less" method if any, otherwise by value. See "valueLess".
+func sortKeyLess(one, two reflect.Value) (bool, bool) {
+	if !one.IsValid() || !two.IsValid() || one.Type() != two.Type() {
+		return false, false
+	}
+
+	less := lessMethod(one.Type())
+	if less.IsValid() {
+		if callLess(less, one, two) {
+			return true, true
 		}
-		out = append(out, '}')
-		return out
+		return false, callLess(less, two, one)
 	}
 
-	count := 0
-	out = append(out, '{')
+	return valueLess(one, two)
+}
 
-	for i := 0; i < rtype.NumField(); i++ {
-		sfield := rtype.Field(i)
-		if !isSfieldExported(sfield) {
-			continue
+// Returns the function of the method "Less(T) bool" of the given type, if any.
+func lessMethod(rtype reflect.Type) reflect.Value {
+	method, ok := rtype.MethodByName(`Less`)
+	if !ok {
+		return reflect.Value{}
+	}
+	mtype := method.Type
+	if mtype.NumIn() != 2 || mtype.In(1) != rtype || mtype.NumOut() != 1 || mtype.Out(0).Kind() != reflect.Bool {
+		return reflect.Value{}
+	}
+	return method.Func
+}
+
+func callLess(fun, one, two reflect.Value) bool {
+	return fun.Call([]reflect.Value{one, two})[0].Bool()
+}
+
+// Orders values of the same type by value where it's well-defined. The boolean
+// is false if the values are equivalent for ordering purposes or have no
+// natural order.
+func valueLess(one, two reflect.Value) (bool, bool) {
+	switch one.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return one.Int() < two.Int(), one.Int() != two.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return one.Uint() < two.Uint(), one.Uint() != two.Uint()
+	case reflect.Float32, reflect.Float64:
+		return floatLess(one.Float(), two.Float())
+	case reflect.String:
+		return one.String() < two.String(), one.String() != two.String()
+	case reflect.Bool:
+		return !one.Bool() && two.Bool(), one.Bool() != two.Bool()
+	default:
+		return false, false
+	}
+}
+
+// Orders floats numerically, with -0 before +0 and NaN last. The boolean is
+// false if the numbers are equivalent for ordering purposes.
+func floatLess(one, two float64) (bool, bool) {
+	switch {
+	case math.IsNaN(one) || math.IsNaN(two):
+		return math.IsNaN(two), math.IsNaN(one) != math.IsNaN(two)
+	case one != two:
+		return one < two, true
+	default:
+		return math.Signbit(one), math.Signbit(one) != math.Signbit(two)
+	}
+}
+
+// Similar to fmt.Sprintf("%#02v", val), but multiline: large inputs are printed
+// as a column with 8 bytes per row, unless overridden via "Config.Columns".
+func appendBytes(out []byte, val []byte, fmter fmter) []byte {
+	fmter = fmter.enter()
+	perRow := fmter.conf.bytesPerRow()
+	inline := fmter.conf.InlineBytes
+	if inline <= 0 {
+		switch {
+		case fmter.conf.Compactness >= 3:
+			inline = 64
+		case fmter.conf.Compactness == 2:
+			inline = 32
+		case fmter.conf.Compactness == 1:
+			inline = 16
+		default:
+			inline = perRow
 		}
+	}
 
-		rfield := rval.Field(i)
-		if !fmter.conf.ZeroFields && isZeroOrShouldOmit(rfield) {
-			continue
+	win := fmter.conf.window(len(val))
+	if win.gap() == 0 && !fmter.conf.ForceMultiline {
+		return appendByteRows(out, val[:win.count()], win.rest(), perRow, inline, fmter)
+	}
+	return appendRows(out, win, perRow, inline, fmter, func(out []byte, i int) []byte {
+		return appendByteHex(out, val[i])
+	})
+}
+
+/*
+Fast path of "appendRows" for bytes without a gap comment. Writes each row with
+a lookup table of pre-rendered elements, including separators, instead of
+formatting bytes one by one, and preallocates the output. The result is
+identical to "appendRows".
+*/
+func appendByteRows(out []byte, val []byte, rest, perRow, inline int, fmter fmter) []byte {
+	count := len(val)
+
+	if fmter.conf.SingleLine() || count <= inline {
+		out = fmter.grow(out, count*len(byteHexSep[0])+len(`{}`))
+		out = appendBraceOpen(out, count > 0, fmter)
+		for start := 0; start < count; start += perFlush {
+			out = appendByteRow(out, val[start:minInt(start+perFlush, count)], start > 0)
+			out = fmter.flush(out)
 		}
+		out = appendOmitted(out, rest, count+rest, `element`, `elements`, fmter)
+		return appendBraceClose(out, count > 0, fmter)
+	}
 
-		count++
-		if count == 1 {
-			out = append(out, '\n')
-			fmter.indent++
+	var breaks []int
+	if fmter.conf.ByteBreaks != nil {
+		breaks = fmter.conf.ByteBreaks(val)
+	}
+
+	fmter.indent++
+	newline := fmter.conf.newline() + fmter.conf.LinePrefix
+	indent := len(fmter.conf.Indent) * fmter.indent
+	rows := (count+perRow-1)/perRow + len(breaks)
+	out = fmter.grow(out, count*len(byteHexSep[0])+rows*(indent+len(`,`)+len(newline))+len(`{}`))
+
+	out = append(out, '{')
+	out = appendNewline(out, fmter)
+
+	for start := 0; start < count; {
+		end := minInt(start+perRow, count)
+		for len(breaks) > 0 && breaks[0] <= start {
+			breaks = breaks[1:]
+		}
+		if len(breaks) > 0 && breaks[0] < end {
+			end = breaks[0]
 		}
 
 		out = appendIndent(out, fmter)
-		out = append(out, sfield.Name...)
-		out = append(out, ':', ' ')
-
-		fmter := fmter
-		fmter.elideType = isPrimitive(rfield.Type()) || isNil(rfield)
-		out = appendAny(out, rfield.Interface(), fmter)
-		out = append(out, ',', '\n')
+		out = appendByteRow(out, val[start:end], false)
+		out = append(out, ',')
+		out = appendNewline(out, fmter)
+		out = fmter.flush(out)
+		start = end
 	}
 
-	if count > 0 {
-		fmter.indent--
+	if rest > 0 {
 		out = appendIndent(out, fmter)
+		out = appendOmittedComment(out, rest, count+rest, `element`, `elements`, fmter)
+		out = appendNewline(out, fmter)
 	}
 
-	out = append(out, '}')
+	fmter.indent--
+	out = appendIndent(out, fmter)
+	return append(out, '}')
+}
+
+/*
+Returns a function for "Config.ByteBreaks" which places segment boundaries
+where the contents match a pattern, also known as content-defined chunking.
+Boundaries are derived from a rolling hash of the preceding bytes, so they
+move along with inserted or removed bytes, and segments after the change
+remain the same once boundaries realign, typically at the next boundary.
+Segments take roughly "size" bytes on average, and between "size/4" and
+"size*4" bytes, except for the last segment. A size which is a multiple of
+the row length, such as 256, works best. Non-positive sizes disable
+segmenting.
+*/
+func ContentBreaks(size int) func([]byte) []int {
+	var mask uint64
+	for size > 0 && mask < uint64(size-1) {
+		mask = mask<<1 | 1
+	}
+	minSize := size / 4
+	maxSize := size * 4
+
+	return func(val []byte) []int {
+		if size <= 0 {
+			return nil
+		}
+
+		var out []int
+		var hash uint64
+		start := 0
+		for i, char := range val {
+			hash = hash<<1 + gearTable[char]
+			if i-start+1 < minSize {
+				continue
+			}
+			if hash&mask == 0 || i-start+1 >= maxSize {
+				start = i + 1
+				hash = 0
+				if start < len(val) {
+					out = append(out, start)
+				}
+			}
+		}
+		return out
+	}
+}
+
+// Pseudo-random values for the rolling hash of "ContentBreaks". Generated by
+// a fixed "splitmix64" sequence, which keeps boundaries stable across versions.
+var gearTable = func() (out [256]uint64) {
+	var state uint64
+	for i := range out {
+		state += 0x9e3779b97f4a7c15
+		num := state
+		num = (num ^ num>>30) * 0xbf58476d1ce4e5b9
+		num = (num ^ num>>27) * 0x94d049bb133111eb
+		out[i] = num ^ num>>31
+	}
+	return
+}()
+
+// Elements are separated by ", ". If "sep" is true, the first element is
+// preceded by the separator, continuing a previous row.
+func appendByteRow(out []byte, val []byte, sep bool) []byte {
+	for i, char := range val {
+		if i > 0 || sep {
+			out = append(out, byteHexSep[char][:]...)
+		} else {
+			out = append(out, byteHexSep[char][len(`, `):]...)
+		}
+	}
 	return out
 }
 
-// TODO: the test doesn't cover constructor elision in maps.
-func appendMap(out []byte, rval reflect.Value, fmter fmter) []byte {
-	rtype := rval.Type()
-	keyType := rtype.Key()
-	elemType := rtype.Elem()
-	elideKeyType := canElideType(keyType, fmter)
-	elideElemType := canElideType(elemType, fmter)
+// Number of bytes written between flushes in single-line mode.
+const perFlush = 1 << 10
 
-	if fmter.conf.SingleLine() {
-		fmter.indent = 0
+// Bytes pre-rendered as ", 0x00" through ", 0xff".
+var byteHexSep = func() (out [256][6]byte) {
+	for i := range out {
+		out[i] = [6]byte{',', ' ', '0', 'x', hexDigits[i>>4], hexDigits[i&0xf]}
+	}
+	return
+}()
+
+// Ensures capacity for at least "size" more bytes, to avoid repeated growth.
+// When streaming to a writer, the output is flushed periodically, so capacity
+// beyond the flush threshold would be wasted.
+func (self fmter) grow(out []byte, size int) []byte {
+	if self.state != nil && self.state.writer != nil && size > flushSize {
+		size = flushSize
+	}
+	if cap(out)-len(out) >= size {
+		return out
+	}
+	buf := make([]byte, len(out), len(out)+size)
+	copy(buf, out)
+	return buf
+}
 
-		keyFmter := fmter
-		keyFmter.elideType = elideKeyType
+func minInt(one, two int) int {
+	if one < two {
+		return one
+	}
+	return two
+}
 
-		elemFmter := fmter
-		elemFmter.elideType = elideElemType
+// Prints elements in rows of "perRow", or on a single line if there are at most
+// "inline" elements.
+func appendRows(out []byte, win window, perRow, inline int, fmter fmter, appendElem func([]byte, int) []byte) []byte {
+	count := win.count()
+	if fmter.conf.ForceMultiline {
+		perRow = 1
+		inline = -1
+	}
 
-		keys := rval.MapKeys()
+	if fmter.conf.SingleLine() || count <= inline {
+		out = appendBraceOpen(out, count > 0, fmter)
 
-		out = append(out, '{')
-		for i, key := range keys {
-			out = appendAny(out, key.Interface(), keyFmter)
-			out = append(out, ':', ' ')
-			out = appendAny(out, rval.MapIndex(key).Interface(), elemFmter)
-			if i < len(keys)-1 {
+		for pos := 0; pos < count; pos++ {
+			if pos == win.head {
+				out = appendGapComment(out, win, fmter)
+				out = append(out, ' ')
+			}
+			out = appendElem(out, win.index(pos))
+			if pos < count-1 {
 				out = append(out, ',', ' ')
 			}
+			out = fmter.flush(out)
 		}
-		out = append(out, '}')
+
+		out = appendOmitted(out, win.rest(), win.total, `element`, `elements`, fmter)
+		out = appendBraceClose(out, count > 0, fmter)
 		return out
 	}
 
+	if count == 0 {
+		out = append(out, '{')
+		out = appendEmptyMultiline(out, count, fmter)
+		return append(out, '}')
+	}
+
+	fmter.indent++
 	out = append(out, '{')
-	keys := rval.MapKeys()
+	out = appendNewline(out, fmter)
 
-	for i, key := range keys {
-		if i == 0 {
-			out = append(out, '\n')
-			fmter.indent++
+	for pos := 0; pos < count; pos++ {
+		if pos == 0 {
+			out = appendIndent(out, fmter)
+		} else if pos == win.head {
+			out = append(out, ',')
+			out = appendNewline(out, fmter)
+			out = appendIndent(out, fmter)
+			out = appendGapComment(out, win, fmter)
+			out = appendNewline(out, fmter)
+			out = fmter.flush(out)
+			out = appendIndent(out, fmter)
+		} else if win.offset(pos)%perRow == 0 {
+			out = append(out, ',')
+			out = appendNewline(out, fmter)
+			out = fmter.flush(out)
+			out = appendIndent(out, fmter)
+		} else {
+			out = append(out, ',', ' ')
 		}
+		out = appendElem(out, win.index(pos))
+	}
 
-		keyFmter := fmter
-		keyFmter.elideType = elideKeyType
-
-		elemFmter := fmter
-		elemFmter.elideType = elideElemType
+	out = append(out, ',')
+	out = appendNewline(out, fmter)
 
+	if win.rest() > 0 {
 		out = appendIndent(out, fmter)
-		out = appendAny(out, key.Interface(), keyFmter)
-		out = append(out, ':', ' ')
-		out = appendAny(out, rval.MapIndex(key).Interface(), elemFmter)
+		out = appendOmittedComment(out, win.rest(), win.total, `element`, `elements`, fmter)
+		out = appendNewline(out, fmter)
+	}
+
+	fmter.indent--
+	out = appendIndent(out, fmter)
+	out = append(out, '}')
+	return out
+}
+
+// Returns the number of values per row for columnar output of elements of the
+// given type, or 0 if "Config.Columns" doesn't apply. See "appendRows".
+func (self fmter) columns(rtype reflect.Type) int {
+	if len(self.conf.Columns) == 0 || !self.elideType || rtype.Implements(goStringerType) ||
+		(self.conf.Stringers && rtype.Implements(stringerType)) {
+		return 0
+	}
+	switch rtype.Kind() {
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return self.conf.Columns[rtype.Kind()]
+	default:
+		return 0
+	}
+}
+
+const hexDigits = `0123456789abcdef`
+
+// Decimal digit pairs "00" through "99".
+var decimalPairs = func() (out [200]byte) {
+	for i := 0; i < 100; i++ {
+		out[i*2] = byte('0' + i/10)
+		out[i*2+1] = byte('0' + i%10)
+	}
+	return
+}()
+
+/*
+Same as "strconv.AppendUint" in base 10, but faster for the numbers typical for
+fixtures. Numbers below 100 take a single table lookup, and larger numbers are
+converted two digits at a time.
+*/
+func appendUint(out []byte, val uint64) []byte {
+	if val < 10 {
+		return append(out, byte('0'+val))
+	}
+	if val < 100 {
+		return append(out, decimalPairs[val*2], decimalPairs[val*2+1])
+	}
 
-		out = append(out, ',', '\n')
+	var buf [20]byte
+	pos := len(buf)
+	for val >= 100 {
+		pair := (val % 100) * 2
+		val /= 100
+		pos -= 2
+		buf[pos] = decimalPairs[pair]
+		buf[pos+1] = decimalPairs[pair+1]
 	}
+	if val >= 10 {
+		pos -= 2
+		buf[pos] = decimalPairs[val*2]
+		buf[pos+1] = decimalPairs[val*2+1]
+	} else {
+		pos--
+		buf[pos] = byte('0' + val)
+	}
+	return append(out, buf[pos:]...)
+}
 
-	if len(keys) > 0 {
-		fmter.indent--
-		out = appendIndent(out, fmter)
+// Same as "strconv.AppendInt" in base 10. See "appendUint". The negation of
+// the minimum int64 overflows back to itself, which converts to the correct
+// unsigned magnitude.
+func appendInt(out []byte, val int64) []byte {
+	if val < 0 {
+		return appendUint(append(out, '-'), uint64(-val))
 	}
+	return appendUint(out, uint64(val))
+}
 
-	out = append(out, '}')
+// Appends the value as hex, zero-padded to the given number of digits.
+func appendHex(out []byte, val uint64, digits int) []byte {
+	out = append(out, '0', 'x')
+	for i := digits - 1; i >= 0; i-- {
+		out = append(out, hexDigits[(val>>(uint(i)*4))&0xf])
+	}
 	return out
 }
 
-// Similar to fmt.Sprintf("%#02v", val), but multiline: large inputs are printed
-// as a column with 8 bytes per row.
-func appendBytes(out []byte, val []byte, fmter fmter) []byte {
-	if fmter.conf.SingleLine() || len(val) <= 8 {
-		out = append(out, '{')
+func appendByteHex(out []byte, char byte) []byte {
+	return append(out, '0', 'x', hexDigits[int(char>>4)], hexDigits[int(char&^0xf0)])
+}
 
-		for i, char := range val {
-			out = appendByteHex(out, char)
-			if i < len(val)-1 {
-				out = append(out, ',', ' ')
-			}
-		}
+// Addressable arrays are sliced in place. Others, which includes any array
+// obtained from an interface, are copied, which is a single allocation.
+func byteArrayToSlice(rval reflect.Value) []byte {
+	if rval.CanAddr() {
+		return rval.Slice(0, rval.Len()).Bytes()
+	}
+	out := make([]byte, rval.Len())
+	reflect.Copy(reflect.ValueOf(out), rval)
+	return out
+}
 
-		out = append(out, '}')
-		return out
+// True if "Config.TypedNils" applies to the value.
+func (self fmter) showTypedNil(rval reflect.Value) bool {
+	return self.conf.TypedNils && !self.elideType && rval.IsNil()
+}
+
+/*
+Prints a pointer to a non-composite value as a call of "Config.PtrFunc", such as
+"ptr.To(uint8(0xff))". The argument keeps its type, since the helper infers the
+type of the pointer from it, which includes nil pointees: "ptr.To((*int)(nil))".
+Named pointer types are converted back unless the type is implied.
+*/
+func appendPtrCall(out []byte, rval reflect.Value, fmter fmter) []byte {
+	rtype := rval.Type()
+	named := rtype.Name() != `` && !fmter.elideType
+	if named {
+		out = appendTypeName(out, rtype, fmter)
+		out = append(out, '(')
 	}
 
-	fmter.indent++
-	out = append(out, '{', '\n')
+	path, ident := splitFuncName(fmter.conf.PtrFunc)
+	if path == `` {
+		out = append(out, ident...)
+	} else {
+		out = appendQualifiedPath(out, path, guessPackageName(path), ident, fmter)
+	}
+	out = append(out, '(')
 
-	for i, char := range val {
-		if i == 0 {
-			out = appendIndent(out, fmter)
-		} else if i%8 == 0 {
-			out = append(out, ',', '\n')
-			out = appendIndent(out, fmter)
-		} else {
-			out = append(out, ',', ' ')
-		}
-		out = appendByteHex(out, char)
+	elem := rval.Elem().Interface()
+	fmter.elideType = false
+	if isNil(rval.Elem()) {
+		out = appendTypedNil(out, rtype.Elem(), fmter)
+	} else if name := numberConversion(elem); name != `` {
+		fmter.elideType = true
+		out = append(out, name...)
+		out = append(out, '(')
+		out = appendAny(out, elem, fmter)
+		out = append(out, ')')
+	} else {
+		out = appendAny(out, elem, fmter)
 	}
 
-	fmter.indent--
-	out = append(out, ',', '\n')
-	out = appendIndent(out, fmter)
-	out = append(out, '}')
+	out = append(out, ')')
+	if named {
+		out = append(out, ')')
+	}
 	return out
 }
 
-func appendByteHex(out []byte, char byte) []byte {
-	const hexDigits = `0123456789abcdef`
-	return append(out, '0', 'x', hexDigits[int(char>>4)], hexDigits[int(char&^0xf0)])
-}
+// Prints a nil of the given type. Unnamed pointer, func and channel types are
+// parenthesized, since conversions such as "*T(nil)" are parsed differently.
+func appendTypedNil(out []byte, rtype reflect.Type, fmter fmter) []byte {
+	paren := false
+	if rtype.Name() == `` {
+		switch rtype.Kind() {
+		case reflect.Ptr, reflect.Func, reflect.Chan:
+			paren = true
+		}
+	}
 
-func byteArrayToSlice(rval reflect.Value) []byte {
-	type sliceHeader struct {
-		dat unsafe.Pointer
-		len int
-		cap int
+	if paren {
+		out = append(out, '(')
 	}
-	ptr, size := raw(rval)
-	slice := sliceHeader{ptr, int(size), int(size)}
-	return *(*[]byte)(unsafe.Pointer(&slice))
+	out = appendTypeName(out, rtype, fmter)
+	if paren {
+		out = append(out, ')')
+	}
+	return append(out, `(nil)`...)
 }
 
 func appendCastPrefix(out []byte, rval reflect.Value, fmter fmter) []byte {
@@ -704,6 +3382,63 @@ func appendCastSuffix(out []byte, rval reflect.Value, fmter fmter) []byte {
 	return append(out, ')')
 }
 
+func appendNewline(out []byte, fmter fmter) []byte {
+	if fmter.conf.Newline == `` {
+		out = append(out, '\n')
+	} else {
+		out = append(out, fmter.conf.Newline...)
+	}
+	return append(out, fmter.conf.LinePrefix...)
+}
+
+func (self Config) newline() string {
+	if self.Newline == `` {
+		return "\n"
+	}
+	return self.Newline
+}
+
+func appendColon(out []byte, fmter fmter) []byte {
+	return append(out, fmter.conf.colon()...)
+}
+
+const defaultColon = `: `
+
+// True if the separator is empty or consists of a single colon surrounded by
+// optional spaces.
+func isValidColon(str string) bool {
+	return str == `` || strings.Trim(str, ` `) == `:`
+}
+
+func (self Config) colon() string {
+	if self.Colon == `` {
+		return defaultColon
+	}
+	return self.Colon
+}
+
+func appendBraceOpen(out []byte, nonEmpty bool, fmter fmter) []byte {
+	out = append(out, '{')
+	if nonEmpty {
+		out = appendBraceSpace(out, fmter)
+	}
+	return out
+}
+
+func appendBraceClose(out []byte, nonEmpty bool, fmter fmter) []byte {
+	if nonEmpty {
+		out = appendBraceSpace(out, fmter)
+	}
+	return append(out, '}')
+}
+
+func appendBraceSpace(out []byte, fmter fmter) []byte {
+	if fmter.conf.BraceSpace {
+		return append(out, ' ')
+	}
+	return out
+}
+
 func appendIndent(out []byte, fmter fmter) []byte {
 	for i := 0; i < fmter.indent; i++ {
 		out = append(out, fmter.conf.Indent...)
@@ -711,7 +3446,14 @@ func appendIndent(out []byte, fmter fmter) []byte {
 	return out
 }
 
+// Negative zero is printed differently, and omitting it would change the value.
+func isPositiveZero(num float64) bool { return num == 0 && !math.Signbit(num) }
+
 func isZeroOrShouldOmit(rval reflect.Value) bool {
+	if isZero, ok := semanticZero(rval); ok {
+		return isZero
+	}
+
 	switch rval.Type().Kind() {
 	case reflect.Bool:
 		return !rval.Bool()
@@ -728,14 +3470,12 @@ func isZeroOrShouldOmit(rval reflect.Value) bool {
 	case reflect.UnsafePointer:
 		return rval.Convert(reflect.TypeOf(unsafe.Pointer(nil))).Interface().(unsafe.Pointer) == nil
 
-	case reflect.Float32:
-		return rval.Float() == 0
-
-	case reflect.Float64:
-		return rval.Float() == 0
+	case reflect.Float32, reflect.Float64:
+		return isPositiveZero(rval.Float())
 
 	case reflect.Complex64, reflect.Complex128:
-		return rval.Complex() == 0
+		num := rval.Complex()
+		return isPositiveZero(real(num)) && isPositiveZero(imag(num))
 
 	case reflect.Array:
 		return isZero(rval)
@@ -769,8 +3509,131 @@ func isZeroOrShouldOmit(rval reflect.Value) bool {
 	}
 }
 
+/*
+Zero-value checks for types whose zero-ness can't be determined from their
+bytes, for example because they contain caches or unused buffers. Used when
+omitting zero fields, see "Config.ZeroFields". Types with a method
+"IsZero() bool", such as "time.Time", are supported automatically and don't
+need to be registered. Not safe for concurrent modification: register custom
+types at init time.
+*/
+var ZeroChecks = map[reflect.Type]func(reflect.Value) bool{
+	reflect.TypeOf(big.Int{}): func(rval reflect.Value) bool {
+		val := rval.Interface().(big.Int)
+		return val.Sign() == 0
+	},
+	reflect.TypeOf(big.Float{}): func(rval reflect.Value) bool {
+		val := rval.Interface().(big.Float)
+		return val.Sign() == 0
+	},
+	reflect.TypeOf(big.Rat{}): func(rval reflect.Value) bool {
+		val := rval.Interface().(big.Rat)
+		return val.Sign() == 0
+	},
+	reflect.TypeOf(bytes.Buffer{}): func(rval reflect.Value) bool {
+		val := rval.Interface().(bytes.Buffer)
+		return val.Len() == 0
+	},
+}
+
+var isZeroerType = reflect.TypeOf((*interface{ IsZero() bool })(nil)).Elem()
+
+// Uses "ZeroChecks" or the "IsZero" method. The boolean is false if neither
+// applies. Pointers and interfaces are excluded: they're zero only when nil.
+func semanticZero(rval reflect.Value) (bool, bool) {
+	rtype := rval.Type()
+	switch rtype.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return false, false
+	}
+
+	check := ZeroChecks[rtype]
+	if check != nil {
+		return check(rval), true
+	}
+	if rtype.Implements(isZeroerType) {
+		return rval.Interface().(interface{ IsZero() bool }).IsZero(), true
+	}
+	return false, false
+}
+
+// True if the struct should be printed on a single line in multiline mode.
+// See "Config.InlineLeafStructs".
+func (self fmter) inlineStruct(rtype reflect.Type) bool {
+	if !(self.conf.InlineLeafStructs || self.conf.Compactness >= 1) || self.conf.ForceMultiline {
+		return false
+	}
+	return structMetaOf(rtype).leaf
+}
+
+// In multiline mode, puts the closing brace of an empty literal on a separate
+// line. See "Config.ForceMultiline".
+func appendEmptyMultiline(out []byte, count int, fmter fmter) []byte {
+	if count > 0 || !fmter.conf.ForceMultiline {
+		return out
+	}
+	out = appendNewline(out, fmter)
+	return appendIndent(out, fmter)
+}
+
+// True if a map with primitive keys and values fits on a single line in
+// multiline mode. See "Config.InlineMaps".
+func (self fmter) inlineMap(rval reflect.Value) bool {
+	rtype := rval.Type()
+	return self.conf.InlineMaps > 0 && !self.conf.ForceMultiline &&
+		isPrimitive(rtype.Key()) && isPrimitive(rtype.Elem()) &&
+		self.measure(self.conf.InlineMaps, rval, appendMap)
+}
+
+// True if a list with the given element type and count should be printed on a
+// single line in multiline mode. See "Config.InlineElems".
+func (self fmter) inlineList(elemType reflect.Type, count int) bool {
+	if self.conf.ForceMultiline {
+		return false
+	}
+
+	limit := self.conf.InlineElems
+	if limit == 0 {
+		switch {
+		case self.conf.Compactness >= 3:
+			limit = math.MaxInt32
+		case self.conf.Compactness == 2:
+			limit = 96
+		default:
+			limit = 48
+		}
+	}
+	if count >= limit {
+		return false
+	}
+
+	if self.conf.InlineType != nil {
+		return self.conf.InlineType(elemType)
+	}
+	if self.conf.Compactness >= 3 && elemType.Kind() == reflect.Struct && self.inlineStruct(elemType) {
+		return true
+	}
+	if self.conf.Compactness >= 2 && elemType.Kind() == reflect.String {
+		return true
+	}
+	return !mayRequireMultiline(elemType)
+}
+
+func (self Config) bytesPerRow() int {
+	perRow := self.Columns[reflect.Uint8]
+	if perRow > 0 {
+		return perRow
+	}
+	if self.Compactness >= 3 {
+		return 16
+	}
+	return 8
+}
+
 func mayRequireMultiline(rtype reflect.Type) bool {
 	switch rtype.Kind() {
+	case reflect.Ptr:
+		return mayRequireMultiline(rtype.Elem())
 	case reflect.Array, reflect.Chan, reflect.Func, reflect.Interface,
 		reflect.Map, reflect.Slice, reflect.String, reflect.Struct:
 		return true
@@ -830,30 +3693,77 @@ func appendTypeName(out []byte, rtype reflect.Type, fmter fmter) []byte {
 			out = append(out, ']')
 			out = appendTypeName(out, rtype.Elem(), fmter)
 			return out
+
+		case reflect.Ptr:
+			out = append(out, '*')
+			out = appendTypeName(out, rtype.Elem(), fmter)
+			return out
+
+		case reflect.Func:
+			out = append(out, `func`...)
+			out = appendFuncSignature(out, rtype, fmter)
+			return out
+
+		case reflect.Interface:
+			if rtype.NumMethod() == 0 && fmter.conf.langAtLeast(18) {
+				return append(out, `any`...)
+			}
 		}
 		return append(out, rtype.String()...)
 	}
 
-	pkg := rtype.PkgPath()
-	if pkg == `` {
+	if rtype.PkgPath() == `` {
 		return append(out, rtype.String()...)
 	}
+	if fmter.conf.langBelow(18) && strings.IndexByte(name, '[') >= 0 {
+		panic(langVersionError{fmt.Errorf(`repr: instantiated generic type %v requires go1.18, but the language version is %q`, rtype, fmter.conf.LangVersion)})
+	}
+	return appendQualified(out, rtype, name, fmter)
+}
+
+// Appends an identifier declared in the package of the given named type,
+// qualified with the package name, respecting "Config.PackageMap".
+func appendQualified(out []byte, rtype reflect.Type, ident string, fmter fmter) []byte {
+	str := rtype.String()
+	return appendQualifiedPath(out, rtype.PkgPath(), str[:len(str)-len(rtype.Name())-1], ident, fmter)
+}
 
-	pkg, ok := fmter.conf.PackageMap[pkg]
+// Qualifies the identifier with the name of its package, as defined by
+// "Config.PackageMap", falling back on the provided name.
+func appendQualifiedPath(out []byte, path, name, ident string, fmter fmter) []byte {
+	pkg, ok := fmter.conf.PackageMap[path]
 	if !ok {
-		return append(out, rtype.String()...)
+		pkg = name
 	}
 
 	if pkg == `` {
-		return append(out, name...)
+		return append(out, ident...)
 	}
 
+	fmter.addImport(path, pkg)
 	out = append(out, pkg...)
 	out = append(out, '.')
-	out = append(out, name...)
+	out = append(out, ident...)
 	return out
 }
 
+func stripLinePrefix(out []byte, conf Config) []byte {
+	if conf.LinePrefix == `` {
+		return out
+	}
+	newline := conf.newline()
+	out = bytes.TrimPrefix(out, []byte(conf.LinePrefix))
+	return bytes.Replace(out, []byte(newline+conf.LinePrefix), []byte(newline), -1)
+}
+
+func validate(out []byte) error {
+	_, err := parser.ParseExpr(bytesToMutableString(out))
+	if err != nil {
+		return fmt.Errorf(`repr: output is not a valid Go expression: %v`, err)
+	}
+	return nil
+}
+
 // Questionable
 func isZero(rval reflect.Value) bool {
 	ptr, size := raw(rval)
@@ -886,10 +3796,261 @@ func bytesToMutableString(bytes []byte) string {
 	return *(*string)(unsafe.Pointer(&bytes))
 }
 
+// Value of the field in the output. See "Config.FieldValue" and
+// "Config.EmptyFields".
+func (self fmter) fieldValue(owner reflect.Type, sfield reflect.StructField, rfield reflect.Value) reflect.Value {
+	if self.conf.EmptyFields == EmptyNil {
+		rfield = EmptyNil.value(rfield)
+	}
+	if self.conf.FieldValue == nil {
+		return rfield
+	}
+
+	val, ok := self.conf.FieldValue(self.atField(sfield.Name).path, owner, sfield, rfield)
+	if !ok {
+		return rfield
+	}
+
+	out := reflect.ValueOf(val)
+	if !out.IsValid() {
+		return reflect.Zero(sfield.Type)
+	}
+	return out
+}
+
+// Fields are printed in one pass, or in two passes with "Config.ZeroFieldsLast":
+// non-zero fields first, then zero fields.
+func (self fmter) fieldPasses() int {
+	if self.conf.ZeroFields && self.conf.ZeroFieldsLast {
+		return 2
+	}
+	return 1
+}
+
+func (self fmter) skipField(sfield reflect.StructField, rfield reflect.Value, pass int) bool {
+	if self.conf.tracksPaths() {
+		child := self.atField(sfield.Name)
+		if !child.visible() {
+			return true
+		}
+		if _, ok := child.override(rfield); ok {
+			return pass > 0
+		}
+	}
+	if len(self.conf.SkipTypes) > 0 && self.conf.skipsValue(rfield) {
+		return true
+	}
+	if self.conf.Addresses == AddressOmit && isAddressKind(rfield.Kind()) {
+		return true
+	}
+	if self.conf.EmptyFields == EmptyOmit && isEmptyComposite(rfield) {
+		return true
+	}
+	if self.fieldPasses() > 1 {
+		return self.isZeroField(rfield) != (pass > 0)
+	}
+	return !self.conf.ZeroFields && self.isZeroField(rfield)
+}
+
+// Types of primitive and nil fields are elided, as well as funcs printed by
+// "Config.FuncNames" or "File.FuncStubs", which are assignable to the field as
+// is.
+func (self fmter) canElideField(rfield reflect.Value) bool {
+	return isPrimitive(rfield.Type()) || isNil(rfield) ||
+		(self.printsFuncs() && rfield.Kind() == reflect.Func)
+}
+
+// Like "isZeroOrShouldOmit", but keeps non-nil funcs printed by
+// "Config.FuncNames" or "File.FuncStubs", and zero structs kept by
+// "Config.EmptyFields".
+func (self fmter) isZeroField(rfield reflect.Value) bool {
+	if self.printsFuncs() && rfield.Kind() == reflect.Func {
+		return rfield.IsNil()
+	}
+	if self.conf.EmptyFields == EmptyKeep && rfield.Kind() == reflect.Struct {
+		return false
+	}
+	return isZeroOrShouldOmit(rfield)
+}
+
+// Name of the field in the output, or an empty string if the field should be
+// omitted. See "Config.FieldName".
+func (self fmter) fieldName(sfield reflect.StructField) string {
+	if self.conf.FieldName != nil {
+		return self.conf.FieldName(self.atField(sfield.Name).path, sfield)
+	}
+	return sfield.Name
+}
+
+// Blank fields, typically used for padding, are omitted silently.
+func (self fmter) warnUnexported(owner reflect.Type, sfield reflect.StructField) {
+	if sfield.Name != `_` {
+		self.warn(owner, `unexported fields are omitted`)
+	}
+}
+
+/*
+Returns the named type which can't be referenced by generated code, found by
+dereferencing pointers, or nil. This includes types from internal packages of
+the standard library, cgo-generated types, and instantiated generic types
+whose type arguments are printed with full package paths.
+*/
+func opaqueType(rtype reflect.Type) reflect.Type {
+	for rtype.Kind() == reflect.Ptr {
+		rtype = rtype.Elem()
+	}
+
+	name := rtype.Name()
+	if name == `` {
+		return nil
+	}
+	if strings.HasPrefix(name, `_Ctype_`) || strings.HasPrefix(name, `_Cgo`) ||
+		strings.ContainsRune(name, '/') || isStdInternalPath(rtype.PkgPath()) {
+		return rtype
+	}
+	return nil
+}
+
+// Packages of the standard library have no dots in the first path element.
+// Their internal and vendored packages can't be imported by other modules.
+func isStdInternalPath(path string) bool {
+	first := path
+	if index := strings.IndexByte(path, '/'); index >= 0 {
+		first = path[:index]
+	}
+	if first == `` || strings.ContainsRune(first, '.') {
+		return false
+	}
+	return first == `internal` || first == `vendor` ||
+		strings.Contains(path, `/internal/`) || strings.HasSuffix(path, `/internal`)
+}
+
+// Prints a value of an opaque type as "nil" with a comment naming the type,
+// which compiles wherever the type is a pointer or interface.
+func appendOpaque(out []byte, rtype, opaque reflect.Type, fmter fmter) []byte {
+	fmter.warn(rtype, `type can't be referenced, printed as nil`)
+	name := opaque.Name()
+	if path := opaque.PkgPath(); path != `` {
+		name = path + `.` + name
+	}
+	return appendPlaceholder(out, name)
+}
+
+// See "Config.Addresses".
+func isAddressKind(kind reflect.Kind) bool {
+	return kind == reflect.Uintptr || kind == reflect.UnsafePointer
+}
+
+// Prints a non-zero address as zero, according to "Config.Addresses".
+func appendHiddenAddress(out []byte, rval reflect.Value, fmter fmter) []byte {
+	out = appendCastPrefix(out, rval, fmter)
+	if rval.Kind() == reflect.UnsafePointer {
+		out = append(out, `nil`...)
+	} else {
+		out = append(out, '0')
+	}
+	out = appendCastSuffix(out, rval, fmter)
+
+	if fmter.conf.Addresses == AddressPlaceholder {
+		out = append(out, ` /* address */`...)
+	}
+	return out
+}
+
+func appendPlaceholder(out []byte, comment string) []byte {
+	out = append(out, `nil /* `...)
+	out = appendCommentText(out, comment)
+	return append(out, ` */`...)
+}
+
+// See "Config.SkipTypes".
+func (self Config) skipsType(rtype reflect.Type) bool {
+	for _, skip := range self.SkipTypes {
+		if rtype == skip {
+			return true
+		}
+	}
+	return false
+}
+
+// Checks both the static type and, for interfaces, the dynamic type.
+func (self Config) skipsValue(rval reflect.Value) bool {
+	if self.skipsType(rval.Type()) {
+		return true
+	}
+	return rval.Kind() == reflect.Interface && !rval.IsNil() && self.skipsType(rval.Elem().Type())
+}
+
+/*
+Per-type struct metadata, computed once per type and shared between calls.
+"reflect.Type.Field" is relatively expensive, and struct-heavy data would call
+it for every field of every struct. Field names are prebuilt with the colon, so
+printing them is a single copy.
+*/
+type structMeta struct {
+	fields []fieldMeta
+
+	// True if all exported fields are primitive. See "Config.InlineLeafStructs".
+	leaf bool
+}
+
+type fieldMeta struct {
+	reflect.StructField
+	exported bool
+
+	// Field name followed by the default colon, such as "Name: ". Used when
+	// neither "Config.FieldName" nor "Config.Colon" is set.
+	head []byte
+}
+
+var structMetaCache sync.Map
+
+func structMetaOf(rtype reflect.Type) *structMeta {
+	cached, ok := structMetaCache.Load(rtype)
+	if ok {
+		return cached.(*structMeta)
+	}
+
+	meta := &structMeta{fields: make([]fieldMeta, rtype.NumField()), leaf: true}
+	for i := range meta.fields {
+		sfield := rtype.Field(i)
+		exported := isSfieldExported(sfield)
+		meta.fields[i] = fieldMeta{
+			StructField: sfield,
+			exported:    exported,
+			head:        []byte(sfield.Name + defaultColon),
+		}
+		if exported && !isPrimitive(sfield.Type) {
+			meta.leaf = false
+		}
+	}
+
+	cached, _ = structMetaCache.LoadOrStore(rtype, meta)
+	return cached.(*structMeta)
+}
+
 func isSfieldExported(sfield reflect.StructField) bool {
 	return sfield.PkgPath == ``
 }
 
+/*
+Reports whether values in a position of the given declared type, such as the
+element type of a slice, may be printed without their type. Composite literals
+may omit their type only where it's identical to the declared type. This rules
+out interfaces, where the dynamic types of values vary and must be printed, and
+named pointer types, since "&T" may be elided only where the declared type is
+exactly "*T".
+*/
 func canElideType(rtype reflect.Type, fmter fmter) bool {
-	return !fmter.conf.ForceConstructorName && !isInterface(rtype)
+	if fmter.conf.ForceConstructorName {
+		return false
+	}
+	switch rtype.Kind() {
+	case reflect.Interface:
+		return false
+	case reflect.Ptr:
+		return rtype.Name() == ``
+	default:
+		return true
+	}
 }
@@ -1,9 +1,22 @@
 package repr
 
 import (
+	"bytes"
+	"encoding/hex"
 	"encoding/json"
 	"go/format"
+	"math"
+	"math/big"
+	"math/rand"
+	"os"
+	"reflect"
+	"regexp"
+	"runtime/debug"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
+	"unsafe"
 
 	"github.com/mitranim/repr/test"
 )
@@ -130,6 +143,56 @@ func TestForceConstructorNames(t *testing.T) {
 	}
 }
 
+type testAbiTypePtr *test.AbiType
+
+func TestElideTypes(t *testing.T) {
+	actual := String(test.Abi{
+		test.AbiFunction{Name: "one"},
+		&test.AbiEvent{Name: "two"},
+		test.AbiKindUint,
+		[]test.AbiType{{Type: "bool"}},
+		nil,
+	})
+	expected := `test.Abi{
+	test.AbiFunction{
+		Name: "one",
+	},
+	&test.AbiEvent{
+		Name: "two",
+	},
+	test.AbiKind(2),
+	[]test.AbiType{
+		{
+			Type: "bool",
+		},
+	},
+	nil,
+}`
+	if actual != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+	}
+
+	actual = String(map[string]testAbiTypePtr{`one`: &test.AbiType{Type: "bool"}})
+	expected = `map[string]repr.testAbiTypePtr{
+	"one": &test.AbiType{
+		Type: "bool",
+	},
+}`
+	if actual != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+	}
+
+	actual = String([]*test.AbiType{{Type: "bool"}})
+	expected = `[]*test.AbiType{
+	{
+		Type: "bool",
+	},
+}`
+	if actual != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+	}
+}
+
 func TestBytesHex(t *testing.T) {
 	actual := String(testBytes)
 	expected := testOutputBytesHex
@@ -138,6 +201,1910 @@ func TestBytesHex(t *testing.T) {
 	}
 }
 
+func TestValidate(t *testing.T) {
+	conf := Default
+	conf.Validate = true
+
+	_, err := BytesE(testStructure, conf)
+	if err != nil {
+		t.Fatalf("unexpected validation error: %v", err)
+	}
+
+	_, err = BytesE(invalidGoStringer{}, conf)
+	if err == nil {
+		t.Fatalf("expected validation error for invalid output")
+	}
+
+	conf.Validate = false
+	_, err = BytesE(invalidGoStringer{}, conf)
+	if err != nil {
+		t.Fatalf("unexpected error with validation disabled: %v", err)
+	}
+}
+func TestNewlineAndSpacing(t *testing.T) {
+	type Data struct {
+		List []int
+		Dict map[string]int
+	}
+	val := Data{List: []int{1, 2}, Dict: map[string]int{"one": 1}}
+
+	conf := Default
+	conf.Newline = "\r\n"
+	conf.Colon = ":"
+	actual := StringC(val, conf)
+	expected := "repr.Data{\r\n\tList:[]int{1, 2},\r\n\tDict:map[string]int{\r\n\t\t\"one\":1,\r\n\t},\r\n}"
+	if actual != expected {
+		t.Fatalf("expected output:\n%q\nactual output:\n%q", expected, actual)
+	}
+
+	conf.Colon = " : "
+	actual = StringC(val, conf)
+	expected = "repr.Data{\r\n\tList : []int{1, 2},\r\n\tDict : map[string]int{\r\n\t\t\"one\" : 1,\r\n\t},\r\n}"
+	if actual != expected {
+		t.Fatalf("expected output:\n%q\nactual output:\n%q", expected, actual)
+	}
+
+	for _, colon := range []string{" = ", "=", "::", "\t:", ": // "} {
+		conf.Colon = colon
+		_, err := StringE(val, conf)
+		if err == nil {
+			t.Fatalf(`expected an error for the separator %q`, colon)
+		}
+	}
+
+	conf = Config{BraceSpace: true}
+	actual = StringC(val, conf)
+	expected = `repr.Data{ List: []int{ 1, 2 }, Dict: map[string]int{ "one": 1 } }`
+	if actual != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+	}
+
+	actual = StringC(Data{List: []int{}}, conf)
+	expected = `repr.Data{ List: []int{} }`
+	if actual != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+	}
+}
+
+func TestLinePrefix(t *testing.T) {
+	conf := Default
+	conf.LinePrefix = `// `
+	conf.Validate = true
+
+	actual, err := StringE(test.AbiType{Type: "bool", Kind: test.AbiKindBool}, conf)
+	if err != nil {
+		t.Fatalf("unexpected validation error: %v", err)
+	}
+
+	expected := `// test.AbiType{
+// 	Type: "bool",
+// 	Kind: 1,
+// }`
+	if actual != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+	}
+}
+
+func TestComment(t *testing.T) {
+	val := test.AbiType{Type: "*/ bool /*"}
+
+	actual := Comment(val, Default)
+	expected := `// test.AbiType{
+// 	Type: "*/ bool /*",
+// }`
+	if actual != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+	}
+
+	actual = BlockComment(val, Default)
+	expected = `/*
+test.AbiType{
+	Type: "\x2a/ bool /*",
+}
+*/`
+	if actual != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+	}
+
+	actual = BlockComment(val, Config{})
+	expected = `/* test.AbiType{Type: "\x2a/ bool /*"} */`
+	if actual != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+	}
+
+	_, err := format.Source([]byte("package p\n\n" + BlockComment(val, Default) + "\nvar _ = 1\n"))
+	if err != nil {
+		t.Fatalf("block comment broke the surrounding code: %v", err)
+	}
+
+	// Comments added by repr are escaped like string literals.
+	actual = BlockComment([]string{`*/`, `two`, `three`}, Config{MaxElems: 1})
+	expected = `/* []string{"\x2a/" /* 2 more elements \x2a/} */`
+	if actual != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+	}
+
+	_, err = format.Source([]byte("package p\n\n" + actual + "\nvar _ = 1\n"))
+	if err != nil {
+		t.Fatalf("block comment broke the surrounding code: %v", err)
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	val := test.AbiType{Type: "```"}
+
+	actual := Markdown(val, `Input:`, Default)
+	expected := "Input:\n\n```go\ntest.AbiType{\n\tType: \"\\x60\\x60\\x60\",\n}\n```"
+	if actual != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+	}
+
+	actual = Markdown(1, ``, Config{})
+	expected = "```go\n1\n```"
+	if actual != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+	}
+}
+
+func TestStringers(t *testing.T) {
+	conf := Config{Stringers: true}
+	actual := StringC(test.AbiType{Type: "uint", Kind: test.AbiKindUint}, conf)
+	expected := `test.AbiType{Type: "uint", Kind: 2 /* AbiKindUint */}`
+	if actual != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	type Node struct {
+		Name  string
+		Nums  []int
+		Bytes []byte
+		Next  *Node
+	}
+
+	val := Node{
+		Name:  "привет",
+		Nums:  []int{1, 2, 3, 4, 5},
+		Bytes: []byte{1, 2, 3, 4, 5},
+		Next:  &Node{Next: &Node{Name: "deep"}},
+	}
+
+	conf := Config{MaxDepth: 2, MaxElems: 3, MaxStringLen: 5}
+	actual := StringC(val, conf)
+	expected := `repr.Node{Name: "пр" /* 12 bytes total */, Nums: []int{1, 2, 3 /* 2 more elements */}, Bytes: []uint8{0x01, 0x02, 0x03 /* 2 more elements */}, Next: &repr.Node{Next: &repr.Node{/* depth limit */}}}`
+	if actual != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+	}
+
+	conf = DebugConfig
+	conf.MaxElems = 2
+	actual = StringC(map[int][]int{1: {10, 20, 30}, 2: nil, 3: nil}, conf)
+	expected = `map[int][]int{
+	1: []int{10, 20 /* 1 more element */},
+	2: nil,
+	/* 1 more entry */
+}`
+	if actual != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+	}
+
+	_, err := format.Source([]byte("package p\nvar _ = " + actual))
+	if err != nil {
+		t.Fatalf("failed to format via gofmt: %v", err)
+	}
+
+	// Truncated maps are sorted even without "SortKeys".
+	conf = Config{MaxElems: 2}
+	big := map[int]bool{}
+	for i := 0; i < 100; i++ {
+		big[i] = true
+	}
+	for i := 0; i < 10; i++ {
+		actual = StringC(big, conf)
+		expected = `map[int]bool{0: true, 1: true /* 98 more entries */}`
+		if actual != expected {
+			t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+		}
+	}
+}
+
+func TestPresets(t *testing.T) {
+	actual := StringC(map[string]int{"b": 2, "a": 1}, CompactConfig)
+	expected := `map[string]int{"a": 1, "b": 2}`
+	if actual != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+	}
+
+	_, err := BytesE(testStructure, CodegenConfig)
+	if err != nil {
+		t.Fatalf("unexpected validation error: %v", err)
+	}
+}
+
+func TestByDepth(t *testing.T) {
+	conf := Default
+	conf.ByDepth = func(depth int, conf Config) Config {
+		conf.ZeroFields = depth == 0
+		if depth >= 2 {
+			conf.Indent = ``
+		}
+		return conf
+	}
+
+	val := test.AbiParam{
+		Name: "one",
+		AbiType: test.AbiType{
+			Type: "uint32[]",
+			Elem: &test.AbiType{Type: "uint32", Kind: test.AbiKindUint},
+		},
+	}
+
+	actual := StringC(val, conf)
+	expected := `test.AbiParam{
+	Name: "one",
+	Type: "",
+	Components: nil,
+	Indexed: false,
+	AbiType: test.AbiType{
+		Type: "uint32[]",
+		Elem: &test.AbiType{Type: "uint32", Kind: 2},
+	},
+}`
+	if actual != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+	}
+}
+
+func TestColumns(t *testing.T) {
+	conf := Default
+	conf.Columns = map[reflect.Kind]int{reflect.Uint8: 4, reflect.Uint32: 4, reflect.Uint16: 3}
+
+	check := func(val interface{}, expected string) {
+		t.Helper()
+		actual := StringC(val, conf)
+		if actual != expected {
+			t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+		}
+	}
+
+	check([]uint32{
+		0x6a09e667, 0xbb67ae85, 0x3c6ef372, 0xa54ff53a,
+		0x510e527f, 0x9b05688c, 0x1f83d9ab, 0x5be0cd19,
+		0x1,
+	}, `[]uint32{
+	0x6a09e667, 0xbb67ae85, 0x3c6ef372, 0xa54ff53a,
+	0x510e527f, 0x9b05688c, 0x1f83d9ab, 0x5be0cd19,
+	0x00000001,
+}`)
+
+	check([4]uint16{1, 2, 0xffff}, `[4]uint16{
+	0x0001, 0x0002, 0xffff,
+	0x0000,
+}`)
+
+	check([]uint16{1, 2}, `[]uint16{0x0001, 0x0002}`)
+	check([]byte{1, 2, 3, 4, 5}, `[]uint8{
+	0x01, 0x02, 0x03, 0x04,
+	0x05,
+}`)
+
+	// Kinds missing from the map are unaffected.
+	check([]uint64{1, 2}, `[]uint64{1, 2}`)
+
+	conf.MaxElems = 5
+	check([]uint32{1, 2, 3, 4, 5, 6, 7}, `[]uint32{
+	0x00000001, 0x00000002, 0x00000003, 0x00000004,
+	0x00000005,
+	/* 2 more elements */
+}`)
+}
+
+func TestNonFiniteFloats(t *testing.T) {
+	type Temp float32
+
+	check := func(val interface{}, expected string) {
+		t.Helper()
+		actual := StringC(val, CompactConfig)
+		if actual != expected {
+			t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+		}
+	}
+
+	check(math.NaN(), `math.NaN()`)
+	check(float32(math.Inf(1)), `float32(math.Inf(1))`)
+	check(Temp(math.Inf(-1)), `repr.Temp(math.Inf(-1))`)
+	check([]Temp{1, Temp(math.NaN())}, `[]repr.Temp{1, repr.Temp(math.NaN())}`)
+
+	check(
+		map[float64]string{
+			math.NaN():           "two",
+			1:                    "",
+			math.Inf(-1):         "",
+			math.Copysign(0, -1): "",
+			math.NaN():           "one",
+			math.Inf(1):          "",
+			-2.5:                 "",
+		},
+		`map[float64]string{math.Inf(-1): "", -2.5: "", math.Copysign(0, -1): "", 1: "", math.Inf(1): "", math.NaN(): "one", math.NaN(): "two"}`,
+	)
+
+	var decls Decls
+	decls.AddNamed(`vals`, map[float32]bool{float32(math.NaN()): true})
+	file := File{Package: `fixtures`, Decls: decls}
+	out, err := file.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `package fixtures
+
+import (
+	"math"
+)
+
+var vals = map[float32]bool{float32(math.NaN()): true}
+`
+	if string(out) != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, string(out))
+	}
+
+	negZero := math.Copysign(0, -1)
+	check(negZero, `math.Copysign(0, -1)`)
+	check([2]float32{float32(negZero), 0}, `[2]float32{float32(math.Copysign(0, -1)), 0}`)
+	check(map[string]float64{`one`: negZero}, `map[string]float64{"one": math.Copysign(0, -1)}`)
+	check(
+		[]interface{}{math.Inf(1), float32(negZero), complex(1, negZero), complex64(complex(math.NaN(), 2)), 1 + 2i},
+		`[]interface {}{math.Inf(1), float32(math.Copysign(0, -1)), complex(1, math.Copysign(0, -1)), complex64(complex(math.NaN(), 2)), (1+2i)}`,
+	)
+
+	// Negative zeros aren't omitted as zero values.
+	type Signed struct {
+		Float   float64
+		Complex complex128
+	}
+	check(Signed{negZero, complex(0, negZero)}, `repr.Signed{Float: math.Copysign(0, -1), Complex: complex(0, math.Copysign(0, -1))}`)
+	check(Signed{Complex: complex(negZero, 0)}, `repr.Signed{Complex: complex(math.Copysign(0, -1), 0)}`)
+
+	conf := CompactConfig
+	conf.Sparse = true
+	var sparse [8]float64
+	sparse[2] = negZero
+	if actual := StringC(sparse, conf); actual != `[8]float64{2: math.Copysign(0, -1)}` {
+		t.Fatalf(`unexpected sparse output: %v`, actual)
+	}
+
+	conf = CompactConfig
+	conf.TypedNumbers = true
+	actual := StringC([]interface{}{float32(math.NaN()), float32(1.5), negZero}, conf)
+	if actual != `[]interface {}{float32(math.NaN()), float32(1.5), math.Copysign(0, -1)}` {
+		t.Fatalf(`unexpected output with typed numbers: %v`, actual)
+	}
+
+	res, err := Format(map[string]complex128{`one`: complex(negZero, 0)}, CompactConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(res.Imports, map[string]string{`math`: `math`}) {
+		t.Fatalf(`expected the "math" import, got %v`, res.Imports)
+	}
+}
+
+type testPriority int
+
+// Orders higher priorities first.
+func (self testPriority) Less(other testPriority) bool { return self > other }
+
+func TestSortKeysByValue(t *testing.T) {
+	check := func(val interface{}, expected string) {
+		t.Helper()
+		actual := StringC(val, CompactConfig)
+		if actual != expected {
+			t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+		}
+	}
+
+	check(map[int]bool{10: true, 2: true, 1: true, -3: true}, `map[int]bool{-3: true, 1: true, 2: true, 10: true}`)
+	check(map[uint16]bool{10: true, 2: true, 1: true}, `map[uint16]bool{1: true, 2: true, 10: true}`)
+	check(map[bool]int{true: 1, false: 0}, `map[bool]int{false: 0, true: 1}`)
+	check(map[string]int{"b": 2, "\x00": 0, "a": 1}, `map[string]int{"\x00": 0, "a": 1, "b": 2}`)
+	check(map[testPriority]string{1: "low", 3: "high", 2: "mid"}, `map[repr.testPriority]string{3: "high", 2: "mid", 1: "low"}`)
+}
+
+func TestSortKeyHook(t *testing.T) {
+	type Key struct {
+		Name  string
+		Order int
+		id    int
+	}
+
+	val := map[Key]int{
+		{`one`, 3, 0}:   1,
+		{`two`, 1, 0}:   2,
+		{`three`, 2, 0}: 3,
+		{`three`, 2, 1}: 4,
+	}
+
+	conf := CompactConfig
+	actual := StringC(val, conf)
+	expected := `map[repr.Key]int{{Name: "one", Order: 3}: 1, {Name: "three", Order: 2}: 3, {Name: "three", Order: 2}: 4, {Name: "two", Order: 1}: 2}`
+	if actual != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+	}
+
+	var paths []string
+	conf.SortKey = func(path string, key interface{}) interface{} {
+		paths = append(paths, path)
+		return key.(Key).Order
+	}
+	actual = StringC(val, conf)
+	expected = `map[repr.Key]int{{Name: "two", Order: 1}: 2, {Name: "three", Order: 2}: 3, {Name: "three", Order: 2}: 4, {Name: "one", Order: 3}: 1}`
+	if actual != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+	}
+
+	paths = nil
+	StringC(struct{ Index map[Key]int }{map[Key]int{{Order: 1}: 1}}, conf)
+	if !reflect.DeepEqual(paths, []string{`.Index`}) {
+		t.Fatalf(`unexpected paths %q`, paths)
+	}
+}
+
+func TestMapPairs(t *testing.T) {
+	conf := CodegenConfig
+	conf.MapPairs = true
+
+	val := map[string]int{`two`: 20, `one`: 10}
+
+	check := func(val interface{}, expected string) {
+		t.Helper()
+		actual := StringC(val, conf)
+		if actual != expected {
+			t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+		}
+	}
+
+	check(val, `[]struct {
+	Key   string
+	Value int
+}{
+	{Key: "one", Value: 10},
+	{Key: "two", Value: 20},
+}`)
+
+	check(map[string][]int{`one`: {1}}, `[]struct {
+	Key   string
+	Value []int
+}{
+	{
+		Key: "one",
+		Value: []int{1},
+	},
+}`)
+
+	check(struct{ Routes map[string]int }{val}, `struct { Routes map[string]int }{
+	Routes: []struct {
+		Key   string
+		Value int
+	}{
+		{Key: "one", Value: 10},
+		{Key: "two", Value: 20},
+	},
+}`)
+
+	check(map[string]int(nil), `[]struct {
+	Key   string
+	Value int
+}(nil)`)
+
+	conf.MapPairsFunc = `newRoutes`
+	conf.Indent = ``
+	check(val, `newRoutes([]struct{Key string; Value int}{{Key: "one", Value: 10}, {Key: "two", Value: 20}})`)
+	check(map[string]int{}, `newRoutes([]struct{Key string; Value int}{})`)
+}
+
+func TestTypedNils(t *testing.T) {
+	type Outer struct {
+		Err   error
+		Inner *Outer
+		Any   interface{}
+	}
+
+	conf := CompactConfig
+	conf.ZeroFields = true
+
+	val := Outer{Err: (*json.SyntaxError)(nil)}
+
+	actual := StringC(val, conf)
+	expected := `repr.Outer{Err: nil, Inner: nil, Any: nil}`
+	if actual != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+	}
+
+	conf.TypedNils = true
+	val.Any = (func())(nil)
+	actual = StringC(val, conf)
+	expected = `repr.Outer{Err: (*json.SyntaxError)(nil), Inner: nil, Any: (func())(nil)}`
+	if actual != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+	}
+
+	conf.ZeroFields = false
+	actual = StringC([]interface{}{nil, (*Outer)(nil), (*int)(nil)}, conf)
+	expected = `[]interface {}{nil, (*repr.Outer)(nil), (*int)(nil)}`
+	if actual != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+	}
+}
+
+type testZeroer struct{ Cache []int }
+
+func (self testZeroer) IsZero() bool { return true }
+
+func TestSemanticZero(t *testing.T) {
+	type Outer struct {
+		Time   time.Time
+		Int    big.Int
+		Buf    bytes.Buffer
+		Custom testZeroer
+		Num    int
+	}
+
+	var val Outer
+	val.Time = time.Time{}.In(time.FixedZone(`UTC+1`, 3600))
+	val.Int.SetInt64(123).SetInt64(0)
+	val.Buf.WriteString(`hello`)
+	val.Buf.Reset()
+	val.Custom.Cache = []int{1}
+	val.Num = 1
+
+	actual := StringC(val, CompactConfig)
+	expected := `repr.Outer{Num: 1}`
+	if actual != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+	}
+}
+
+func TestOutputVersion(t *testing.T) {
+	conf := Default
+	conf.OutputVersion = OutputV1
+	if StringC(testStructure, conf) != String(testStructure) {
+		t.Fatalf(`expected the latest version to match the default`)
+	}
+
+	conf.OutputVersion = LatestOutputVersion + 1
+	_, err := StringE(testStructure, conf)
+	if err == nil {
+		t.Fatalf(`expected an error for an unknown version, got %v`, err)
+	}
+}
+
+func TestLangVersion(t *testing.T) {
+	val := map[string]interface{}{`list`: []interface{}{1}}
+
+	conf := CompactConfig
+	actual := StringC(val, conf)
+	if actual != `map[string]interface {}{"list": []interface {}{1}}` {
+		t.Fatalf(`unexpected output without a version: %v`, actual)
+	}
+
+	for _, ver := range []string{`go1.17`, `go1.17rc2`} {
+		conf.LangVersion = ver
+		if StringC(val, conf) != actual {
+			t.Fatalf(`unexpected output for %q: %v`, ver, StringC(val, conf))
+		}
+	}
+
+	for _, ver := range []string{`go1.18`, `1.21`, `go1.21rc1`, `go1.22.3`, `go1.23rc1`} {
+		conf.LangVersion = ver
+		actual = StringC(val, conf)
+		if actual != `map[string]any{"list": []any{1}}` {
+			t.Fatalf(`unexpected output for %q: %v`, ver, actual)
+		}
+	}
+
+	var out map[string]interface{}
+	err := Parse([]byte(actual), &out, nil)
+	if err != nil || !reflect.DeepEqual(out, val) {
+		t.Fatalf(`failed to parse %v: %v, %v`, actual, out, err)
+	}
+
+	for _, ver := range []string{`go2`, `1`, `go1.`, `go1.x`, `gox1.21`, `rc1`} {
+		conf.LangVersion = ver
+		_, err = StringE(val, conf)
+		if err == nil {
+			t.Fatalf(`expected an error for %q`, ver)
+		}
+	}
+
+	conf.LangVersion = `go1.17`
+	conf.PtrFunc = `ptr.To`
+	_, err = StringE(val, conf)
+	if err == nil {
+		t.Fatalf(`expected an error for a generic helper before go1.18`)
+	}
+}
+
+func TestPooled(t *testing.T) {
+	conf := Default
+	conf.Pooled = true
+
+	one := BytesC(testStructure, conf)
+	two := BytesC([]int{1, 2, 3}, conf)
+
+	if string(one) != String(testStructure) {
+		t.Fatalf(`pooled output doesn't match regular output`)
+	}
+	if string(two) != `[]int{1, 2, 3}` {
+		t.Fatalf(`unexpected pooled output: %s`, two)
+	}
+}
+
+func TestSparse(t *testing.T) {
+	conf := CompactConfig
+	conf.Sparse = true
+
+	check := func(val interface{}, expected string) {
+		t.Helper()
+		actual := StringC(val, conf)
+		if actual != expected {
+			t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+		}
+	}
+
+	var table [256]int
+	table[5] = 7
+	table[130] = 9
+	check(table, `[256]int{5: 7, 130: 9}`)
+	check(table[:200], `[]int{5: 7, 130: 9, 199: 0}`)
+	check(table[:131], `[]int{5: 7, 130: 9}`)
+	check([8]int{}, `[8]int{}`)
+	check(make([]string, 8), `[]string{7: ""}`)
+
+	var bytes [16]byte
+	bytes[3] = 0xff
+	check(bytes, `[16]uint8{3: 0xff}`)
+	check(bytes[:], `[]uint8{3: 0xff, 15: 0x00}`)
+
+	// Dense and short lists are printed normally.
+	check([]int{1, 2, 3, 0, 0, 0, 0, 0}, `[]int{1, 2, 3, 0, 0, 0, 0, 0}`)
+	check([]int{0, 0, 1}, `[]int{0, 0, 1}`)
+
+	conf.Indent = "\t"
+	check([]struct{ Val int }{9: {1}}, `[]struct { Val int }{
+	9: {
+		Val: 1,
+	},
+}`)
+}
+
+func TestIndexKeys(t *testing.T) {
+	conf := CompactConfig
+	conf.IndexKeys = true
+
+	check := func(val interface{}, expected string) {
+		t.Helper()
+		actual := StringC(val, conf)
+		if actual != expected {
+			t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+		}
+	}
+
+	check([7]string{`sun`, `mon`}, `[7]string{0: "sun", 1: "mon", 2: "", 3: "", 4: "", 5: "", 6: ""}`)
+	check([]byte{1, 2}, `[]uint8{0: 0x01, 1: 0x02}`)
+	check([]int{}, `[]int{}`)
+
+	conf.Sparse = true
+	check(make([]int, 8), `[]int{7: 0}`)
+	check([]int{1, 2}, `[]int{0: 1, 1: 2}`)
+}
+
+func TestTypedNumbers(t *testing.T) {
+	conf := CompactConfig
+	conf.TypedNumbers = true
+
+	var val interface{}
+	err := json.Unmarshal([]byte(`{"one": 1, "half": 0.5, "list": [2, "str", true]}`), &val)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual := StringC(val, conf)
+	expected := `map[string]interface {}{"half": 0.5, "list": []interface {}{float64(2), "str", true}, "one": float64(1)}`
+	if actual != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+	}
+
+	actual = StringC([]interface{}{1, int64(5), byte(0xff), float32(1.5), 'x', math.NaN()}, conf)
+	expected = `[]interface {}{1, int64(5), uint8(0xff), float32(1.5), int32(120), math.NaN()}`
+	if actual != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+	}
+
+	// Types are implied by the container.
+	actual = StringC([]float64{1, 2}, conf)
+	expected = `[]float64{1, 2}`
+	if actual != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+	}
+}
+
+func TestPtrFunc(t *testing.T) {
+	type Opts struct {
+		Name  *string
+		Count *int
+		Size  *uint8
+		Kind  *test.AbiKind
+		Skip  *bool
+	}
+
+	name, count, size, kind := `one`, 10, uint8(0xff), test.AbiKindUint
+
+	conf := CompactConfig
+	conf.PtrFunc = `k8s.io/utils/ptr.To`
+
+	res, err := Format(Opts{&name, &count, &size, &kind, nil}, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `repr.Opts{Name: ptr.To("one"), Count: ptr.To(10), Size: ptr.To(uint8(0xff)), Kind: ptr.To(test.AbiKind(2))}`
+	if string(res.Bytes) != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, string(res.Bytes))
+	}
+	if res.Imports[`k8s.io/utils/ptr`] != `ptr` {
+		t.Fatalf(`expected the "ptr" import, got %v`, res.Imports)
+	}
+
+	conf.PtrFunc = `newPtr`
+	actual := StringC([]*int{&count, nil}, conf)
+	if actual != `[]*int{newPtr(10), nil}` {
+		t.Fatalf(`unexpected output for a local helper: %v`, actual)
+	}
+
+	var nilPtr *int
+	actual = StringC([]**int{&nilPtr}, conf)
+	if actual != `[]**int{newPtr((*int)(nil))}` {
+		t.Fatalf(`unexpected output for a nil pointee: %v`, actual)
+	}
+}
+
+func TestKeyConversions(t *testing.T) {
+	type ID string
+	type Num int
+
+	test := func(expected string, val interface{}, conf Config) {
+		t.Helper()
+		actual := StringC(val, conf)
+		if actual != expected {
+			t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+		}
+	}
+
+	conf := CompactConfig
+	test(`map[repr.ID]int{"one": 1}`, map[ID]int{`one`: 1}, conf)
+	test(`map[repr.Num]repr.Num{1: 2}`, map[Num]Num{1: 2}, conf)
+	test(
+		`map[interface {}]int{"one": 1, 1.5: 2, 3: 3, float64(2): 4, int64(3): 5, repr.ID("one"): 6, repr.Num(3): 7, uint8(0x04): 8}`,
+		map[interface{}]int{`one`: 1, 1.5: 2, 3: 3, float64(2): 4, int64(3): 5, ID(`one`): 6, Num(3): 7, uint8(4): 8},
+		conf,
+	)
+
+	conf.KeyConversions = true
+	test(`map[repr.ID]int{repr.ID("one"): 1}`, map[ID]int{`one`: 1}, conf)
+	test(`map[repr.Num]repr.Num{repr.Num(1): 2}`, map[Num]Num{1: 2}, conf)
+	test(`map[int64]int{3: 1}`, map[int64]int{3: 1}, conf)
+	test(`map[[1]repr.Num]int{{1}: 1}`, map[[1]Num]int{{1}: 1}, conf)
+}
+
+func TestConstName(t *testing.T) {
+	conf := CompactConfig
+	var paths []string
+	conf.ConstName = func(path string, val interface{}) string {
+		paths = append(paths, path)
+		if val == test.AbiKindUint {
+			return `AbiKindUint`
+		}
+		return ``
+	}
+
+	actual := StringC([]test.AbiKind{test.AbiKindUint, test.AbiKindInt}, conf)
+	expected := `[]test.AbiKind{test.AbiKindUint, 3}`
+	if actual != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+	}
+	if !reflect.DeepEqual(paths, []string{`[0]`, `[1]`}) {
+		t.Fatalf(`unexpected paths %q`, paths)
+	}
+
+	conf.PackageMap = map[string]string{`github.com/mitranim/repr/test`: ``}
+	actual = StringC(test.AbiKindUint, conf)
+	expected = `AbiKindUint`
+	if actual != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+	}
+}
+
+func TestFieldName(t *testing.T) {
+	type Src struct {
+		Id     int    `json:"id"`
+		Name   string `json:"name"`
+		Secret string `json:"-"`
+	}
+
+	conf := CompactConfig
+	var paths []string
+	conf.FieldName = func(path string, sfield reflect.StructField) string {
+		paths = append(paths, path)
+		tag := sfield.Tag.Get(`json`)
+		if tag == `-` {
+			return ``
+		}
+		return strings.ToUpper(tag)
+	}
+
+	actual := StringC([]Src{{Id: 10, Name: `one`, Secret: `two`}}, conf)
+	expected := `[]repr.Src{{ID: 10, NAME: "one"}}`
+	if actual != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+	}
+	if !reflect.DeepEqual(paths, []string{`[0].Id`, `[0].Name`, `[0].Secret`}) {
+		t.Fatalf(`unexpected paths %q`, paths)
+	}
+
+	conf.Indent = "\t"
+	actual = StringC(Src{Secret: `two`}, conf)
+	expected = `repr.Src{}`
+	if actual != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+	}
+}
+
+func TestFieldValue(t *testing.T) {
+	type Src struct {
+		Id      string
+		Created time.Time
+		Parent  interface{}
+		Count   int
+	}
+
+	conf := CompactConfig
+	var paths []string
+	conf.FieldValue = func(path string, owner reflect.Type, sfield reflect.StructField, val reflect.Value) (interface{}, bool) {
+		paths = append(paths, path)
+		switch sfield.Name {
+		case `Id`:
+			return `<id>`, true
+		case `Created`:
+			return time.Time{}, true
+		case `Parent`:
+			return nil, true
+		}
+		return nil, false
+	}
+
+	actual := StringC(Src{Id: `a1b2`, Created: time.Now(), Parent: 123, Count: 3}, conf)
+	expected := `repr.Src{Id: "<id>", Count: 3}`
+	if actual != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+	}
+	if !reflect.DeepEqual(paths, []string{`.Id`, `.Created`, `.Parent`, `.Count`}) {
+		t.Fatalf(`unexpected paths %q`, paths)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	conf := CompactConfig
+	conf.Redact = []*regexp.Regexp{
+		regexp.MustCompile(`Bearer \S+`),
+		regexp.MustCompile(`[\w.]+@[\w.]+`),
+	}
+
+	actual := StringC(map[string]string{
+		`Authorization`: `Bearer abc.def`,
+		`mail@host.com`: `to: mail@host.com, cc: other@host.com`,
+	}, conf)
+	expected := `map[string]string{"Authorization": "***", "***": "to: ***, cc: ***"}`
+	if actual != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+	}
+
+	conf.MaxStringLen = 8
+	actual = StringC(`key: Bearer 0123456789`, conf)
+	expected = `"key: ***"`
+	if actual != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+	}
+}
+
+func TestDigestLen(t *testing.T) {
+	conf := CompactConfig
+	conf.DigestLen = 4
+
+	type Blob string
+
+	actual := StringC([]interface{}{`abcdef`, Blob(`abcdef`), []byte(`abcdef`), `abc`, []byte(nil)}, conf)
+	expected := `[]interface {}{"" /* 6 B, sha256:bef57ec7f53a6d40… */, repr.Blob("") /* 6 B, sha256:bef57ec7f53a6d40… */, []uint8(nil) /* 6 B, sha256:bef57ec7f53a6d40… */, "abc", []uint8{}}`
+	if actual != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+	}
+
+	actual = StringC(struct{ Data []byte }{make([]byte, 5<<20)}, conf)
+	expected = `struct { Data []uint8 }{Data: []uint8(nil) /* 5.0 MiB, sha256:`
+	if !strings.HasPrefix(actual, expected) {
+		t.Fatalf("expected output with prefix:\n%v\nactual output:\n%v", expected, actual)
+	}
+}
+
+func TestTruncateMiddle(t *testing.T) {
+	conf := CompactConfig
+	conf.MaxStringLen = 10
+	conf.TruncateMiddle = true
+
+	check := func(val interface{}, expected string) {
+		t.Helper()
+		actual := StringC(val, conf)
+		if actual != expected {
+			t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+		}
+	}
+
+	check(`0123456789`, `"0123456789"`)
+	check(`https://example.com/file.txt`, `"https" + /* … */ "e.txt" /* 28 bytes total */`)
+	check(`ééééééééé`, `"éé" + /* … */ "ééé" /* 18 bytes total */`)
+	check([]string{`0123456789abc`}, `[]string{"01234" + /* … */ "89abc" /* 13 bytes total */}`)
+}
+
+func TestTailElems(t *testing.T) {
+	list := make([]int, 100)
+	for i := range list {
+		list[i] = i
+	}
+
+	conf := CompactConfig
+	conf.MaxElems = 3
+	conf.TailElems = 2
+
+	check := func(val interface{}, expected string) {
+		t.Helper()
+		actual := StringC(val, conf)
+		if actual != expected {
+			t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+		}
+	}
+
+	check(list, `[]int{0, 1, 2, /* … 95 elements omitted … */ 98, 99}`)
+	check(list[:5], `[]int{0, 1, 2, 3, 4}`)
+	check(list[:6], `[]int{0, 1, 2, /* … 1 element omitted … */ 4, 5}`)
+	check([]byte{1, 2, 3, 4, 5, 6, 7}, `[]uint8{0x01, 0x02, 0x03, /* … 2 elements omitted … */ 0x06, 0x07}`)
+	check(map[int]int{1: 1, 2: 2, 3: 3, 4: 4, 5: 5, 6: 6}, `map[int]int{1: 1, 2: 2, 3: 3 /* 3 more entries */}`)
+
+	conf = Default
+	conf.MaxElems = 2
+	conf.TailElems = 1
+	check([]string{`a`, `b`, `c`, `d`}, `[]string{
+	"a",
+	"b",
+	/* … 1 element omitted … */
+	"d",
+}`)
+
+	conf.MaxElems = 9
+	conf.TailElems = 8
+	check(make([]byte, 20), `[]uint8{
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00,
+	/* … 3 elements omitted … */
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+}`)
+}
+
+func TestInlineBytes(t *testing.T) {
+	conf := Default
+	conf.InlineBytes = 16
+
+	check := func(val interface{}, expected string) {
+		t.Helper()
+		actual := StringC(val, conf)
+		if actual != expected {
+			t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+		}
+	}
+
+	check(make([]byte, 16), `[]uint8{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}`)
+	check(make([]byte, 17), `[]uint8{
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00,
+}`)
+
+	conf.InlineBytes = 2
+	check([4]byte{}, `[4]uint8{
+	0x00, 0x00, 0x00, 0x00,
+}`)
+}
+
+func TestWideAndDeep(t *testing.T) {
+	fields := make([]reflect.StructField, 2000)
+	for i := range fields {
+		fields[i] = reflect.StructField{Name: `F` + strconv.Itoa(i), Type: reflect.TypeOf(0)}
+	}
+	wide := reflect.New(reflect.StructOf(fields)).Elem()
+	for i := range fields {
+		wide.Field(i).SetInt(int64(i + 1))
+	}
+
+	out := String(wide.Interface())
+	if count := strings.Count(out, "\n\tF"); count != len(fields) {
+		t.Fatalf(`expected %v fields, got %v`, len(fields), count)
+	}
+
+	type Node struct{ Next *Node }
+	var node *Node
+	for i := 0; i < 500; i++ {
+		node = &Node{node}
+	}
+
+	conf := CompactConfig
+	conf.MaxDepth = 0
+	out = StringC(node, conf)
+	if count := strings.Count(out, `&repr.Node{`); count != 500 {
+		t.Fatalf(`expected 500 nodes, got %v`, count)
+	}
+}
+
+func TestDeepNesting(t *testing.T) {
+	type Deep struct {
+		Name string
+		List []interface{}
+		Dict map[string]*Deep
+		Next *Deep
+	}
+
+	root := &Deep{Name: `root`}
+	node := root
+	for i := 0; i < 1000; i++ {
+		next := &Deep{Name: strconv.Itoa(i)}
+		if i%3 == 0 {
+			next.List = []interface{}{i, []int{i}, map[int]string{i: `one`}}
+		}
+		if i%5 == 0 {
+			next.Dict = map[string]*Deep{`back`: node}
+		} else if i%7 == 0 {
+			next.Dict = map[string]*Deep{`leaf`: {Name: `leaf`}}
+		}
+		node.Next = next
+		node = next
+	}
+	node.Next = root
+
+	narrow := Default
+	narrow.MaxWidth = 80
+
+	for _, conf := range []Config{Default, CompactConfig, DebugConfig, narrow} {
+		conf.MaxDepth = 0
+		conf.MaxElems = 0
+
+		// Positions are recorded in a single recursive pass.
+		withPositions := conf
+		withPositions.Positions = true
+
+		expected := formatBytes(t, root, withPositions)
+		if actual := formatBytes(t, root, conf); actual != expected {
+			t.Fatalf("deferred output differs from recursive output:\n%v\n%v", actual, expected)
+		}
+		if !strings.Contains(expected, `cyclic reference to the root`) {
+			t.Fatalf(`expected a cyclic reference to the root`)
+		}
+	}
+
+	var list []interface{}
+	expected := `[]interface {}(nil)`
+	for i := 0; i < 1000; i++ {
+		list = []interface{}{i, list}
+		expected = `[]interface {}{` + strconv.Itoa(i) + `, ` + expected + `}`
+	}
+
+	actual := StringC(list, CompactConfig)
+	if actual != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+	}
+}
+
+func formatBytes(t *testing.T, val interface{}, conf Config) string {
+	res, err := Format(val, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(res.Bytes)
+}
+
+func TestDeepNestingStack(t *testing.T) {
+	type Node struct{ Next *Node }
+	var node *Node
+	for i := 0; i < 100000; i++ {
+		node = &Node{node}
+	}
+
+	// Recursion at this depth would exceed the limit and crash.
+	defer debug.SetMaxStack(debug.SetMaxStack(16 << 20))
+
+	conf := CompactConfig
+	conf.MaxDepth = 0
+	out := StringC(node, conf)
+	if count := strings.Count(out, `&repr.Node{`); count != 100000 {
+		t.Fatalf(`expected 100000 nodes, got %v`, count)
+	}
+}
+
+func TestByteBreaks(t *testing.T) {
+	conf := Default
+	conf.Columns = map[reflect.Kind]int{reflect.Uint8: 4}
+	conf.ByteBreaks = func([]byte) []int { return []int{2, 2, 1, 7, 100} }
+
+	actual := StringC([]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, conf)
+	expected := `[]uint8{
+	0x01, 0x02,
+	0x03, 0x04, 0x05, 0x06,
+	0x07,
+	0x08, 0x09, 0x0a,
+}`
+	if actual != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+	}
+
+	src := make([]byte, 1<<14)
+	rand.New(rand.NewSource(1)).Read(src)
+	edited := append([]byte{0xff}, src...)
+
+	// Counts the rows of "edited" missing from the output for "src".
+	changed := func(conf Config) int {
+		rows := map[string]bool{}
+		for _, row := range strings.Split(StringC(src, conf), "\n") {
+			rows[row] = true
+		}
+		count := 0
+		for _, row := range strings.Split(StringC(edited, conf), "\n") {
+			if !rows[row] {
+				count++
+			}
+		}
+		return count
+	}
+
+	conf = Default
+	if count := changed(conf); count < 1000 {
+		t.Fatalf(`expected most rows to change without breaks, got %v`, count)
+	}
+
+	// At most the rows of the first segment, of up to 1024 bytes.
+	conf.ByteBreaks = ContentBreaks(256)
+	if count := changed(conf); count > 1024/8+1 {
+		t.Fatalf(`expected few rows to change with content breaks, got %v`, count)
+	}
+
+	breaks := ContentBreaks(256)(src)
+	for i, offset := range breaks {
+		size := offset
+		if i > 0 {
+			size -= breaks[i-1]
+		}
+		if size < 64 || size > 1024 {
+			t.Fatalf(`segment size %v out of range`, size)
+		}
+	}
+	if len(breaks) < 16 || len(breaks) > 256 {
+		t.Fatalf(`unexpected number of segments: %v`, len(breaks))
+	}
+}
+
+func TestByteArray(t *testing.T) {
+	type Data struct {
+		One  [1]byte
+		Word [8]byte
+	}
+
+	check := func(val interface{}, expected string) {
+		t.Helper()
+		actual := StringC(val, CompactConfig)
+		if actual != expected {
+			t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+		}
+	}
+
+	check([1]byte{0xff}, `[1]uint8{0xff}`)
+	check([3]byte{1, 2, 3}, `[3]uint8{0x01, 0x02, 0x03}`)
+	check(Data{One: [1]byte{7}, Word: [8]byte{1, 2, 3, 4, 5, 6, 7, 8}},
+		`repr.Data{One: [1]uint8{0x07}, Word: [8]uint8{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08}}`)
+
+	arr := [4]byte{1, 2, 3, 4}
+	if !bytes.Equal(byteArrayToSlice(reflect.ValueOf(arr)), arr[:]) {
+		t.Fatalf("expected a copy of %v", arr)
+	}
+
+	slice := byteArrayToSlice(reflect.ValueOf(&arr).Elem())
+	if &slice[0] != &arr[0] {
+		t.Fatalf("expected addressable arrays to be sliced in place")
+	}
+}
+
+func TestInlineElems(t *testing.T) {
+	conf := Default
+	conf.InlineElems = 3
+
+	check := func(val interface{}, expected string) {
+		t.Helper()
+		actual := StringC(val, conf)
+		if actual != expected {
+			t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+		}
+	}
+
+	check([]int{1, 2}, `[]int{1, 2}`)
+	check([]int{1, 2, 3}, `[]int{
+	1,
+	2,
+	3,
+}`)
+
+	conf.InlineType = func(rtype reflect.Type) bool {
+		return rtype.Kind() == reflect.String
+	}
+	check([]string{`a`, `b`}, `[]string{"a", "b"}`)
+	check([]int{1, 2}, `[]int{
+	1,
+	2,
+}`)
+
+	conf.InlineElems = -1
+	check([]string{`a`}, `[]string{
+	"a",
+}`)
+}
+
+func TestForceMultiline(t *testing.T) {
+	conf := Default
+	conf.ForceMultiline = true
+
+	type Inner struct{ Val int }
+	type Outer struct {
+		Ints  []int
+		Bytes []byte
+		Map   map[int]int
+		Inner Inner
+		Empty []string
+	}
+
+	actual := StringC(Outer{
+		Ints:  []int{1, 2},
+		Bytes: []byte{3},
+		Map:   map[int]int{4: 5},
+		Inner: Inner{6},
+		Empty: []string{},
+	}, conf)
+	expected := `repr.Outer{
+	Ints: []int{
+		1,
+		2,
+	},
+	Bytes: []uint8{
+		0x03,
+	},
+	Map: map[int]int{
+		4: 5,
+	},
+	Inner: repr.Inner{
+		Val: 6,
+	},
+	Empty: []string{
+	},
+}`
+	if actual != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+	}
+
+	_, err := format.Source([]byte(`package main; var _ = ` + actual))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInlineLeafStructs(t *testing.T) {
+	conf := Default
+	conf.InlineLeafStructs = true
+
+	type Param struct {
+		Name string
+		Kind test.AbiKind
+	}
+	type Method struct {
+		Name   string
+		Params []Param
+	}
+
+	actual := StringC(Method{
+		Name:   `transfer`,
+		Params: []Param{{`to`, test.AbiKindAddress}, {`amount`, test.AbiKindUint}},
+	}, conf)
+	expected := `repr.Method{
+	Name: "transfer",
+	Params: []repr.Param{
+		{Name: "to", Kind: 4},
+		{Name: "amount", Kind: 2},
+	},
+}`
+	if actual != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+	}
+}
+
+func TestInlineMaps(t *testing.T) {
+	type Config struct {
+		Limits map[string]int
+		Names  map[int]string
+		Nested map[string][]int
+	}
+
+	conf := Default
+	conf.SortKeys = true
+	conf.InlineMaps = 24
+
+	actual := StringC(Config{
+		Limits: map[string]int{`max`: 10, `min`: 1},
+		Names:  map[int]string{1: `one`, 2: `two`, 3: `three`, 4: `four`},
+		Nested: map[string][]int{`one`: {1}},
+	}, conf)
+	expected := `repr.Config{
+	Limits: map[string]int{"max": 10, "min": 1},
+	Names: map[int]string{
+		1: "one",
+		2: "two",
+		3: "three",
+		4: "four",
+	},
+	Nested: map[string][]int{
+		"one": []int{1},
+	},
+}`
+	if actual != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+	}
+
+	conf.ForceMultiline = true
+	actual = StringC(map[string]int{`one`: 1}, conf)
+	expected = `map[string]int{
+	"one": 1,
+}`
+	if actual != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+	}
+}
+
+func TestCompactness(t *testing.T) {
+	type Param struct {
+		Name string
+		Size int
+	}
+	type Fixture struct {
+		Hash   []byte
+		Tags   []string
+		Params []Param
+	}
+
+	val := Fixture{
+		Hash:   make([]byte, 16),
+		Tags:   []string{`one`, `two`},
+		Params: []Param{{`a`, 1}, {`b`, 2}},
+	}
+
+	check := func(level int, expected string) {
+		t.Helper()
+		conf := Default
+		conf.Compactness = level
+		actual := StringC(val, conf)
+		if actual != expected {
+			t.Fatalf("level %v, expected output:\n%v\nactual output:\n%v", level, expected, actual)
+		}
+	}
+
+	check(0, `repr.Fixture{
+	Hash: []uint8{
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	},
+	Tags: []string{
+		"one",
+		"two",
+	},
+	Params: []repr.Param{
+		{
+			Name: "a",
+			Size: 1,
+		},
+		{
+			Name: "b",
+			Size: 2,
+		},
+	},
+}`)
+
+	check(1, `repr.Fixture{
+	Hash: []uint8{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+	Tags: []string{
+		"one",
+		"two",
+	},
+	Params: []repr.Param{
+		{Name: "a", Size: 1},
+		{Name: "b", Size: 2},
+	},
+}`)
+
+	check(2, `repr.Fixture{
+	Hash: []uint8{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+	Tags: []string{"one", "two"},
+	Params: []repr.Param{
+		{Name: "a", Size: 1},
+		{Name: "b", Size: 2},
+	},
+}`)
+
+	check(3, `repr.Fixture{
+	Hash: []uint8{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+	Tags: []string{"one", "two"},
+	Params: []repr.Param{{Name: "a", Size: 1}, {Name: "b", Size: 2}},
+}`)
+}
+
+func TestZeroFieldsLast(t *testing.T) {
+	type Src struct {
+		One   int
+		Two   string
+		Three int
+		Four  string
+	}
+
+	conf := Default
+	conf.ZeroFields = true
+	conf.ZeroFieldsLast = true
+
+	actual := StringC(Src{Two: `two`, Three: 3}, conf)
+	expected := `repr.Src{
+	Two: "two",
+	Three: 3,
+
+	One: 0,
+	Four: "",
+}`
+	if actual != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+	}
+
+	actual = StringC(Src{}, conf)
+	expected = `repr.Src{
+	One: 0,
+	Two: "",
+	Three: 0,
+	Four: "",
+}`
+	if actual != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+	}
+
+	conf.Indent = ``
+	actual = StringC(Src{Two: `two`, Three: 3}, conf)
+	expected = `repr.Src{Two: "two", Three: 3, One: 0, Four: ""}`
+	if actual != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+	}
+}
+
+func TestInlineKeyLen(t *testing.T) {
+	type Point struct {
+		X, Y  int
+		Label string
+	}
+
+	conf := Default
+	conf.SortKeys = true
+	conf.InlineKeyLen = 20
+
+	actual := StringC(map[Point]string{
+		{X: 1, Y: 2}: `short`,
+		{X: 1234567890, Y: 1234567890, Label: `long label`}: `long`,
+	}, conf)
+	expected := `map[repr.Point]string{
+	{X: 1, Y: 2}: "short",
+	{
+		X: 1234567890,
+		Y: 1234567890,
+		Label: "long label",
+	}: "long",
+}`
+	if actual != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+	}
+}
+
+func TestSliceOfPointers(t *testing.T) {
+	type Elem struct{ Val int }
+	shared := &Elem{1}
+	val := []*Elem{shared, nil, shared, {}}
+
+	check := func(conf Config, expected string) {
+		t.Helper()
+		actual := StringC(val, conf)
+		if actual != expected {
+			t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+		}
+		_, err := format.Source([]byte(`package main; var _ = ` + actual))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	check(CompactConfig, `[]*repr.Elem{{Val: 1}, nil, {Val: 1}, {}}`)
+
+	check(Default, `[]*repr.Elem{
+	{
+		Val: 1,
+	},
+	nil,
+	{
+		Val: 1,
+	},
+	{},
+}`)
+
+	conf := CompactConfig
+	conf.ForceConstructorName = true
+	check(conf, `[]*repr.Elem{&repr.Elem{Val: 1}, nil, &repr.Elem{Val: 1}, &repr.Elem{}}`)
+
+	actual := StringC(map[string]*Elem{`one`: shared, `two`: nil}, CompactConfig)
+	expected := `map[string]*repr.Elem{"one": {Val: 1}, "two": nil}`
+	if actual != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+	}
+}
+
+type _Ctype_int int32
+
+func TestOpaqueTypes(t *testing.T) {
+	check := func(val interface{}, expected string) {
+		t.Helper()
+		actual := StringC(val, CompactConfig)
+		if actual != expected {
+			t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+		}
+	}
+
+	check(os.ErrDeadlineExceeded, `nil /* internal/poll.DeadlineExceededError */`)
+	check([]error{os.ErrDeadlineExceeded, nil}, `[]error{nil /* internal/poll.DeadlineExceededError */, nil}`)
+	check([]interface{}{_Ctype_int(1)}, `[]interface {}{nil /* github.com/mitranim/repr._Ctype_int */}`)
+
+	res, err := Format(struct {
+		_   [0]byte
+		Val int
+	}{Val: 1}, CompactConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Warnings) != 0 {
+		t.Fatalf(`blank fields must not produce warnings, got %v`, res.Warnings)
+	}
+}
+
+func TestSkipTypes(t *testing.T) {
+	type Parent struct{ Name string }
+	type Child struct {
+		Name   string
+		Parent *Parent
+		Any    interface{}
+	}
+
+	conf := CompactConfig
+	conf.SkipTypes = []reflect.Type{reflect.TypeOf((*Parent)(nil))}
+
+	parent := &Parent{`parent`}
+	check := func(val interface{}, expected string) {
+		t.Helper()
+		actual := StringC(val, conf)
+		if actual != expected {
+			t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+		}
+	}
+
+	check(Child{Name: `child`, Parent: parent, Any: parent}, `repr.Child{Name: "child"}`)
+	check([]*Parent{parent}, `[]*repr.Parent{nil /* skipped *repr.Parent */}`)
+	check(*parent, `repr.Parent{Name: "parent"}`)
+}
+
+func TestTypeExpr(t *testing.T) {
+	type DB struct{ Conns int }
+	type Service struct {
+		Name string
+		DB   *DB
+	}
+
+	conf := CompactConfig
+	conf.TypeExpr = map[reflect.Type]string{reflect.TypeOf((*DB)(nil)): `testDB`}
+
+	actual := StringC([]Service{{`one`, &DB{10}}, {`two`, nil}}, conf)
+	expected := `[]repr.Service{{Name: "one", DB: testDB}, {Name: "two"}}`
+	if actual != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+	}
+}
+
+func TestPathOverrides(t *testing.T) {
+	conf := CompactConfig
+	conf.PathOverrides = map[string]string{
+		`.Inputs[1].AbiType`:       `customType()`,
+		`.Inputs[0].Components`:    `nil`,
+		`.Outputs[0].AbiType.Elem`: `&elem`,
+	}
+
+	actual := StringC(test.AbiFunction{
+		Inputs: []test.AbiParam{
+			{Name: `one`, Components: []test.AbiParam{{Name: `nested`}}},
+			{Name: `two`},
+		},
+		Outputs: []test.AbiParam{{Name: `three`, AbiType: test.AbiType{Kind: test.AbiKindUint}}},
+	}, conf)
+	expected := `test.AbiFunction{Inputs: []test.AbiParam{{Name: "one", Components: nil}, {Name: "two", AbiType: customType()}}, Outputs: []test.AbiParam{{Name: "three", AbiType: test.AbiType{Kind: 2, Elem: &elem}}}}`
+	if actual != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+	}
+
+	conf.PathOverrides = map[string]string{`["two"][1]`: `x`}
+	actual = StringC(map[string][]int{`one`: {1, 2}, `two`: {3, 4}}, conf)
+	expected = `map[string][]int{"one": []int{1, 2}, "two": []int{3, x}}`
+	if actual != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+	}
+}
+
+func TestOverrideFunc(t *testing.T) {
+	type Login struct {
+		User     string
+		Password string
+	}
+	type Data struct {
+		Main  Login
+		Other []Login
+		Count int
+	}
+
+	var paths []string
+	conf := CompactConfig
+	conf.PathOverrides = map[string]string{`.Count`: `count`}
+	conf.OverrideFunc = func(path string, val reflect.Value) (string, bool) {
+		paths = append(paths, path)
+		if strings.HasSuffix(path, `.Password`) {
+			return `"***"`, true
+		}
+		return ``, false
+	}
+
+	actual := StringC(Data{
+		Main:  Login{User: `one`, Password: `secret`},
+		Other: []Login{{User: `two`}},
+	}, conf)
+	expected := `repr.Data{Main: repr.Login{User: "one", Password: "***"}, Other: []repr.Login{{User: "two", Password: "***"}}, Count: count}`
+	if actual != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+	}
+
+	for _, path := range []string{``, `.Main`, `.Main.User`, `.Other[0]`, `.Other[0].User`} {
+		if !containsString(paths, path) {
+			t.Fatalf(`expected the hook to be called for %q, got %q`, path, paths)
+		}
+	}
+	if containsString(paths, `.Count`) {
+		t.Fatalf(`expected "PathOverrides" to take priority over the hook`)
+	}
+}
+
+func TestUnsupportedPanic(t *testing.T) {
+	type Inner struct {
+		Name  string
+		Value *int
+	}
+	type Outer struct {
+		Items []Inner
+	}
+
+	num := 10
+	val := Outer{Items: []Inner{{Name: `one`}, {Name: `two`, Value: &num}}}
+
+	test := func(expected string, val interface{}) {
+		t.Helper()
+		defer func() {
+			t.Helper()
+			err, _ := recover().(error)
+			if err == nil || err.Error() != expected {
+				t.Fatalf("expected panic:\n%v\ngot:\n%v", expected, err)
+			}
+		}()
+		String(val)
+	}
+
+	test(`repr currently doesn't support pointers to non-composite types: *int at .Items[1].Value in repr.Inner`, val)
+	test(`repr currently doesn't support pointers to non-composite types: *int`, &num)
+}
+
+func containsString(list []string, val string) bool {
+	for _, elem := range list {
+		if elem == val {
+			return true
+		}
+	}
+	return false
+}
+
+type testOpaque struct {
+	_   [0]byte
+	one int
+	two string
+}
+
+func TestAnnotateOpaque(t *testing.T) {
+	conf := CompactConfig
+	conf.AnnotateOpaque = true
+
+	res, err := Format([]interface{}{testOpaque{one: 1}, struct{}{}}, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `[]interface {}{repr.testOpaque{} /* opaque: 2 unexported fields */, struct {}{}}`
+	if string(res.Bytes) != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, string(res.Bytes))
+	}
+
+	warnings := []Warning{
+		{Message: `repr.testOpaque: unexported fields are omitted`, Path: `[0]`},
+		{Message: `repr.testOpaque: no exported fields, printed as empty`, Path: `[0]`},
+	}
+	if !reflect.DeepEqual(res.Warnings, warnings) {
+		t.Fatalf("expected warnings:\n%#v\nactual warnings:\n%#v", warnings, res.Warnings)
+	}
+}
+
+func TestInterfaceKind(t *testing.T) {
+	vals := []interface{}{nil, 10, test.AbiParam{Name: `one`}, []interface{}{`two`}}
+	rval := reflect.ValueOf(vals)
+
+	expected := []string{
+		`nil`,
+		`10`,
+		`test.AbiParam{Name: "one"}`,
+		`[]interface {}{"two"}`,
+	}
+
+	conf := CompactConfig
+
+	for i := range vals {
+		elem := rval.Index(i)
+		if elem.Kind() != reflect.Interface {
+			t.Fatalf(`expected an interface value, got %v`, elem.Kind())
+		}
+
+		actual := string(appendReflect(nil, elem, fmter{conf: &conf}))
+		if actual != expected[i] {
+			t.Fatalf("expected output:\n%v\nactual output:\n%v", expected[i], actual)
+		}
+		if size := estimateValue(elem, fmter{conf: &conf}); size <= 0 {
+			t.Fatalf(`expected a positive size estimate, got %v`, size)
+		}
+	}
+
+	// Values reached through unexported fields can't be converted back to
+	// "interface{}", and are printed by kind.
+	type hidden struct{ val interface{} }
+	elem := reflect.ValueOf(hidden{int32(10)}).Field(0)
+
+	actual := string(appendReflect(nil, elem, fmter{conf: &conf}))
+	if actual != `int32(10)` {
+		t.Fatalf(`unexpected output: %v`, actual)
+	}
+}
+
+func TestEmptyPolicy(t *testing.T) {
+	type Inner struct{ Val int }
+	type Data struct {
+		List  []int
+		Dict  map[string]int
+		Inner Inner
+		Lists [][]int
+		Dicts map[string][]int
+		Elems []Inner
+	}
+
+	val := Data{
+		List:  []int{},
+		Dict:  map[string]int{},
+		Lists: [][]int{[]int{}, []int{1}},
+		Dicts: map[string][]int{`one`: {}, `two`: {2}},
+		Elems: []Inner{{}},
+	}
+
+	check := func(conf Config, expected string) {
+		t.Helper()
+		conf.SortKeys = true
+		actual := StringC(val, conf)
+		if actual != expected {
+			t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+		}
+	}
+
+	conf := CompactConfig
+	check(conf, `repr.Data{List: []int{}, Dict: map[string]int{}, Lists: [][]int{[]int{}, []int{1}}, Dicts: map[string][]int{"one": []int{}, "two": []int{2}}, Elems: []repr.Inner{{}}}`)
+
+	conf.EmptyFields = EmptyKeep
+	check(conf, `repr.Data{List: []int{}, Dict: map[string]int{}, Inner: repr.Inner{}, Lists: [][]int{[]int{}, []int{1}}, Dicts: map[string][]int{"one": []int{}, "two": []int{2}}, Elems: []repr.Inner{{}}}`)
+
+	conf.EmptyFields = EmptyNil
+	conf.EmptyElems = EmptyNil
+	conf.EmptyMapValues = EmptyNil
+	check(conf, `repr.Data{Lists: [][]int{nil, []int{1}}, Dicts: map[string][]int{"one": nil, "two": []int{2}}, Elems: []repr.Inner{{}}}`)
+
+	conf.ZeroFields = true
+	check(conf, `repr.Data{List: nil, Dict: nil, Inner: repr.Inner{Val: 0}, Lists: [][]int{nil, []int{1}}, Dicts: map[string][]int{"one": nil, "two": []int{2}}, Elems: []repr.Inner{{Val: 0}}}`)
+
+	conf.EmptyFields = EmptyOmit
+	conf.EmptyElems = EmptyOmit
+	conf.EmptyMapValues = EmptyOmit
+	check(conf, `repr.Data{Lists: [][]int{nil, []int{1}}, Dicts: map[string][]int{"two": []int{2}}, Elems: []repr.Inner{{Val: 0}}}`)
+}
+
+func TestAddresses(t *testing.T) {
+	type Addr uintptr
+	type Data struct {
+		Name  string
+		Ptr   unsafe.Pointer
+		Addr  Addr
+		Addrs []uintptr
+	}
+
+	num := 10
+	val := Data{Name: `one`, Ptr: unsafe.Pointer(&num), Addr: 0x1234, Addrs: []uintptr{0, 0x1234}}
+
+	test := func(expected string, val interface{}, addresses AddressPolicy) {
+		t.Helper()
+		conf := CompactConfig
+		conf.Addresses = addresses
+		actual := StringC(val, conf)
+		if actual != expected {
+			t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+		}
+	}
+
+	test(`repr.Data{Name: "one", Ptr: unsafe.Pointer(nil) /* address */, Addr: 0 /* address */, Addrs: []uintptr{0x0, 0 /* address */}}`, val, AddressPlaceholder)
+	test(`repr.Data{Name: "one", Addrs: []uintptr{0x0, 0}}`, val, AddressOmit)
+	test(`[]interface {}{uintptr(0) /* address */, unsafe.Pointer(nil) /* address */}`, []interface{}{uintptr(0x1234), unsafe.Pointer(&num)}, AddressPlaceholder)
+	test(`repr.Addr(0x1234)`, val.Addr, AddressShow)
+}
+
+func TestAppendInt(t *testing.T) {
+	for _, val := range []int64{
+		0, 1, 9, 10, 11, 99, 100, 101, 255, 256, 999, 1000, 12345, 1e9,
+		math.MaxInt32, math.MaxInt64, -1, -9, -10, -99, -100, -256, math.MinInt64,
+	} {
+		expected := strconv.FormatInt(val, 10)
+		actual := string(appendInt(nil, val))
+		if actual != expected {
+			t.Fatalf("expected %v, got %v", expected, actual)
+		}
+	}
+
+	for _, val := range []uint64{0, 9, 10, 99, 100, 255, 256, 1e19, math.MaxUint64} {
+		expected := strconv.FormatUint(val, 10)
+		actual := string(appendUint(nil, val))
+		if actual != expected {
+			t.Fatalf("expected %v, got %v", expected, actual)
+		}
+	}
+}
+
 func BenchmarkBytes(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		_ = Bytes(testStructure)
@@ -150,6 +2117,14 @@ func BenchmarkString(b *testing.B) {
 	}
 }
 
+func BenchmarkStringPooled(b *testing.B) {
+	conf := Default
+	conf.Pooled = true
+	for i := 0; i < b.N; i++ {
+		_ = StringC(testStructure, conf)
+	}
+}
+
 func BenchmarkBytesWithFormat(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		_, err := format.Source(Bytes(testStructure))
@@ -159,6 +2134,83 @@ func BenchmarkBytesWithFormat(b *testing.B) {
 	}
 }
 
+var testBytecode = func() []byte {
+	out := make([]byte, 1<<16)
+	for i := range out {
+		out[i] = byte(i * 7)
+	}
+	return out
+}()
+
+func BenchmarkByteSlice(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		_ = Bytes(testBytecode)
+	}
+}
+
+func BenchmarkByteSliceHexForComparison(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		_ = hex.EncodeToString(testBytecode)
+	}
+}
+
+// Mostly small numbers, with occasional large ones, as in typical fixtures.
+var testInts = func() []int {
+	out := make([]int, 1<<14)
+	for i := range out {
+		out[i] = i % 300
+		if i%16 == 0 {
+			out[i] = i * 7919
+		}
+	}
+	return out
+}()
+
+type testPoint struct{ X, Y, Z int }
+
+var testPoints = func() []testPoint {
+	out := make([]testPoint, len(testInts)/3)
+	for i := range out {
+		out[i] = testPoint{testInts[i*3], testInts[i*3+1], testInts[i*3+2]}
+	}
+	return out
+}()
+
+func BenchmarkIntSlice(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		_ = Bytes(testInts)
+	}
+}
+
+func BenchmarkIntFields(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		_ = Bytes(testPoints)
+	}
+}
+
+var testMap = func() map[string]int {
+	out := make(map[string]int, 1<<12)
+	for i := 0; i < 1<<12; i++ {
+		out[strconv.Itoa(i*7919)] = i
+	}
+	return out
+}()
+
+func BenchmarkMap(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		_ = Bytes(testMap)
+	}
+}
+
+func BenchmarkMapSorted(b *testing.B) {
+	conf := Default
+	conf.SortKeys = true
+
+	for i := 0; i < b.N; i++ {
+		_ = BytesC(testMap, conf)
+	}
+}
+
 func BenchmarkJsonForComparison(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		_, err := json.Marshal(testStructure)
@@ -1594,3 +3646,7 @@ const testOutputBytesHex = `[]uint8{
 	0x74, 0x02, 0x48, 0xa9, 0x11, 0x9e, 0x4d, 0x28,
 	0x53, 0x22, 0x87, 0x00, 0x29,
 }`
+
+type invalidGoStringer struct{}
+
+func (invalidGoStringer) GoString() string { return `}{` }
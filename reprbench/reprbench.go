@@ -0,0 +1,159 @@
+/*
+Benchmarking helpers for "github.com/mitranim/repr", for measuring the cost of
+"repr.Config" choices on realistic shapes of data:
+
+	import "github.com/mitranim/repr/reprbench"
+
+	func BenchmarkMyConfig(b *testing.B) {
+		reprbench.Run(b, myValue, myConfig)
+	}
+
+	func BenchmarkMyConfigFixtures(b *testing.B) {
+		reprbench.RunFixtures(b, myConfig)
+	}
+
+"Fixtures" are deterministic, so results are comparable between runs, commits
+and machines of the same kind. Compare runs via "benchstat". Unlike timings,
+allocation counts don't depend on the machine, which makes "allocs/op" a
+reliable regression gate: for a given fixture and config, it should only change
+together with the code.
+*/
+package reprbench
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/mitranim/repr"
+)
+
+/*
+Benchmarks "repr.BytesC" with the given value and config. Reports allocations,
+and throughput in bytes of output per second. The value is formatted once
+before starting the timer, which excludes one-time costs such as caching type
+metadata, and panics on unsupported values before the benchmark starts.
+*/
+func Run(b *testing.B, val interface{}, conf repr.Config) {
+	b.Helper()
+	b.ReportAllocs()
+	b.SetBytes(int64(len(repr.BytesC(val, conf))))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		_ = repr.BytesC(val, conf)
+	}
+}
+
+/*
+Runs "Run" for each of "Fixtures" as a sub-benchmark named after the fixture,
+such as "BenchmarkMyConfigFixtures/structs".
+*/
+func RunFixtures(b *testing.B, conf repr.Config) {
+	b.Helper()
+	for _, fix := range Fixtures {
+		val := fix.Value
+		b.Run(fix.Name, func(b *testing.B) { Run(b, val, conf) })
+	}
+}
+
+// Named input for "RunFixtures".
+type Fixture struct {
+	Name  string
+	Value interface{}
+}
+
+/*
+Baseline fixtures, one per shape of data with distinct costs. Must not be
+modified.
+
+	structs   slice of structs with mixed fields, nested structs and slices
+	maps      JSON-like tree of "map[string]interface{}" with numbers and strings
+	bytes     large byte slice, printed as rows of hex
+	pointers  deep chain of pointers to structs
+*/
+var Fixtures = []Fixture{
+	{`structs`, Structs},
+	{`maps`, Maps},
+	{`bytes`, Bytes},
+	{`pointers`, Pointers},
+}
+
+// Element of "Structs".
+type Record struct {
+	ID      int
+	Name    string
+	Active  bool
+	Score   float64
+	Tags    []string
+	Address Address
+}
+
+// Field of "Record".
+type Address struct {
+	City   string
+	Street string
+	Zip    uint32
+}
+
+// Node of "Pointers".
+type Node struct {
+	Value int
+	Label string
+	Next  *Node
+}
+
+// Struct-heavy fixture: 1024 records with every field set.
+var Structs = func() []Record {
+	out := make([]Record, 1<<10)
+	for i := range out {
+		out[i] = Record{
+			ID:     i,
+			Name:   `record ` + strconv.Itoa(i),
+			Active: i%3 == 0,
+			Score:  float64(i) / 7,
+			Tags:   []string{`one`, `two`, `three`}[:i%4],
+			Address: Address{
+				City:   `city ` + strconv.Itoa(i%17),
+				Street: `street ` + strconv.Itoa(i%101),
+				Zip:    uint32(10000 + i*7919%90000),
+			},
+		}
+	}
+	return out
+}()
+
+// Map-heavy fixture: 256 objects with 8 entries each, as decoded from JSON.
+var Maps = func() map[string]interface{} {
+	out := make(map[string]interface{}, 1<<8)
+	for i := 0; i < 1<<8; i++ {
+		obj := make(map[string]interface{}, 8)
+		for j := 0; j < 8; j++ {
+			key := `field` + strconv.Itoa(j)
+			if j%2 == 0 {
+				obj[key] = float64(i*j) / 4
+			} else {
+				obj[key] = `value ` + strconv.Itoa(i*j)
+			}
+		}
+		out[`object`+strconv.Itoa(i*7919)] = obj
+	}
+	return out
+}()
+
+// Bytes-heavy fixture: 64 KiB of varied bytes.
+var Bytes = func() []byte {
+	out := make([]byte, 1<<16)
+	for i := range out {
+		out[i] = byte(i * 7)
+	}
+	return out
+}()
+
+// Deep-pointer fixture: a chain of 256 nodes.
+var Pointers = func() *Node {
+	var out *Node
+	for i := 1 << 8; i > 0; i-- {
+		out = &Node{Value: i, Label: `node ` + strconv.Itoa(i), Next: out}
+	}
+	return out
+}()
@@ -0,0 +1,34 @@
+package reprbench
+
+import (
+	"testing"
+
+	"github.com/mitranim/repr"
+)
+
+func TestFixtures(t *testing.T) {
+	conf := repr.Default
+	conf.Validate = true
+
+	for _, fix := range Fixtures {
+		_, err := repr.BytesE(fix.Value, conf)
+		if err != nil {
+			t.Fatalf(`fixture %q: %v`, fix.Name, err)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	res := testing.Benchmark(func(b *testing.B) { Run(b, Pointers, repr.Default) })
+	if res.N == 0 || res.Bytes != int64(len(repr.Bytes(Pointers))) {
+		t.Fatalf(`unexpected result: %+v`, res)
+	}
+}
+
+func BenchmarkDefault(b *testing.B) { RunFixtures(b, repr.Default) }
+
+func BenchmarkSortKeys(b *testing.B) {
+	conf := repr.Default
+	conf.SortKeys = true
+	RunFixtures(b, conf)
+}
@@ -0,0 +1,207 @@
+package repr
+
+import "strconv"
+
+/*
+Output of "Format": the formatted code along with information collected during
+formatting, which would otherwise require separate passes over the value.
+*/
+type Result struct {
+	/**
+	Formatted code, same as returned by "BytesC".
+	*/
+	Bytes []byte
+
+	/**
+	Package paths referenced by the output, mapped to the names used for them.
+	Useful for generating import declarations. See "Config.PackageMap".
+	*/
+	Imports map[string]string
+
+	/**
+	Statistics about the formatted value.
+	*/
+	Stats Stats
+
+	/**
+	Non-fatal issues encountered during formatting, such as data that couldn't
+	be represented, lossy renderings, truncations due to limits, or unexported
+	fields which were skipped. Each message is reported once, with the path of
+	its first occurrence.
+	*/
+	Warnings []Warning
+
+	/**
+	Every place where a limit such as "Config.MaxElems" omitted part of the
+	value, in output order. Unlike warnings, these are not deduplicated, which
+	allows tools to find specific subtrees and format them again with higher
+	limits, for example via "Config.Include".
+	*/
+	Limits []Limit
+
+	/**
+	Byte ranges of printed values in "Bytes", keyed by their paths in the
+	syntax of "Config.PathOverrides". The root value has an empty path.
+	Pointers share paths with their targets, and are recorded with the
+	enclosing "&". Nil unless "Config.Positions" is set.
+	*/
+	Positions map[string]Span
+}
+
+/*
+Range of bytes in the output, from "Start" inclusive to "End" exclusive. See
+"Result.Positions".
+*/
+type Span struct{ Start, End int }
+
+/*
+True if the output omits parts of the value due to limits such as
+"Config.MaxElems", "Config.MaxStringLen" or "Config.MaxDepth".
+*/
+func (self Result) Truncated() bool { return self.Stats.Truncated() }
+
+/*
+Statistics collected by "Format".
+*/
+type Stats struct {
+	/**
+	Number of values printed, including nested values.
+	*/
+	Values int
+
+	/**
+	Maximum nesting depth of composite literals.
+	*/
+	Depth int
+
+	/**
+	Number of elements and entries omitted due to "Config.MaxElems".
+	*/
+	Omitted int
+
+	/**
+	Number of strings truncated due to "Config.MaxStringLen".
+	*/
+	TruncatedStrings int
+
+	/**
+	Number of composite literals replaced due to "Config.MaxDepth".
+	*/
+	DepthLimited int
+
+	/**
+	Number of strings and byte slices replaced due to "Config.DigestLen".
+	*/
+	Digested int
+}
+
+// True if any limit was applied. See "Result.Truncated".
+func (self Stats) Truncated() bool {
+	return self.Omitted > 0 || self.TruncatedStrings > 0 || self.DepthLimited > 0 ||
+		self.Digested > 0
+}
+
+/*
+Non-fatal issue encountered during formatting. See "Result.Warnings".
+*/
+type Warning struct {
+	Message string
+
+	/**
+	Location of the value in the formatted tree, in the syntax of
+	"Config.PathOverrides", such as `.Items[2]["key"]`. Empty for the root
+	value.
+	*/
+	Path string
+}
+
+// Implements "fmt.Stringer". Prefixes the message with the path, if any.
+func (self Warning) String() string {
+	if self.Path == `` {
+		return self.Message
+	}
+	return self.Path + `: ` + self.Message
+}
+
+/*
+Limit applied to part of the output. See "Limit".
+*/
+type LimitKind byte
+
+const (
+	// Composite literal replaced due to "Config.MaxDepth".
+	LimitDepth LimitKind = iota + 1
+
+	// Elements or entries omitted due to "Config.MaxElems".
+	LimitElems
+
+	// String truncated due to "Config.MaxStringLen".
+	LimitStringLen
+
+	// String or byte slice replaced due to "Config.DigestLen".
+	LimitDigest
+)
+
+// Implements "fmt.Stringer". Returns the name of the config field.
+func (self LimitKind) String() string {
+	switch self {
+	case LimitDepth:
+		return `MaxDepth`
+	case LimitElems:
+		return `MaxElems`
+	case LimitStringLen:
+		return `MaxStringLen`
+	case LimitDigest:
+		return `DigestLen`
+	default:
+		return `LimitKind(` + strconv.Itoa(int(self)) + `)`
+	}
+}
+
+/*
+Machine-readable record of a limit applied to part of the output, in addition
+to the comment in the output itself. See "Result.Limits".
+*/
+type Limit struct {
+	Kind LimitKind
+
+	/**
+	Location of the affected value, in the syntax of "Config.PathOverrides".
+	For "LimitElems", this is the collection.
+	*/
+	Path string
+
+	/**
+	Original size of the value: number of elements or entries for
+	"LimitElems", and number of bytes for "LimitStringLen" and "LimitDigest".
+	Zero for "LimitDepth".
+	*/
+	Size int
+
+	/**
+	Number of elements, entries or bytes missing from the output. Zero for
+	"LimitDepth".
+	*/
+	Omitted int
+}
+
+/*
+Formats the value using the provided config, collecting the imports used,
+statistics and warnings in a single pass. Returns an error if validation is
+enabled and fails, in which case the result is still populated. See "Result".
+*/
+func Format(val interface{}, conf Config) (Result, error) {
+	state := &state{imports: map[string]string{}, paths: true}
+	if conf.Positions {
+		state.positions = map[string]Span{}
+	}
+	out, err := appendE(nil, val, conf, state)
+	return Result{
+		Bytes:     out,
+		Imports:   state.imports,
+		Stats:     state.stats,
+		Warnings:  state.warnings,
+		Limits:    state.limits,
+		Positions: state.positions,
+	}, err
+}
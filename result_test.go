@@ -0,0 +1,160 @@
+package repr
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mitranim/repr/test"
+)
+
+func TestFormat(t *testing.T) {
+	type Inner struct {
+		Funs   []func()
+		hidden int
+	}
+	type Outer struct {
+		Name  string
+		List  []int
+		Inner Inner
+		Abi   test.AbiParam
+	}
+
+	conf := CompactConfig
+	conf.MaxElems = 2
+	conf.MaxStringLen = 4
+
+	val := Outer{
+		Name:  `hello world`,
+		List:  []int{1, 2, 3, 4},
+		Inner: Inner{Funs: []func(){func() {}}, hidden: 1},
+		Abi:   test.AbiParam{Name: `one`},
+	}
+
+	result, err := Format(val, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(result.Bytes) != StringC(val, conf) {
+		t.Fatalf(`expected the same output as StringC, got %s`, result.Bytes)
+	}
+
+	expectedImports := map[string]string{
+		`github.com/mitranim/repr`:      `repr`,
+		`github.com/mitranim/repr/test`: `test`,
+	}
+	if !reflect.DeepEqual(result.Imports, expectedImports) {
+		t.Fatalf(`unexpected imports: %v`, result.Imports)
+	}
+
+	expectedStats := Stats{Values: 10, Depth: 3, Omitted: 2, TruncatedStrings: 1}
+	if result.Stats != expectedStats {
+		t.Fatalf(`unexpected stats: %+v`, result.Stats)
+	}
+	if !result.Truncated() {
+		t.Fatalf(`expected the result to be truncated`)
+	}
+
+	expectedWarnings := []Warning{
+		{Message: `strings are truncated due to MaxStringLen`, Path: `.Name`},
+		{Message: `elements are omitted due to MaxElems`, Path: `.List`},
+		{Message: `func(): non-nil value is printed as nil`, Path: `.Inner.Funs[0]`},
+		{Message: `repr.Inner: unexported fields are omitted`, Path: `.Inner`},
+	}
+	if !reflect.DeepEqual(result.Warnings, expectedWarnings) {
+		t.Fatalf(`unexpected warnings: %v`, result.Warnings)
+	}
+
+	if str := result.Warnings[0].String(); str != `.Name: strings are truncated due to MaxStringLen` {
+		t.Fatalf(`unexpected warning string: %v`, str)
+	}
+}
+
+func TestFormatLimits(t *testing.T) {
+	type Inner struct{ Val []int }
+	type Outer struct {
+		Name   string
+		Lists  [][]int
+		Nested Inner
+		Data   map[string]int
+	}
+
+	conf := CompactConfig
+	conf.MaxElems = 2
+	conf.MaxStringLen = 4
+	conf.MaxDepth = 2
+
+	val := Outer{
+		Name:   `hello world`,
+		Lists:  [][]int{{1, 2, 3}, {4}, {5}},
+		Nested: Inner{[]int{1}},
+		Data:   map[string]int{`a`: 1, `b`: 2, `c`: 3},
+	}
+
+	result, err := Format(val, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Limit{
+		{Kind: LimitStringLen, Path: `.Name`, Size: 11, Omitted: 7},
+		{Kind: LimitDepth, Path: `.Lists[0]`},
+		{Kind: LimitDepth, Path: `.Lists[1]`},
+		{Kind: LimitElems, Path: `.Lists`, Size: 3, Omitted: 1},
+		{Kind: LimitDepth, Path: `.Nested.Val`},
+		{Kind: LimitElems, Path: `.Data`, Size: 3, Omitted: 1},
+	}
+	if !reflect.DeepEqual(result.Limits, expected) {
+		t.Fatalf("unexpected limits:\n%+v\noutput:\n%s", result.Limits, result.Bytes)
+	}
+
+	if str := LimitElems.String(); str != `MaxElems` {
+		t.Fatalf(`unexpected limit kind string: %v`, str)
+	}
+}
+
+func TestFormatPositions(t *testing.T) {
+	type Inner struct{ Tags []string }
+	type Outer struct {
+		Name  string
+		Inner *Inner
+		Map   map[string]int
+	}
+
+	conf := CompactConfig
+	conf.Positions = true
+
+	result, err := Format(Outer{
+		Name:  `one`,
+		Inner: &Inner{Tags: []string{`a`, `b`}},
+		Map:   map[string]int{`key`: 10},
+	}, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		``:               string(result.Bytes),
+		`.Name`:          `"one"`,
+		`.Inner`:         `&repr.Inner{Tags: []string{"a", "b"}}`,
+		`.Inner.Tags`:    `[]string{"a", "b"}`,
+		`.Inner.Tags[1]`: `"b"`,
+		`.Map`:           `map[string]int{"key": 10}`,
+		`.Map["key"]`:    `10`,
+	}
+	for path, code := range expected {
+		span, ok := result.Positions[path]
+		if !ok {
+			t.Fatalf(`missing position for %q in %v`, path, result.Positions)
+		}
+		if actual := string(result.Bytes[span.Start:span.End]); actual != code {
+			t.Fatalf(`expected %q at %q, got %q`, code, path, actual)
+		}
+	}
+
+	conf.Positions = false
+	result, _ = Format(`one`, conf)
+	if result.Positions != nil {
+		t.Fatalf(`expected no positions unless enabled, got %v`, result.Positions)
+	}
+}
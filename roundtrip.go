@@ -0,0 +1,272 @@
+package repr
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+)
+
+/*
+Formats the value using the provided config, parses the output back via
+"Parse", and deeply compares the result with the original. Returns an error
+describing the first divergent path, in the syntax of "Config.PathOverrides",
+or nil if the output reproduces the value. Useful for verifying that persisted
+fixtures are complete:
+
+	err := repr.RoundTrip(val, repr.CodegenConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+Types are registered automatically, including concrete types stored in
+interfaces. Only exported fields are compared, since unexported fields are
+never printed. Non-nil funcs and channels are printed as nil, and are reported
+as divergent, as are parts omitted due to limits such as "Config.MaxElems".
+Output that "Parse" doesn't support, such as constant names produced by
+"Config.ConstName", results in a parse error.
+*/
+func RoundTrip(val interface{}, conf Config) error {
+	rval := reflect.ValueOf(val)
+	if !rval.IsValid() {
+		return nil
+	}
+
+	src, err := BytesE(val, conf)
+	if err != nil {
+		return err
+	}
+
+	types := Types{}
+	types.addValue(rval, conf, map[uintptr]bool{})
+
+	out := reflect.New(rval.Type())
+	err = Parse(stripLinePrefix(src, conf), out.Interface(), types)
+	if err != nil {
+		return fmt.Errorf(`repr: round-trip failed to parse the output: %w`, err)
+	}
+
+	diff := differ{conf: conf, seen: map[[2]uintptr]bool{}}
+	if diff.compare(rval, out.Elem(), ``) {
+		return nil
+	}
+
+	path := diff.path
+	if path == `` {
+		path = `root`
+	}
+	return fmt.Errorf(
+		`repr: round-trip mismatch at %v: expected %v, got %v`,
+		path, diff.describe(diff.expected), diff.describe(diff.actual),
+	)
+}
+
+// Unlike "Types.add", also registers the dynamic types of interface values.
+func (self Types) addValue(rval reflect.Value, conf Config, seen map[uintptr]bool) {
+	if !rval.IsValid() {
+		return
+	}
+	self.add(rval.Type(), conf)
+
+	switch rval.Kind() {
+	case reflect.Ptr:
+		if rval.IsNil() || seen[rval.Pointer()] {
+			return
+		}
+		seen[rval.Pointer()] = true
+		self.addValue(rval.Elem(), conf, seen)
+
+	case reflect.Interface:
+		self.addValue(rval.Elem(), conf, seen)
+
+	case reflect.Array, reflect.Slice:
+		for i := 0; i < rval.Len(); i++ {
+			self.addValue(rval.Index(i), conf, seen)
+		}
+
+	case reflect.Map:
+		iter := rval.MapRange()
+		for iter.Next() {
+			self.addValue(iter.Key(), conf, seen)
+			self.addValue(iter.Value(), conf, seen)
+		}
+
+	case reflect.Struct:
+		for i := 0; i < rval.NumField(); i++ {
+			if isSfieldExported(rval.Type().Field(i)) {
+				self.addValue(rval.Field(i), conf, seen)
+			}
+		}
+	}
+}
+
+// Finds the first difference between an original value and its parsed copy.
+type differ struct {
+	conf Config
+
+	// Pairs of pointers already being compared, which breaks cycles.
+	seen map[[2]uintptr]bool
+
+	path     string
+	expected reflect.Value
+	actual   reflect.Value
+}
+
+// Returns true if the values are equivalent. Otherwise records the path and
+// the divergent values.
+func (self *differ) compare(exp, act reflect.Value, path string) bool {
+	if self.equal(exp, act, path) {
+		return true
+	}
+	if self.expected.IsValid() || self.actual.IsValid() {
+		return false
+	}
+	self.path = path
+	self.expected = exp
+	self.actual = act
+	return false
+}
+
+func (self *differ) equal(exp, act reflect.Value, path string) bool {
+	if !exp.IsValid() || !act.IsValid() {
+		return exp.IsValid() == act.IsValid()
+	}
+	if exp.Type() != act.Type() {
+		return false
+	}
+
+	switch exp.Kind() {
+	case reflect.Bool:
+		return exp.Bool() == act.Bool()
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return exp.Int() == act.Int()
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return exp.Uint() == act.Uint()
+
+	case reflect.Float32, reflect.Float64:
+		return floatEqual(exp.Float(), act.Float())
+
+	case reflect.Complex64, reflect.Complex128:
+		exp, act := exp.Complex(), act.Complex()
+		return floatEqual(real(exp), real(act)) && floatEqual(imag(exp), imag(act))
+
+	case reflect.String:
+		return exp.String() == act.String()
+
+	case reflect.Chan, reflect.Func, reflect.UnsafePointer:
+		return exp.Pointer() == act.Pointer()
+
+	case reflect.Interface:
+		if exp.IsNil() || act.IsNil() {
+			return exp.IsNil() == act.IsNil()
+		}
+		return self.compare(exp.Elem(), act.Elem(), path)
+
+	case reflect.Ptr:
+		if exp.IsNil() || act.IsNil() {
+			return exp.IsNil() == act.IsNil()
+		}
+		key := [2]uintptr{exp.Pointer(), act.Pointer()}
+		if self.seen[key] {
+			return true
+		}
+		self.seen[key] = true
+		return self.compare(exp.Elem(), act.Elem(), path)
+
+	case reflect.Slice:
+		if exp.IsNil() || act.IsNil() {
+			return exp.IsNil() == act.IsNil()
+		}
+		return self.equalList(exp, act, path)
+
+	case reflect.Array:
+		return self.equalList(exp, act, path)
+
+	case reflect.Map:
+		if exp.IsNil() || act.IsNil() {
+			return exp.IsNil() == act.IsNil()
+		}
+		return self.equalMap(exp, act, path)
+
+	case reflect.Struct:
+		return self.equalStruct(exp, act, path)
+
+	default:
+		return false
+	}
+}
+
+func (self *differ) equalList(exp, act reflect.Value, path string) bool {
+	if exp.Len() != act.Len() {
+		return false
+	}
+	for i := 0; i < exp.Len(); i++ {
+		if !self.compare(exp.Index(i), act.Index(i), path+`[`+strconv.Itoa(i)+`]`) {
+			return false
+		}
+	}
+	return true
+}
+
+// Entries are visited in the order of their printed keys, which makes the
+// reported path deterministic.
+func (self *differ) equalMap(exp, act reflect.Value, path string) bool {
+	if exp.Len() != act.Len() {
+		return false
+	}
+
+	keyFmter := fmter{conf: &self.conf}
+	keyFmter.ownConf()
+	keyFmter.conf.SortKeys = true
+	keyFmter.conf.Include = nil
+	keyFmter.conf.Exclude = nil
+	keyFmter.conf.EmptyMapValues = EmptyDefault
+
+	for _, entry := range mapEntries(exp, keyFmter) {
+		child := path + keySegment(entry.key, self.conf)
+		if !self.compare(entry.val, act.MapIndex(entry.key), child) {
+			return false
+		}
+	}
+	return true
+}
+
+func (self *differ) equalStruct(exp, act reflect.Value, path string) bool {
+	rtype := exp.Type()
+	for i := 0; i < rtype.NumField(); i++ {
+		sfield := rtype.Field(i)
+		if !isSfieldExported(sfield) {
+			continue
+		}
+		if !self.compare(exp.Field(i), act.Field(i), path+`.`+sfield.Name) {
+			return false
+		}
+	}
+	return true
+}
+
+// Describes a divergent value in single-line mode, followed by its type, since
+// values of different types may be printed identically. Non-nil funcs and
+// channels are described by their types, since they're printed as nil.
+func (self *differ) describe(rval reflect.Value) string {
+	if !rval.IsValid() {
+		return `<missing>`
+	}
+	if (rval.Kind() == reflect.Func || rval.Kind() == reflect.Chan) && !rval.IsNil() {
+		return `non-nil ` + rval.Type().String()
+	}
+	conf := self.conf
+	conf.Indent = ``
+	conf.LinePrefix = ``
+	conf.Validate = false
+	return StringC(rval.Interface(), conf) + ` of type ` + rval.Type().String()
+}
+
+// Unlike "==", considers NaN equal to itself, and distinguishes negative zero,
+// which is printed differently.
+func floatEqual(one, two float64) bool {
+	return (one == two && math.Signbit(one) == math.Signbit(two)) ||
+		(math.IsNaN(one) && math.IsNaN(two))
+}
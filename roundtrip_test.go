@@ -0,0 +1,86 @@
+package repr
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/mitranim/repr/test"
+)
+
+func TestRoundTrip(t *testing.T) {
+	for _, conf := range []Config{Default, CodegenConfig, {}} {
+		err := RoundTrip(testStructure, conf)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	type Inner struct {
+		Vals  map[string][]int
+		Any   interface{}
+		Funcs []func()
+	}
+	type Outer struct {
+		Name  string
+		Inner *Inner
+	}
+
+	val := Outer{
+		Name: `one`,
+		Inner: &Inner{
+			Vals: map[string][]int{`two`: {1, 2, 3}, `three`: nil},
+			Any:  test.AbiParam{Name: `four`},
+		},
+	}
+	err := RoundTrip(val, Default)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, val := range []interface{}{(chan<- int)(nil), (func(int, ...string) error)(nil)} {
+		err := RoundTrip(val, Default)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	conf := Default
+	conf.MaxElems = 2
+	testRoundTripError(t, val, conf, `repr: round-trip mismatch at .Inner.Vals["two"]: expected []int{1, 2 /* 1 more element */} of type []int, got []int{1, 2} of type []int`)
+
+	val.Inner.Funcs = []func(){nil, func() {}}
+	testRoundTripError(t, val, Default, `repr: round-trip mismatch at .Inner.Funcs[1]: expected non-nil func(), got (func())(nil) of type func()`)
+
+	testRoundTripError(t, func() {}, Default, `repr: round-trip mismatch at root: expected non-nil func(), got (func())(nil) of type func()`)
+}
+
+func TestRoundTripDiffer(t *testing.T) {
+	check := func(exp, act interface{}, expected string) {
+		t.Helper()
+		diff := differ{conf: CompactConfig, seen: map[[2]uintptr]bool{}}
+		if diff.compare(reflect.ValueOf(exp), reflect.ValueOf(act), ``) {
+			t.Fatalf(`expected a difference between %#v and %#v`, exp, act)
+		}
+		actual := diff.path + `: ` + diff.describe(diff.expected) + `, ` + diff.describe(diff.actual)
+		if actual != expected {
+			t.Fatalf("expected:\n%v\nactual:\n%v", expected, actual)
+		}
+	}
+
+	check([]float64{math.Copysign(0, -1)}, []float64{0}, `[0]: math.Copysign(0, -1) of type float64, 0 of type float64`)
+	check([]complex128{complex(0, math.Copysign(0, -1))}, []complex128{0}, `[0]: complex(0, math.Copysign(0, -1)) of type complex128, (0+0i) of type complex128`)
+	check([]interface{}{int64(1)}, []interface{}{1}, `[0]: 1 of type int64, 1 of type int`)
+}
+
+func testRoundTripError(t *testing.T, val interface{}, conf Config, expected string) {
+	t.Helper()
+
+	err := RoundTrip(val, conf)
+	if err == nil {
+		t.Fatalf(`expected a round-trip error`)
+	}
+	if err.Error() != expected {
+		t.Fatalf("expected error:\n%v\nactual error:\n%v", expected, err)
+	}
+}
@@ -0,0 +1,241 @@
+package repr
+
+import (
+	"fmt"
+	"reflect"
+)
+
+/*
+Estimates the length of the output for the given value and config, without
+formatting it. Walks the value the same way as formatting, respecting limits
+such as "Config.MaxElems", but uses upper bounds for the lengths of numbers and
+ignores string escapes, which makes it cheaper than formatting. Useful for
+preallocating buffers for "AppendC", or for deciding whether a value is too
+large to be logged before formatting it:
+
+	out := repr.AppendC(make([]byte, 0, repr.EstimateSize(val, conf)), val, conf)
+
+The estimate is approximate and may be smaller or larger than the actual
+output.
+*/
+func EstimateSize(val interface{}, conf Config) int {
+	return len(conf.LinePrefix) + estimateAny(val, fmter{conf: &conf})
+}
+
+func estimateAny(val interface{}, fmter fmter) int {
+	impl, _ := val.(fmt.GoStringer)
+	if impl != nil {
+		return len(impl.GoString())
+	}
+
+	rval := reflect.ValueOf(val)
+	if !rval.IsValid() {
+		return len(`nil`)
+	}
+	if mayCycle(rval) {
+		fmter, key, _, ok := fmter.visit(rval)
+		if !ok {
+			return len(`nil /* cyclic reference */`)
+		}
+		defer fmter.leave(key)
+		return estimateValue(rval, fmter)
+	}
+	return estimateValue(rval, fmter)
+}
+
+func estimateValue(rval reflect.Value, fmter fmter) int {
+	rtype := rval.Type()
+	size := 0
+
+	switch rtype.Kind() {
+	case reflect.Bool:
+		size = len(`false`)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		size = estimateInt(rval.Int())
+
+	case reflect.Uint, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		size = estimateUint(rval.Uint())
+
+	case reflect.Uint8:
+		size = len(`0x00`)
+
+	case reflect.Uintptr, reflect.UnsafePointer:
+		size = len(`0x`) + rtype.Bits()/4
+
+	case reflect.Float32, reflect.Float64:
+		size = len(`-0.000000000000000`)
+
+	case reflect.Complex64, reflect.Complex128:
+		size = len(`(-0.000000000000000+-0.000000000000000i)`)
+
+	case reflect.String:
+		size = len(rval.String())
+		if limit := fmter.conf.MaxStringLen; limit > 0 && size > limit {
+			size = limit + len(` /* 0000 bytes total */`)
+			if fmter.conf.TruncateMiddle {
+				size += len(`"" + /* … */ `)
+			}
+		}
+		size += len(`""`)
+
+	case reflect.Chan, reflect.Func:
+		size = len(`nil`)
+
+	case reflect.Interface:
+		if rval.IsNil() {
+			return len(`nil`)
+		}
+		return estimateValue(rval.Elem(), fmter)
+
+	case reflect.Ptr:
+		if rval.IsNil() {
+			return len(`nil`)
+		}
+		if fmter.elideType {
+			return estimateAny(rval.Elem().Interface(), fmter)
+		}
+		return len(`&`) + estimateAny(rval.Elem().Interface(), fmter)
+
+	case reflect.Array:
+		size = estimateList(rval, fmter)
+
+	case reflect.Slice:
+		if rval.IsNil() {
+			size = len(`(nil)`)
+		} else {
+			size = estimateList(rval, fmter)
+		}
+
+	case reflect.Struct:
+		size = estimateStruct(rval, fmter)
+
+	case reflect.Map:
+		if rval.IsNil() {
+			size = len(`(nil)`)
+		} else {
+			size = estimateMap(rval, fmter)
+		}
+	}
+
+	if !fmter.elideType {
+		size += len(rtype.String())
+	}
+	return size
+}
+
+// Each element or entry takes a separator in single-line mode, or an indent,
+// comma and newline in multiline mode.
+func (self fmter) elemOverhead() int {
+	if self.conf.SingleLine() {
+		return len(`, `)
+	}
+	return (self.indent+1)*len(self.conf.Indent) + len(`,`) + len(self.conf.newline())
+}
+
+func estimateList(rval reflect.Value, fmter fmter) int {
+	if fmter.atDepthLimit() {
+		return len(`{/* depth limit */}`)
+	}
+	fmter = fmter.enter()
+
+	win := fmter.conf.window(rval.Len())
+	count := win.count()
+	size := len(`{}`) + estimateOmitted(rval.Len(), count)
+
+	if rval.Type().Elem().Kind() == reflect.Uint8 {
+		return size + count*len(`0x00, `)
+	}
+
+	overhead := fmter.elemOverhead()
+	fmter.elideType = canElideType(rval.Type().Elem(), fmter)
+	fmter.indent++
+	for pos := 0; pos < count; pos++ {
+		size += overhead + estimateAny(fmter.elemValue(rval.Index(win.index(pos))).Interface(), fmter)
+	}
+	return size
+}
+
+func estimateStruct(rval reflect.Value, fmter fmter) int {
+	if fmter.atDepthLimit() {
+		return len(`{/* depth limit */}`)
+	}
+	fmter = fmter.enter()
+
+	rtype := rval.Type()
+	size := len(`{}`)
+	overhead := fmter.elemOverhead() + len(fmter.conf.colon())
+	fmter.indent++
+
+	for i, field := range structMetaOf(rtype).fields {
+		sfield := field.StructField
+		if !field.exported {
+			continue
+		}
+
+		rfield := fmter.fieldValue(rtype, sfield, rval.Field(i))
+		if !fmter.conf.ZeroFields && fmter.isZeroField(rfield) {
+			continue
+		}
+
+		name := fmter.fieldName(sfield)
+		if name == `` {
+			continue
+		}
+
+		fmter := fmter
+		fmter.elideType = fmter.canElideField(rfield)
+		size += overhead + len(name) + estimateAny(rfield.Interface(), fmter)
+	}
+	return size
+}
+
+func estimateMap(rval reflect.Value, fmter fmter) int {
+	if fmter.atDepthLimit() {
+		return len(`{/* depth limit */}`)
+	}
+	fmter = fmter.enter()
+
+	rtype := rval.Type()
+	total := rval.Len()
+	count := fmter.conf.limitElems(total)
+	size := len(`{}`) + estimateOmitted(total, count)
+	overhead := fmter.elemOverhead() + len(fmter.conf.colon())
+	fmter.indent++
+
+	keyFmter := fmter
+	keyFmter.elideType = canElideType(rtype.Key(), fmter)
+	elemFmter := fmter
+	elemFmter.elideType = canElideType(rtype.Elem(), fmter)
+
+	iter := rval.MapRange()
+	for i := 0; i < count && iter.Next(); i++ {
+		size += overhead +
+			estimateAny(iter.Key().Interface(), keyFmter) +
+			estimateAny(iter.Value().Interface(), elemFmter)
+	}
+	return size
+}
+
+func estimateOmitted(total, count int) int {
+	if total > count {
+		return len(`/* 0000 more elements */`)
+	}
+	return 0
+}
+
+func estimateInt(val int64) int {
+	if val < 0 {
+		return 1 + estimateUint(uint64(-val))
+	}
+	return estimateUint(uint64(val))
+}
+
+func estimateUint(val uint64) int {
+	size := 1
+	for val >= 10 {
+		val /= 10
+		size++
+	}
+	return size
+}
@@ -0,0 +1,32 @@
+package repr
+
+import (
+	"testing"
+)
+
+func TestEstimateSize(t *testing.T) {
+	test := func(val interface{}, conf Config) {
+		t.Helper()
+		actual := len(BytesC(val, conf))
+		estimate := EstimateSize(val, conf)
+		if estimate < actual/2 || estimate > actual*2 {
+			t.Fatalf(`estimate %v is too far from actual size %v`, estimate, actual)
+		}
+	}
+
+	test(testStructure, Default)
+	test(testStructure, CompactConfig)
+	test(testStructure, DebugConfig)
+	test(map[string][]int{`one`: {1, 2, 3}, `two`: {4, 5, 6}}, Default)
+	test([]byte(`hello world`), Default)
+
+	if EstimateSize(nil, Default) != len(`nil`) {
+		t.Fatalf(`unexpected estimate for nil`)
+	}
+}
+
+func BenchmarkEstimateSize(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		_ = EstimateSize(testStructure, Default)
+	}
+}
@@ -0,0 +1,20 @@
+// Requires a released version of the root module, which must be tagged first.
+// For local development, use a workspace in the repository root:
+//
+//	go work init . ./source ./testify
+//	go work edit -replace github.com/mitranim/repr@v0.2.0=./
+//
+// The replace is needed only until the required version is tagged.
+module github.com/mitranim/repr/source
+
+go 1.24.0
+
+require (
+	github.com/mitranim/repr v0.2.0
+	golang.org/x/tools v0.40.0
+)
+
+require (
+	golang.org/x/mod v0.31.0 // indirect
+	golang.org/x/sync v0.19.0 // indirect
+)
@@ -0,0 +1,354 @@
+/*
+Source-aware extensions for "github.com/mitranim/repr", backed by
+"golang.org/x/tools/go/packages". Kept in a separate module to preserve the
+zero dependencies of the core.
+
+Reflection can't see constant names, type aliases or whether a type name is
+actually accessible to generated code. This package loads the defining packages
+from source, which allows to print enum-like values as identifiers of their
+constants, and to verify that every type name in the output can be referenced:
+
+	res, err := source.Load(`example.com/mod/pkg`)
+	if err != nil {
+		panic(err)
+	}
+
+	val := pkg.KindFoo
+	if err := res.Verify(val); err != nil {
+		panic(err)
+	}
+
+	fmt.Println(repr.StringC(val, res.Config(repr.Default)))
+	// pkg.KindFoo
+
+This is considerably slower than reflection alone, because it type-checks the
+loaded packages and their dependencies. Load once and reuse the "Resolver".
+*/
+package source
+
+import (
+	"fmt"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/mitranim/repr"
+	"golang.org/x/tools/go/packages"
+)
+
+/*
+Mode used by "Load". Includes dependencies, which allows "Resolver.Verify" to
+check types from packages other than the requested ones.
+*/
+const LoadMode = packages.NeedName | packages.NeedTypes | packages.NeedImports | packages.NeedDeps
+
+/*
+Loads the packages matching the patterns, which follow the rules of
+"go list", relative to the current directory. Short for "LoadConfig" with
+"LoadMode".
+*/
+func Load(patterns ...string) (*Resolver, error) {
+	return LoadConfig(&packages.Config{Mode: LoadMode}, patterns...)
+}
+
+/*
+Loads the packages matching the patterns, using the provided config, which
+must include "LoadMode". Returns an error if any package fails to load or
+type-check.
+*/
+func LoadConfig(conf *packages.Config, patterns ...string) (*Resolver, error) {
+	pkgs, err := packages.Load(conf, patterns...)
+	if err != nil {
+		return nil, fmt.Errorf(`repr: failed to load packages: %w`, err)
+	}
+
+	var errs []string
+	packages.Visit(pkgs, nil, func(pkg *packages.Package) {
+		for _, err := range pkg.Errors {
+			errs = append(errs, err.Error())
+		}
+	})
+	if len(errs) > 0 {
+		return nil, fmt.Errorf(`repr: failed to load packages: %v`, strings.Join(errs, `; `))
+	}
+
+	res := &Resolver{
+		pkgs:   map[string]*types.Package{},
+		consts: map[string]map[string]string{},
+	}
+	packages.Visit(pkgs, nil, func(pkg *packages.Package) {
+		if pkg.Types != nil {
+			res.pkgs[pkg.PkgPath] = pkg.Types
+		}
+	})
+	for _, pkg := range pkgs {
+		if pkg.Types != nil {
+			res.addConsts(pkg.Types)
+		}
+	}
+	return res, nil
+}
+
+/*
+Knowledge about the source code of loaded packages. Maps constant values back
+to identifiers and verifies type names. Safe for concurrent use once loaded.
+*/
+type Resolver struct {
+	// Package path -> package, including dependencies.
+	pkgs map[string]*types.Package
+
+	// Qualified type name -> exact constant value -> constant name. Includes
+	// only the packages matched by the patterns passed to "Load", not their
+	// dependencies.
+	consts map[string]map[string]string
+}
+
+// Constants are indexed by the named types they belong to, resolving aliases.
+// Only constants declared in the same package as their type are indexed,
+// because "repr.Config.ConstName" qualifies names with the package of the
+// type. When several constants share a value, exported names win, then the
+// alphabetically first.
+func (self *Resolver) addConsts(pkg *types.Package) {
+	scope := pkg.Scope()
+
+	for _, name := range scope.Names() {
+		obj, _ := scope.Lookup(name).(*types.Const)
+		if obj == nil || name == `_` {
+			continue
+		}
+
+		named, _ := types.Unalias(obj.Type()).(*types.Named)
+		if named == nil || named.Obj().Pkg() != pkg {
+			continue
+		}
+
+		key := typeKey(pkg.Path(), named.Obj().Name())
+		vals := self.consts[key]
+		if vals == nil {
+			vals = map[string]string{}
+			self.consts[key] = vals
+		}
+
+		val := obj.Val().ExactString()
+		prev, ok := vals[val]
+		if !ok || (!token.IsExported(prev) && token.IsExported(name)) {
+			vals[val] = name
+		}
+	}
+}
+
+/*
+Returns the name of an exported constant of the value's type, declared in the
+package of that type, equal to the value, or an empty string. Suitable for
+"repr.Config.ConstName". Unexported constants can't be referenced outside their
+package, see "Resolver.Config".
+*/
+func (self *Resolver) ConstName(_ string, val interface{}) string {
+	return self.constName(val, false)
+}
+
+func (self *Resolver) constName(val interface{}, unexported bool) string {
+	rtype := reflect.TypeOf(val)
+	if rtype == nil || rtype.Name() == `` {
+		return ``
+	}
+
+	vals := self.consts[typeKey(rtype.PkgPath(), rtype.Name())]
+	if vals == nil {
+		return ``
+	}
+
+	exact := exactValue(reflect.ValueOf(val))
+	if exact == nil {
+		return ``
+	}
+
+	name := vals[exact.ExactString()]
+	if !unexported && !token.IsExported(name) {
+		return ``
+	}
+	return name
+}
+
+/*
+Returns a copy of the config that uses this resolver for
+"repr.Config.ConstName". Unexported constants are used only for types from
+packages printed without qualifiers, which are mapped to empty names in
+"repr.Config.PackageMap", since the output belongs to those packages.
+*/
+func (self *Resolver) Config(conf repr.Config) repr.Config {
+	pkgs := conf.PackageMap
+	conf.ConstName = func(_ string, val interface{}) string {
+		pkg, ok := pkgs[reflect.TypeOf(val).PkgPath()]
+		return self.constName(val, ok && pkg == ``)
+	}
+	return conf
+}
+
+/*
+Verifies that the output for the value can be compiled outside the packages of
+its types: every named type reachable from the value must be declared and
+exported by a loaded package. For unexported types, the error mentions
+exported aliases declared in loaded packages, if any. Returns an error
+describing every offending type, or nil.
+*/
+func (self *Resolver) Verify(val interface{}) error {
+	walker := walker{seen: map[reflect.Type]bool{}, ptrs: map[uintptr]bool{}}
+	walker.walk(reflect.ValueOf(val))
+
+	var errs []string
+	for _, rtype := range walker.types {
+		err := self.verifyType(rtype)
+		if err != `` {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return fmt.Errorf(`repr: unresolvable type names: %v`, strings.Join(errs, `; `))
+	}
+	return nil
+}
+
+func (self *Resolver) verifyType(rtype reflect.Type) string {
+	path := rtype.PkgPath()
+	name := typeName(rtype)
+
+	pkg := self.pkgs[path]
+	if pkg == nil {
+		return fmt.Sprintf(`%v: package %q is not loaded`, rtype, path)
+	}
+
+	obj, _ := pkg.Scope().Lookup(name).(*types.TypeName)
+	if obj == nil {
+		return fmt.Sprintf(`%v: type %q is not declared in package %q`, rtype, name, path)
+	}
+
+	if !obj.Exported() {
+		aliases := self.aliases(obj)
+		if len(aliases) > 0 {
+			return fmt.Sprintf(`%v: type %q is unexported; exported aliases: %v`, rtype, name, strings.Join(aliases, `, `))
+		}
+		return fmt.Sprintf(`%v: type %q is unexported`, rtype, name)
+	}
+	return ``
+}
+
+// Finds exported aliases of the given type in all loaded packages.
+func (self *Resolver) aliases(target *types.TypeName) []string {
+	var out []string
+	for path, pkg := range self.pkgs {
+		scope := pkg.Scope()
+		for _, name := range scope.Names() {
+			obj, _ := scope.Lookup(name).(*types.TypeName)
+			if obj == nil || !obj.IsAlias() || !obj.Exported() {
+				continue
+			}
+			named, _ := types.Unalias(obj.Type()).(*types.Named)
+			if named != nil && named.Obj() == target {
+				out = append(out, path+`.`+name)
+			}
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Collects named types with package paths, reachable from a value.
+type walker struct {
+	seen  map[reflect.Type]bool
+	ptrs  map[uintptr]bool
+	types []reflect.Type
+}
+
+func (self *walker) walk(rval reflect.Value) {
+	if !rval.IsValid() {
+		return
+	}
+
+	rtype := rval.Type()
+	self.add(rtype)
+
+	switch rtype.Kind() {
+	case reflect.Ptr:
+		if rval.IsNil() || self.ptrs[rval.Pointer()] {
+			return
+		}
+		self.ptrs[rval.Pointer()] = true
+		self.walk(rval.Elem())
+
+	case reflect.Interface:
+		self.walk(rval.Elem())
+
+	case reflect.Array, reflect.Slice:
+		self.walkType(rtype.Elem())
+		for i := 0; i < rval.Len(); i++ {
+			self.walk(rval.Index(i))
+		}
+
+	case reflect.Map:
+		self.walkType(rtype.Key())
+		self.walkType(rtype.Elem())
+		iter := rval.MapRange()
+		for iter.Next() {
+			self.walk(iter.Key())
+			self.walk(iter.Value())
+		}
+
+	case reflect.Struct:
+		for i := 0; i < rtype.NumField(); i++ {
+			if rtype.Field(i).PkgPath == `` {
+				self.walk(rval.Field(i))
+			}
+		}
+	}
+}
+
+// Element types of collections are printed even when empty.
+func (self *walker) walkType(rtype reflect.Type) {
+	for rtype.Kind() == reflect.Ptr || rtype.Kind() == reflect.Slice || rtype.Kind() == reflect.Array {
+		rtype = rtype.Elem()
+	}
+	self.add(rtype)
+}
+
+func (self *walker) add(rtype reflect.Type) {
+	if rtype.Name() != `` && rtype.PkgPath() != `` && !self.seen[rtype] {
+		self.seen[rtype] = true
+		self.types = append(self.types, rtype)
+	}
+}
+
+func typeKey(path, name string) string { return path + `.` + name }
+
+// Strips type arguments from names of instantiated generic types.
+func typeName(rtype reflect.Type) string {
+	name := rtype.Name()
+	if index := strings.IndexByte(name, '['); index >= 0 {
+		return name[:index]
+	}
+	return name
+}
+
+// Converts a reflected value to a constant with the same exact value as a
+// typed constant declared in source, which go/types rounds to its type.
+func exactValue(rval reflect.Value) constant.Value {
+	switch rval.Kind() {
+	case reflect.Bool:
+		return constant.MakeBool(rval.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return constant.MakeInt64(rval.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return constant.MakeUint64(rval.Uint())
+	case reflect.Float32, reflect.Float64:
+		return constant.MakeFloat64(rval.Float())
+	case reflect.String:
+		return constant.MakeString(rval.String())
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,91 @@
+package source
+
+import (
+	"testing"
+
+	"github.com/mitranim/repr"
+	"github.com/mitranim/repr/source/testdata/fixture"
+	"github.com/mitranim/repr/test"
+)
+
+func load(t *testing.T) *Resolver {
+	t.Helper()
+	res, err := Load(`github.com/mitranim/repr/test`, `./testdata/fixture`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+func TestConstName(t *testing.T) {
+	res := load(t)
+
+	check := func(val interface{}, expected string) {
+		t.Helper()
+		actual := res.ConstName(``, val)
+		if actual != expected {
+			t.Fatalf("expected %q, got %q", expected, actual)
+		}
+	}
+
+	check(test.AbiKindUint, `AbiKindUint`)
+	check(test.AbiKind(100), ``)
+	check(fixture.LevelLow, `LevelLow`)
+	check(fixture.LevelHigh, `LevelHigh`)
+	check(fixture.ColorRed, `ColorRed`)
+	check(fixture.Color(`blue`), ``)
+	check(byte(2), ``)
+	check(nil, ``)
+}
+
+func TestConfig(t *testing.T) {
+	res := load(t)
+
+	actual := repr.StringC(
+		fixture.Outer{Inner: []test.AbiKind{test.AbiKindInt, 100}, Color: fixture.ColorGreen},
+		res.Config(repr.Default),
+	)
+	expected := `fixture.Outer{
+	Inner: []test.AbiKind{test.AbiKindInt, 100},
+	Color: fixture.ColorGreen,
+}`
+	if actual != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+	}
+
+	// Unexported constants are only used inside their package.
+	conf := repr.CompactConfig
+	actual = repr.StringC(fixture.Color(`blue`), res.Config(conf))
+	if actual != `fixture.Color("blue")` {
+		t.Fatalf(`unexpected output outside the package: %v`, actual)
+	}
+
+	conf.PackageMap = map[string]string{`github.com/mitranim/repr/source/testdata/fixture`: ``}
+	actual = repr.StringC(fixture.Color(`blue`), res.Config(conf))
+	if actual != `colorBlue` {
+		t.Fatalf(`unexpected output inside the package: %v`, actual)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	res := load(t)
+
+	err := res.Verify(fixture.Outer{Inner: test.AbiParam{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = res.Verify(fixture.Outer{Inner: fixture.MakeHidden()})
+	if err == nil {
+		t.Fatal(`expected an error for an unexported type`)
+	}
+	expected := `repr: unresolvable type names: fixture.hidden: type "hidden" is unexported; exported aliases: github.com/mitranim/repr/source/testdata/fixture.Hidden`
+	if err.Error() != expected {
+		t.Fatalf("expected error:\n%v\nactual error:\n%v", expected, err)
+	}
+
+	err = res.Verify(repr.Config{})
+	if err == nil {
+		t.Fatal(`expected an error for a type from a package that isn't loaded`)
+	}
+}
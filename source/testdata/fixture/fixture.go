@@ -0,0 +1,28 @@
+package fixture
+
+type Level float64
+
+const (
+	LevelLow  Level = 0.5
+	LevelHigh Level = 2
+)
+
+type Color string
+
+const (
+	ColorRed   Color = `red`
+	colorRed   Color = `red`
+	ColorGreen Color = `green`
+	colorBlue  Color = `blue`
+)
+
+type hidden struct{ Val int }
+
+type Hidden = hidden
+
+type Outer struct {
+	Inner interface{}
+	Color Color
+}
+
+func MakeHidden() interface{} { return hidden{} }
@@ -0,0 +1,228 @@
+package repr
+
+import (
+	"bytes"
+)
+
+/*
+Formatting is recursive, and every nested value takes several stack frames.
+Goroutine stacks grow as needed, but values nested many thousands of levels
+deep, such as long linked lists, would need a proportionally large stack. To
+keep the stack bounded, values nested more than "maxNest" levels below the
+start of a recursive pass are deferred: the formatter records where the value
+belongs in the output and moves on. Deferred values are then formatted from an
+explicit stack, each in a new pass starting at the bottom of the call stack,
+and their outputs are spliced into place.
+
+Splicing changes byte offsets, so deferring is disabled where offsets matter:
+while measuring, see "fmter.measure", and while recording positions, see
+"Config.Positions". Formatting into a scratch buffer, such as rendering map
+keys for sorting, must not defer into the stack of the main output, which is
+ensured by resetting "fmter.stack".
+
+Deferred values must detect references to their ancestors, see "visiting". The
+ancestors stop visiting their references before the deferred value is
+formatted, so each deferred value remembers the references visited by its
+ancestors in the enclosing pass, and restores them before formatting. Copying
+all visited references instead would make deep values quadratic.
+*/
+type valueStack struct {
+	tasks []deferredValue
+
+	// Task being formatted, or -1 for the initial pass.
+	current int
+
+	// Positions of deferred values in the output of the initial pass.
+	holes []hole
+
+	// Shared by all passes. Contains the references visited by the ancestors
+	// of the current value, including those restored by tasks in "chain".
+	visiting visiting
+
+	// References visited by ancestors in the current pass, in order.
+	visited []visitEntry
+
+	// Current task and its ancestor tasks whose references are restored.
+	chain []int
+}
+
+type visitEntry struct {
+	key  visitKey
+	path string
+}
+
+type deferredValue struct {
+	val   interface{}
+	fmter fmter
+
+	// Enclosing task, or -1 for the initial pass.
+	parent int
+
+	// References visited by ancestors in the pass of the enclosing task.
+	visited []visitEntry
+
+	// Output starts with a copy of the current line of the enclosing output,
+	// which keeps column-dependent layout decisions intact, see "column". Only
+	// the last "Config.MaxWidth" bytes of the line matter.
+	seed  int
+	out   []byte
+	holes []hole
+}
+
+// Position of a deferred value in the output of the enclosing value.
+type hole struct {
+	offset int
+	task   int
+}
+
+// Maximum nesting of values formatted recursively in one pass.
+const maxNest = 256
+
+// Deferring requires splicing the output afterwards, see "valueStack".
+func (self fmter) defers() bool {
+	return self.state == nil || (!self.state.measuring && self.state.positions == nil)
+}
+
+/*
+Called by "appendAny" for values nested too deeply. The first such value starts
+a new stack and formats its contents, deferring deeply nested values to the
+stack. Other values are deferred to the stack of their enclosing value.
+*/
+func appendNested(out []byte, val interface{}, fmter fmter) []byte {
+	if fmter.stack != nil {
+		return fmter.stack.push(out, val, fmter)
+	}
+
+	if fmter.visiting == nil {
+		fmter.visiting = visiting{}
+	}
+	stack := &valueStack{current: -1, visiting: fmter.visiting}
+	fmter.stack = stack
+	fmter.nest = 0
+
+	start := len(out)
+	out = appendAny(out, val, fmter)
+	if len(stack.holes) == 0 {
+		return out
+	}
+
+	stack.run()
+	src := append([]byte(nil), out[start:]...)
+	for i := range stack.holes {
+		stack.holes[i].offset -= start
+	}
+	return stack.splice(out[:start], src, stack.holes)
+}
+
+func (self *valueStack) push(out []byte, val interface{}, fmter fmter) []byte {
+	fmter.nest = 0
+
+	line := out[bytes.LastIndexByte(out, '\n')+1:]
+	if len(line) > fmter.conf.MaxWidth {
+		line = line[len(line)-fmter.conf.MaxWidth:]
+	}
+	index := len(self.tasks)
+	self.tasks = append(self.tasks, deferredValue{
+		val:     val,
+		fmter:   fmter,
+		seed:    len(line),
+		out:     append([]byte(nil), line...),
+		parent:  self.current,
+		visited: append([]visitEntry(nil), self.visited...),
+	})
+
+	hole := hole{offset: len(out), task: index}
+	if self.current < 0 {
+		self.holes = append(self.holes, hole)
+	} else {
+		self.tasks[self.current].holes = append(self.tasks[self.current].holes, hole)
+	}
+	return out
+}
+
+// Formats deferred values in the order of their appearance in the output.
+// Formatting a value may defer more values, which are pushed to the stack.
+func (self *valueStack) run() {
+	var pending []int
+	pending = pushHoles(pending, self.holes)
+
+	for len(pending) > 0 {
+		index := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+
+		self.current = index
+		self.restore(index)
+		task := self.tasks[index]
+		out := appendAny(task.out, task.val, task.fmter)
+
+		// Formatting may have appended to "tasks", which invalidates "task".
+		self.tasks[index].out = out
+		pending = pushHoles(pending, self.tasks[index].holes)
+	}
+	self.current = -1
+	self.restore(-1)
+}
+
+/*
+Makes "visiting" contain the references visited by the ancestors of the task,
+and only them. Tasks run in order of appearance, so the enclosing task is
+always in "chain", and tasks which aren't ancestors are at its end.
+*/
+func (self *valueStack) restore(index int) {
+	parent := -1
+	if index >= 0 {
+		parent = self.tasks[index].parent
+	}
+
+	for len(self.chain) > 0 && self.chain[len(self.chain)-1] != parent {
+		for _, entry := range self.tasks[self.chain[len(self.chain)-1]].visited {
+			delete(self.visiting, entry.key)
+		}
+		self.chain = self.chain[:len(self.chain)-1]
+	}
+
+	if index >= 0 {
+		for _, entry := range self.tasks[index].visited {
+			self.visiting[entry.key] = entry.path
+		}
+		self.chain = append(self.chain, index)
+	}
+	self.visited = self.visited[:0]
+}
+
+func pushHoles(pending []int, holes []hole) []int {
+	for i := len(holes) - 1; i >= 0; i-- {
+		pending = append(pending, holes[i].task)
+	}
+	return pending
+}
+
+type spliceFrame struct {
+	src   []byte
+	holes []hole
+	pos   int
+}
+
+// Copies the output to the buffer, replacing holes with the outputs of deferred
+// values, which may have holes of their own.
+func (self *valueStack) splice(out []byte, src []byte, holes []hole) []byte {
+	frames := []spliceFrame{{src: src, holes: holes}}
+
+	for len(frames) > 0 {
+		frame := &frames[len(frames)-1]
+		if len(frame.holes) == 0 {
+			out = append(out, frame.src[frame.pos:]...)
+			frames = frames[:len(frames)-1]
+			continue
+		}
+
+		hole := frame.holes[0]
+		out = append(out, frame.src[frame.pos:hole.offset]...)
+		frame.pos = hole.offset
+		frame.holes = frame.holes[1:]
+
+		task := self.tasks[hole.task]
+		frames = append(frames, spliceFrame{src: task.out, holes: task.holes, pos: task.seed})
+	}
+	return out
+}
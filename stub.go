@@ -0,0 +1,86 @@
+package repr
+
+import (
+	"reflect"
+	"strings"
+)
+
+/*
+Stub functions generated for non-nil funcs, see "File.FuncStubs". Stubs are
+shared by funcs with the same type and code, such as several references to the
+same closure.
+*/
+type funcStubs struct {
+	decls *Decls
+	names map[funcStubKey]string
+	code  []byte
+}
+
+type funcStubKey struct {
+	rtype reflect.Type
+	pc    uintptr
+}
+
+func (self fmter) stubsFuncs() bool {
+	return self.state != nil && self.state.stubs != nil
+}
+
+// True if non-nil funcs are printed as something other than nil, see
+// "Config.FuncNames" and "File.FuncStubs".
+func (self fmter) printsFuncs() bool {
+	return self.conf.FuncNames || self.stubsFuncs()
+}
+
+// Prints a reference to a stub function, declaring the stub on first use.
+// Stubs have unnamed func types, like functions referenced by "appendFunc",
+// which only require conversions to named types.
+func appendFuncStub(out []byte, rval reflect.Value, fmter fmter) []byte {
+	if rval.Type().Name() == `` {
+		return append(out, fmter.state.stubs.name(rval, fmter)...)
+	}
+	out = appendCastPrefix(out, rval, fmter)
+	out = append(out, fmter.state.stubs.name(rval, fmter)...)
+	return appendCastSuffix(out, rval, fmter)
+}
+
+/*
+Returns the name of the stub for the func, declaring it on first use:
+
+	// Stub for func(int) error closure defined at handlers.go:123.
+	func stubHandler(int) error {
+		panic("stub")
+	}
+
+Parameters are unnamed, since their names aren't available via reflection.
+*/
+func (self *funcStubs) name(rval reflect.Value, fmter fmter) string {
+	key := funcStubKey{rval.Type(), rval.Pointer()}
+	name, ok := self.names[key]
+	if ok {
+		return name
+	}
+
+	name = self.decls.unique(funcStubName(fmter.path))
+	self.names[key] = name
+
+	self.code = append(self.code, '\n')
+	self.code = append(self.code, `// Stub for `...)
+	self.code = append(self.code, strings.Join(strings.Fields(describeFunc(rval)), ` `)...)
+	self.code = append(self.code, ".\nfunc "...)
+	self.code = append(self.code, name...)
+	self.code = appendFuncSignature(self.code, rval.Type(), fmter)
+	self.code = append(self.code, " {\n\tpanic(\"stub\")\n}\n"...)
+	return name
+}
+
+// Names the stub after the innermost struct field containing the func, such
+// as "stubHandler" for ".Routes[0].Handler", or "stubFunc" if there's none.
+func funcStubName(path string) string {
+	segments := splitPath(path)
+	for i := len(segments) - 1; i >= 0; i-- {
+		if strings.HasPrefix(segments[i], `.`) {
+			return `stub` + segments[i][len(`.`):]
+		}
+	}
+	return `stubFunc`
+}
@@ -0,0 +1,157 @@
+package repr
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type testHandler func(io.Writer, ...string) (int, error)
+
+func TestFileFuncStubs(t *testing.T) {
+	type Route struct {
+		Path    string
+		Handler testHandler
+		Format  func(string) string
+		Hooks   []func()
+	}
+
+	handler := testHandler(func(io.Writer, ...string) (int, error) { return 0, nil })
+
+	file := File{Package: `fixtures`, FuncStubs: true}
+	file.Decls.Config = Default
+	file.Decls.Config.FuncNames = true
+	file.Decls.AddNamed(`routes`, []Route{
+		{Path: `/one`, Handler: handler, Format: strings.ToUpper},
+		{Path: `/two`, Handler: handler, Hooks: []func(){func() {}}},
+	})
+	file.Decls.AddNamed(`handler`, []interface{}{handler})
+
+	actual, err := file.Bytes()
+	if err != nil {
+		t.Fatalf("failed to generate file: %v", err)
+	}
+
+	expected := `package fixtures
+
+import (
+	"github.com/mitranim/repr"
+	"io"
+	"strings"
+)
+
+var (
+	routes = []repr.Route{
+		{
+			Path:    "/one",
+			Handler: stubHandler,
+			Format:  strings.ToUpper,
+		},
+		{
+			Path:    "/two",
+			Handler: stubHandler,
+			Hooks: []func(){
+				stubHooks,
+			},
+		},
+	}
+
+	handler = []interface{}{
+		repr.testHandler(stubHandler),
+	}
+)
+
+// Stub for repr.testHandler closure defined at stub_test.go:20.
+func stubHandler(io.Writer, ...string) (int, error) {
+	panic("stub")
+}
+
+// Stub for func() closure defined at stub_test.go:27.
+func stubHooks() {
+	panic("stub")
+}
+`
+	if string(actual) != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%s", expected, actual)
+	}
+}
+
+func TestFileFuncStubsWithoutFuncNames(t *testing.T) {
+	type Job struct {
+		Name string
+		Run  func() error
+	}
+
+	file := File{Package: `fixtures`, FuncStubs: true}
+	file.Decls.Config = Default
+	file.Decls.AddNamed(`job`, Job{Name: `one`, Run: func() error { return nil }})
+	file.Decls.AddNamed(`funcs`, []interface{}{func() {}})
+
+	actual, err := file.Bytes()
+	if err != nil {
+		t.Fatalf("failed to generate file: %v", err)
+	}
+
+	expected := `package fixtures
+
+import (
+	"github.com/mitranim/repr"
+)
+
+var (
+	job = repr.Job{
+		Name: "one",
+		Run:  stubRun,
+	}
+
+	funcs = []interface{}{
+		stubFunc,
+	}
+)
+
+// Stub for func() error closure defined at stub_test.go:88.
+func stubRun() error {
+	panic("stub")
+}
+
+// Stub for func() closure defined at stub_test.go:89.
+func stubFunc() {
+	panic("stub")
+}
+`
+	if string(actual) != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%s", expected, actual)
+	}
+
+	file.Decls = Decls{Config: Default}
+	file.Decls.Config.ZeroFields = true
+	file.Decls.AddNamed(`job`, Job{Run: func() error { return nil }})
+
+	actual, err = file.Bytes()
+	if err != nil {
+		t.Fatalf("failed to generate file: %v", err)
+	}
+	if !strings.Contains(string(actual), "\tRun:  stubRun,\n") {
+		t.Fatalf("expected the stub to be assigned as is:\n%s", actual)
+	}
+}
+
+func TestFuncTypeName(t *testing.T) {
+	for _, val := range []interface{}{
+		func() {},
+		func(int, ...string) {},
+		func([]byte) error { return nil },
+		func(map[string]int) (int, error) { return 0, nil },
+		testHandler(nil),
+	} {
+		rtype := reflect.TypeOf(val)
+		if rtype.Name() != `` {
+			rtype = reflect.FuncOf(nil, nil, false)
+		}
+		actual := string(appendTypeName(nil, rtype, fmter{conf: &Config{}}))
+		if actual != rtype.String() {
+			t.Fatalf(`expected %q, got %q`, rtype.String(), actual)
+		}
+	}
+}
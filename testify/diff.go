@@ -0,0 +1,97 @@
+package testify
+
+import "strings"
+
+/*
+Maximum size of the table used by "diff", in cells. Larger inputs fall back on
+a diff which only skips common leading and trailing lines.
+*/
+const maxDiffCells = 1 << 20
+
+/*
+Returns a line diff between two strings, with removed lines prefixed by "-",
+added lines by "+", and common lines by a space. Uses the longest common
+subsequence of lines, which is quadratic, but adequate for test values.
+Common leading and trailing lines are excluded from the quadratic part, which
+is skipped for large inputs, see "maxDiffCells".
+*/
+func diff(one, two string) string {
+	prev := strings.Split(one, "\n")
+	next := strings.Split(two, "\n")
+
+	var buf strings.Builder
+	line := func(prefix byte, text string) {
+		buf.WriteByte(prefix)
+		buf.WriteString(text)
+		buf.WriteByte('\n')
+	}
+
+	head := 0
+	for head < len(prev) && head < len(next) && prev[head] == next[head] {
+		line(' ', prev[head])
+		head++
+	}
+	tail := 0
+	for tail < len(prev)-head && tail < len(next)-head &&
+		prev[len(prev)-1-tail] == next[len(next)-1-tail] {
+		tail++
+	}
+
+	diffLines(prev[head:len(prev)-tail], next[head:len(next)-tail], line)
+
+	for _, text := range prev[len(prev)-tail:] {
+		line(' ', text)
+	}
+	return buf.String()
+}
+
+func diffLines(prev, next []string, line func(byte, string)) {
+	if (len(prev)+1)*(len(next)+1) > maxDiffCells {
+		for _, text := range prev {
+			line('-', text)
+		}
+		for _, text := range next {
+			line('+', text)
+		}
+		return
+	}
+
+	// lengths[i][j] is the length of the LCS of prev[i:] and next[j:].
+	lengths := make([][]int, len(prev)+1)
+	for i := range lengths {
+		lengths[i] = make([]int, len(next)+1)
+	}
+	for i := len(prev) - 1; i >= 0; i-- {
+		for j := len(next) - 1; j >= 0; j-- {
+			if prev[i] == next[j] {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else if lengths[i+1][j] >= lengths[i][j+1] {
+				lengths[i][j] = lengths[i+1][j]
+			} else {
+				lengths[i][j] = lengths[i][j+1]
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(prev) && j < len(next) {
+		switch {
+		case prev[i] == next[j]:
+			line(' ', prev[i])
+			i++
+			j++
+		case lengths[i+1][j] >= lengths[i][j+1]:
+			line('-', prev[i])
+			i++
+		default:
+			line('+', next[j])
+			j++
+		}
+	}
+	for ; i < len(prev); i++ {
+		line('-', prev[i])
+	}
+	for ; j < len(next); j++ {
+		line('+', next[j])
+	}
+}
@@ -0,0 +1,21 @@
+// Requires a released version of the root module, which must be tagged first.
+// For local development, use a workspace in the repository root:
+//
+//	go work init . ./source ./testify
+//	go work edit -replace github.com/mitranim/repr@v0.2.0=./
+//
+// The replace is needed only until the required version is tagged.
+module github.com/mitranim/repr/testify
+
+go 1.18
+
+require (
+	github.com/mitranim/repr v0.2.0
+	github.com/stretchr/testify v1.9.0
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
@@ -0,0 +1,139 @@
+/*
+Adapters for "github.com/stretchr/testify", kept in a separate module to
+preserve the zero dependencies of the core. Assertions in this package have the
+same signatures and semantics as their counterparts in "assert" and "require",
+but failure messages show values as Go code produced by "repr", which can be
+pasted into tests as expected values:
+
+	import reprtest "github.com/mitranim/repr/testify"
+
+	reprtest.Equal(t, expected, actual)
+
+The message includes a line diff of the formatted values. Formatting is
+controlled by "Config".
+*/
+package testify
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mitranim/repr"
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+Config used for formatting values in failure messages. Sorts map keys, which
+makes the diff meaningful. May be modified, but not concurrently with running
+assertions.
+*/
+var Config = repr.Config{
+	Indent:     "\t",
+	PackageMap: map[string]string{`main`: ``},
+	SortKeys:   true,
+}
+
+/*
+Interface required by "Require*" functions. Satisfied by "*testing.T".
+*/
+type TestingT interface {
+	assert.TestingT
+	FailNow()
+}
+
+/*
+Same as "assert.Equal", but the failure message shows values as Go code. See
+"Message".
+*/
+func Equal(t assert.TestingT, expected, actual interface{}, msgAndArgs ...interface{}) bool {
+	if assert.ObjectsAreEqual(expected, actual) {
+		return true
+	}
+	if helper, ok := t.(interface{ Helper() }); ok {
+		helper.Helper()
+	}
+	return assert.Fail(t, Message(expected, actual), msgAndArgs...)
+}
+
+/*
+Same as "assert.EqualValues", but the failure message shows values as Go code.
+See "Message".
+*/
+func EqualValues(t assert.TestingT, expected, actual interface{}, msgAndArgs ...interface{}) bool {
+	if assert.ObjectsAreEqualValues(expected, actual) {
+		return true
+	}
+	if helper, ok := t.(interface{ Helper() }); ok {
+		helper.Helper()
+	}
+	return assert.Fail(t, Message(expected, actual), msgAndArgs...)
+}
+
+/*
+Same as "require.Equal", but the failure message shows values as Go code. See
+"Message".
+*/
+func RequireEqual(t TestingT, expected, actual interface{}, msgAndArgs ...interface{}) {
+	if helper, ok := t.(interface{ Helper() }); ok {
+		helper.Helper()
+	}
+	if !Equal(t, expected, actual, msgAndArgs...) {
+		t.FailNow()
+	}
+}
+
+/*
+Same as "require.EqualValues", but the failure message shows values as Go
+code. See "Message".
+*/
+func RequireEqualValues(t TestingT, expected, actual interface{}, msgAndArgs ...interface{}) {
+	if helper, ok := t.(interface{ Helper() }); ok {
+		helper.Helper()
+	}
+	if !EqualValues(t, expected, actual, msgAndArgs...) {
+		t.FailNow()
+	}
+}
+
+/*
+Builds a failure message for unequal values, formatted with "Config", followed
+by a line diff if both values are printed on multiple lines. Values which repr
+can't format, such as pointers to non-composite types without
+"repr.Config.PtrFunc", are formatted via "%#v" instead. Useful for custom
+assertions:
+
+	if !assert.ObjectsAreEqual(expected, actual) {
+		t.Fatal(reprtest.Message(expected, actual))
+	}
+*/
+func Message(expected, actual interface{}) string {
+	exp := format(expected)
+	act := format(actual)
+
+	var buf strings.Builder
+	buf.WriteString("Not equal:\nexpected: ")
+	buf.WriteString(indent(exp))
+	buf.WriteString("\nactual  : ")
+	buf.WriteString(indent(act))
+
+	if strings.Contains(exp, "\n") || strings.Contains(act, "\n") {
+		buf.WriteString("\n\nDiff:\n")
+		buf.WriteString(diff(exp, act))
+	}
+	return buf.String()
+}
+
+// Formatting must not crash the test which is reporting a failure.
+func format(val interface{}) (out string) {
+	defer func() {
+		if recover() != nil {
+			out = fmt.Sprintf(`%#v`, val)
+		}
+	}()
+	return repr.StringC(val, Config)
+}
+
+// Aligns continuation lines with the first line, which follows a label.
+func indent(str string) string {
+	return strings.Replace(str, "\n", "\n          ", -1)
+}
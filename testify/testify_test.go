@@ -0,0 +1,119 @@
+package testify
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type mockT struct {
+	errors []string
+	failed bool
+}
+
+func (self *mockT) Errorf(format string, args ...interface{}) {
+	self.errors = append(self.errors, fmt.Sprintf(format, args...))
+}
+
+func (self *mockT) FailNow() { self.failed = true }
+
+type Data struct {
+	Name string
+	List []int
+}
+
+func TestEqual(t *testing.T) {
+	var mock mockT
+	if !Equal(&mock, Data{Name: `one`}, Data{Name: `one`}) {
+		t.Fatalf(`expected equal values to pass`)
+	}
+	if len(mock.errors) > 0 {
+		t.Fatalf(`unexpected errors: %v`, mock.errors)
+	}
+
+	if Equal(&mock, Data{Name: `one`, List: []int{1, 2}}, Data{Name: `two`, List: []int{1, 2}}, `custom %v`, `message`) {
+		t.Fatalf(`expected unequal values to fail`)
+	}
+	if len(mock.errors) != 1 {
+		t.Fatalf(`expected one error, got %v`, mock.errors)
+	}
+
+	msg := mock.errors[0]
+	for _, expected := range []string{
+		`expected: testify.Data{`,
+		"-\tName: \"one\",",
+		"+\tName: \"two\",",
+		`custom message`,
+	} {
+		if !strings.Contains(msg, expected) {
+			t.Fatalf("expected the message to contain:\n%v\nactual message:\n%v", expected, msg)
+		}
+	}
+}
+
+func TestRequireEqualValues(t *testing.T) {
+	var mock mockT
+	RequireEqualValues(&mock, int32(10), int64(10))
+	if mock.failed {
+		t.Fatalf(`expected convertible values to pass`)
+	}
+
+	RequireEqualValues(&mock, int32(10), int64(20))
+	if !mock.failed {
+		t.Fatalf(`expected unequal values to fail the test`)
+	}
+}
+
+func TestMessage(t *testing.T) {
+	test := func(expected, actual string) {
+		t.Helper()
+		if actual != expected {
+			t.Fatalf("expected message:\n%v\nactual message:\n%v", expected, actual)
+		}
+	}
+
+	test("Not equal:\nexpected: 10\nactual  : \"10\"", Message(10, `10`))
+
+	test(`Not equal:
+expected: testify.Data{
+          	Name: "one",
+          	List: []int{1, 2},
+          }
+actual  : testify.Data{
+          	Name: "two",
+          	List: []int{1, 2},
+          }
+
+Diff:
+ testify.Data{
+-	Name: "one",
++	Name: "two",
+ 	List: []int{1, 2},
+ }
+`, Message(Data{Name: `one`, List: []int{1, 2}}, Data{Name: `two`, List: []int{1, 2}}))
+
+	type Opt struct{ Val *int }
+	one, two := 1, 2
+	msg := Message(Opt{&one}, Opt{&two})
+	if !strings.HasPrefix(msg, "Not equal:\nexpected: testify.Opt{Val:(*int)(0x") {
+		t.Fatalf("expected a fallback on %%#v, got:\n%v", msg)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	expected := " one\n-two\n+three\n four\n+five\n"
+	actual := diff("one\ntwo\nfour", "one\nthree\nfour\nfive")
+	if actual != expected {
+		t.Fatalf("expected diff:\n%v\nactual diff:\n%v", expected, actual)
+	}
+
+	// Large inputs skip the quadratic part, but keep common lines.
+	count := maxDiffCells >> 9
+	prev := strings.Repeat("a\n", count) + strings.Repeat("b\n", count) + "c"
+	next := strings.Repeat("a\n", count) + strings.Repeat("d\n", count) + "c"
+	actual = diff(prev, next)
+	expected = strings.Repeat(" a\n", count) + strings.Repeat("-b\n", count) + strings.Repeat("+d\n", count) + " c\n"
+	if actual != expected {
+		t.Fatalf(`unexpected diff of large inputs`)
+	}
+}
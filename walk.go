@@ -0,0 +1,165 @@
+package repr
+
+import (
+	"fmt"
+	"reflect"
+)
+
+/*
+Signature of the callback for "Walk". The path is in the syntax of
+"Config.PathOverrides", such as `.Inputs[2].AbiType`, and is empty for the root
+value. Returning false skips the contents of the value.
+*/
+type WalkFunc func(path string, rval reflect.Value) bool
+
+/*
+Visits the value and its contents in the order in which they would be printed
+with the given config, following the same rules: unexported fields are skipped,
+zero fields are skipped unless "Config.ZeroFields" is set, and "Config.Include",
+"Config.Exclude", "Config.SkipTypes", "Config.FieldValue", "Config.MaxDepth",
+"Config.MaxElems" and others apply. Map entries are visited in the printed
+order, which is random unless "Config.SortKeys" is set. Useful for reusing the
+traversal rules in tools which don't print values, such as scrubbing or
+diffing:
+
+	repr.Walk(val, func(path string, rval reflect.Value) bool {
+		fmt.Println(path, rval.Type())
+		return true
+	}, repr.Default)
+
+Non-nil interfaces are visited as their dynamic values. Pointers are visited
+along with their targets, which share the same path. Cyclic references are
+visited without their contents. Values printed as a whole
+aren't descended into, such as byte slices, implementations of
+"fmt.GoStringer", values replaced via "Config.PathOverrides" or
+"Config.TypeExpr", and values of opaque types.
+*/
+func Walk(val interface{}, fun WalkFunc, conf Config) {
+	rval := reflect.ValueOf(val)
+	if !rval.IsValid() {
+		return
+	}
+	walkValue(rval, fmter{conf: &conf, state: &state{paths: true}}, fun)
+}
+
+func walkValue(rval reflect.Value, fmter fmter, fun WalkFunc) {
+	for rval.Kind() == reflect.Interface && !rval.IsNil() {
+		rval = rval.Elem()
+	}
+	if !fun(fmter.path, rval) {
+		return
+	}
+	if _, ok := fmter.override(rval); ok || fmter.isAtom(rval) {
+		return
+	}
+
+	if mayCycle(rval) {
+		var key visitKey
+		var ok bool
+		fmter, key, _, ok = fmter.visit(rval)
+		if !ok {
+			return
+		}
+		defer fmter.leave(key)
+	}
+
+	switch rval.Kind() {
+	case reflect.Ptr:
+		if !isZeroOrShouldOmit(rval) && isComposite(rval.Type().Elem()) {
+			walkValue(rval.Elem(), fmter, fun)
+		}
+
+	case reflect.Array, reflect.Slice:
+		if rval.Type().Elem() == byteType && fmter.keyedIndexes(rval) == nil {
+			return
+		}
+		if !fmter.atDepthLimit() {
+			walkList(rval, fmter.enter(), fun)
+		}
+
+	case reflect.Map:
+		if !fmter.atDepthLimit() {
+			walkMap(rval, fmter.enter(), fun)
+		}
+
+	case reflect.Struct:
+		if !fmter.atDepthLimit() {
+			walkStruct(rval, fmter.enter(), fun)
+		}
+	}
+}
+
+// Mirrors "appendList".
+func walkList(rval reflect.Value, fmter fmter, fun WalkFunc) {
+	if indexes := fmter.keyedIndexes(rval); indexes != nil {
+		for _, index := range indexes[:fmter.conf.limitElems(len(indexes))] {
+			walkValue(fmter.elemValue(rval.Index(index)), fmter.atIndex(index), fun)
+		}
+		return
+	}
+
+	win := fmter.conf.window(rval.Len())
+	for pos := 0; pos < win.count(); pos++ {
+		index := win.index(pos)
+		walkValue(fmter.elemValue(rval.Index(index)), fmter.atIndex(index), fun)
+	}
+}
+
+// Mirrors "appendMap".
+func walkMap(rval reflect.Value, fmter fmter, fun WalkFunc) {
+	eachMapEntry(rval, fmter, func(entry mapEntry) {
+		walkValue(entry.val, fmter.atKey(entry.key), fun)
+	})
+}
+
+// Mirrors "appendStruct".
+func walkStruct(rval reflect.Value, fmter fmter, fun WalkFunc) {
+	rtype := rval.Type()
+	fields := structMetaOf(rtype).fields
+
+	for pass := 0; pass < fmter.fieldPasses(); pass++ {
+		for i := range fields {
+			field := &fields[i]
+			if !field.exported {
+				continue
+			}
+
+			sfield := field.StructField
+			rfield := fmter.fieldValue(rtype, sfield, rval.Field(i))
+			if fmter.skipField(sfield, rfield, pass) || fmter.fieldName(sfield) == `` {
+				continue
+			}
+			walkValue(rfield, fmter.atField(sfield.Name), fun)
+		}
+	}
+}
+
+// True if the value is printed as a whole by "appendValue", without visiting
+// its contents.
+func (self fmter) isAtom(rval reflect.Value) bool {
+	rtype := rval.Type()
+
+	if _, ok := self.conf.TypeExpr[rtype]; ok && !isNil(rval) {
+		return true
+	}
+	if self.conf.skipsType(rtype) || opaqueType(rtype) != nil {
+		return true
+	}
+	if self.conf.DigestLen > 0 && isDigestable(rval, self.conf.DigestLen) {
+		return true
+	}
+	if rval.CanInterface() {
+		_, ok := rval.Interface().(fmt.GoStringer)
+		return ok
+	}
+	return false
+}
+
+func isComposite(rtype reflect.Type) bool {
+	switch rtype.Kind() {
+	case reflect.Array, reflect.Slice, reflect.Struct, reflect.Map:
+		return true
+	default:
+		return false
+	}
+}
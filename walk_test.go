@@ -0,0 +1,103 @@
+package repr
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWalk(t *testing.T) {
+	type Inner struct {
+		Name string
+		Tags []string
+	}
+	type Outer struct {
+		Id     int
+		Zero   int
+		Inner  *Inner
+		Any    interface{}
+		Map    map[string]Inner
+		Bytes  []byte
+		hidden int
+	}
+
+	val := Outer{
+		Id:     10,
+		Inner:  &Inner{Name: `one`, Tags: []string{`a`, `b`, `c`}},
+		Any:    Inner{Name: `two`},
+		Map:    map[string]Inner{`y`: {Name: `four`}, `x`: {Name: `three`}},
+		Bytes:  []byte{1, 2, 3},
+		hidden: 20,
+	}
+
+	conf := CompactConfig
+	conf.SortKeys = true
+	conf.MaxElems = 2
+
+	var visited []string
+	Walk(val, func(path string, rval reflect.Value) bool {
+		visited = append(visited, path+` `+rval.Type().String())
+		return path != `.Map["y"]`
+	}, conf)
+
+	expected := []string{
+		` repr.Outer`,
+		`.Id int`,
+		`.Inner *repr.Inner`,
+		`.Inner repr.Inner`,
+		`.Inner.Name string`,
+		`.Inner.Tags []string`,
+		`.Inner.Tags[0] string`,
+		`.Inner.Tags[1] string`,
+		`.Any repr.Inner`,
+		`.Any.Name string`,
+		`.Map map[string]repr.Inner`,
+		`.Map["x"] repr.Inner`,
+		`.Map["x"].Name string`,
+		`.Map["y"] repr.Inner`,
+		`.Bytes []uint8`,
+	}
+	if !reflect.DeepEqual(visited, expected) {
+		t.Fatalf("expected:\n%v\nactual:\n%v", strings.Join(expected, "\n"), strings.Join(visited, "\n"))
+	}
+}
+
+func TestWalkRules(t *testing.T) {
+	type Data struct {
+		One   int
+		Two   int
+		Three []int
+	}
+
+	walk := func(val interface{}, conf Config) []string {
+		var visited []string
+		Walk(val, func(path string, _ reflect.Value) bool {
+			visited = append(visited, path)
+			return true
+		}, conf)
+		return visited
+	}
+
+	conf := CompactConfig
+	conf.ZeroFields = true
+	conf.ZeroFieldsLast = true
+	conf.Exclude = []string{`.One`}
+
+	actual := walk(Data{Two: 2, Three: []int{1}}, conf)
+	expected := []string{``, `.Two`, `.Three`, `.Three[0]`}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf(`expected %q, got %q`, expected, actual)
+	}
+
+	conf = CompactConfig
+	conf.MaxDepth = 1
+	actual = walk([]Data{{One: 1}}, conf)
+	expected = []string{``, `[0]`}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf(`expected %q, got %q`, expected, actual)
+	}
+
+	if actual = walk(nil, conf); actual != nil {
+		t.Fatalf(`expected no visits for nil, got %q`, actual)
+	}
+}
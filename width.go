@@ -0,0 +1,93 @@
+package repr
+
+import (
+	"bytes"
+	"reflect"
+)
+
+/*
+Width measurement for layout decisions. Deciding whether a composite literal
+fits on a line requires the width of its single-line form, which is measured
+by rendering it into a scratch buffer before rendering it for real. To keep
+this cheap for large values, rendering is aborted as soon as the output exceeds
+the limit, which bounds the cost of each measurement by the limit rather than
+by the size of the value.
+*/
+
+// Sentinel panic value used for aborting measurement, see "state.checkLimit".
+type tooWide struct{}
+
+// Called for every value while measuring. Aborts the measurement once the
+// output exceeds the limit.
+func (self *state) checkLimit(out []byte) {
+	if self.measuring && len(out) > self.limit {
+		panic(tooWide{})
+	}
+}
+
+// Single-line variant of the formatter.
+func (self fmter) inline() fmter {
+	self.ownConf()
+	self.conf.Indent = ``
+	self.indent = 0
+	return self
+}
+
+// Signature of functions such as "appendStruct", used for measuring.
+type renderFunc func([]byte, reflect.Value, fmter) []byte
+
+// Adapter for "appendAny".
+func appendInterface(out []byte, rval reflect.Value, fmter fmter) []byte {
+	return appendAny(out, rval.Interface(), fmter)
+}
+
+/*
+Reports whether the output of the function for the value, rendered on a single
+line, takes at most "limit" bytes. Statistics, warnings and imports collected during
+measurement are discarded, since the value is rendered again afterwards.
+*/
+func (self fmter) measure(limit int, rval reflect.Value, render renderFunc) (ok bool) {
+	if limit < 0 {
+		return false
+	}
+
+	fmter := self.inline()
+	fmter.state = &state{measuring: true, limit: limit}
+	fmter.visiting = self.visiting.clone()
+	fmter.stack = nil
+
+	// Shared values are printed as names, which affects the width.
+	if self.state != nil {
+		fmter.state.sharing = self.state.sharing
+		fmter.state.aliasing = self.state.aliasing
+		fmter.state.interning = self.state.interning
+	}
+
+	defer func() {
+		val := recover()
+		if val == nil {
+			return
+		}
+		if _, is := val.(tooWide); !is {
+			panic(val)
+		}
+		ok = false
+	}()
+
+	return len(render(nil, rval, fmter)) <= limit
+}
+
+// Reports whether the single-line form of a composite literal fits within
+// "Config.MaxWidth", after the text already on the current line and before a
+// trailing comma.
+func (self fmter) fits(out []byte, rval reflect.Value, render renderFunc) bool {
+	if self.conf.MaxWidth <= 0 || self.conf.SingleLine() || self.conf.ForceMultiline {
+		return false
+	}
+	return self.measure(self.conf.MaxWidth-column(out)-len(`,`), rval, render)
+}
+
+// Number of bytes on the last line of the output.
+func column(out []byte) int {
+	return len(out) - (bytes.LastIndexByte(out, '\n') + 1)
+}
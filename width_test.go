@@ -0,0 +1,76 @@
+package repr
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mitranim/repr/test"
+)
+
+func TestMaxWidth(t *testing.T) {
+	type Inner struct {
+		Name string
+		Nums []int
+	}
+	type Outer struct {
+		Short  Inner
+		Long   Inner
+		Labels map[string]int
+	}
+
+	val := Outer{
+		Short:  Inner{Name: `one`, Nums: []int{1, 2}},
+		Long:   Inner{Name: `a considerably longer name`, Nums: []int{10, 20, 30, 40}},
+		Labels: map[string]int{`one`: 1, `two`: 2},
+	}
+
+	conf := Default
+	conf.SortKeys = true
+	conf.MaxWidth = 60
+
+	actual := StringC(val, conf)
+	expected := `repr.Outer{
+	Short: repr.Inner{Name: "one", Nums: []int{1, 2}},
+	Long: repr.Inner{
+		Name: "a considerably longer name",
+		Nums: []int{10, 20, 30, 40},
+	},
+	Labels: map[string]int{"one": 1, "two": 2},
+}`
+	if actual != expected {
+		t.Fatalf("expected output:\n%v\nactual output:\n%v", expected, actual)
+	}
+
+	conf.ForceMultiline = true
+	if actual := StringC(val.Short, conf); actual != "repr.Inner{\n\tName: \"one\",\n\tNums: []int{\n\t\t1,\n\t\t2,\n\t},\n}" {
+		t.Fatalf("unexpected output with ForceMultiline:\n%v", actual)
+	}
+}
+
+type countingStringer struct{ count *int }
+
+func (self countingStringer) GoString() string {
+	*self.count++
+	return `x`
+}
+
+func TestMeasureStopsAtLimit(t *testing.T) {
+	count := 0
+	list := make([]countingStringer, 1000)
+	for i := range list {
+		list[i] = countingStringer{&count}
+	}
+
+	conf := Default
+	fmter := fmter{conf: &conf}
+	if fmter.measure(10, reflect.ValueOf(list), appendList) {
+		t.Fatalf(`expected the list to exceed the limit`)
+	}
+	if count > 10 {
+		t.Fatalf(`expected measurement to stop early, visited %v elements`, count)
+	}
+
+	if !fmter.measure(100, reflect.ValueOf(test.AbiParam{Name: `one`}), appendInterface) {
+		t.Fatalf(`expected the struct to fit`)
+	}
+}
@@ -0,0 +1,69 @@
+package repr
+
+import (
+	"io"
+)
+
+/*
+Returns a value that formats "val" with the provided config only when needed.
+Implements "fmt.Stringer" and "io.WriterTo", which allows passing it to logging
+functions that may skip formatting, or to copy it into any writer:
+
+	log.Printf("state: %v", repr.Repr(state, repr.DebugConfig))
+
+	_, err := repr.Repr(val, repr.Default).WriteTo(httpResponseWriter)
+
+The value is formatted on every call, reflecting its current state.
+*/
+func Repr(val interface{}, conf Config) Lazy { return Lazy{val, conf} }
+
+/*
+Value with deferred formatting. See "Repr".
+*/
+type Lazy struct {
+	Val  interface{}
+	Conf Config
+}
+
+// Implements "fmt.Stringer". Panics if validation is enabled and fails, see
+// "Config.Validate".
+func (self Lazy) String() string { return StringC(self.Val, self.Conf) }
+
+/*
+Implements "io.WriterTo". Same as "WriteC" with the value and config of this
+instance.
+*/
+func (self Lazy) WriteTo(out io.Writer) (int64, error) {
+	return WriteC(out, self.Val, self.Conf)
+}
+
+/*
+Formats the value using the provided config, writing the output to the writer.
+Formats into a pooled buffer, writing it out in chunks while printing large
+byte slices and arrays, which keeps memory usage bounded regardless of their
+size. Returns the number of bytes written and the first error encountered.
+
+With "Config.Validate", the entire output is buffered and validated before
+writing, and nothing is written if validation fails.
+*/
+func WriteC(out io.Writer, val interface{}, conf Config) (int64, error) {
+	buf := bufPool.Get().(*[]byte)
+	defer putBuffer(buf)
+
+	state := &state{}
+	if !conf.Validate {
+		state.writer = out
+	}
+
+	var err error
+	*buf, err = appendE((*buf)[:0], val, conf, state)
+	if err != nil {
+		return state.written, err
+	}
+	if state.err != nil {
+		return state.written, state.err
+	}
+
+	size, err := out.Write(*buf)
+	return state.written + int64(size), err
+}
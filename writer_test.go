@@ -0,0 +1,77 @@
+package repr
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+)
+
+func TestRepr(t *testing.T) {
+	var _ interface {
+		fmt.Stringer
+		io.WriterTo
+	} = Lazy{}
+
+	val := []int{1, 2, 3}
+	lazy := Repr(val, Default)
+
+	val[0] = 10
+	if lazy.String() != `[]int{10, 2, 3}` {
+		t.Fatalf(`unexpected output: %v`, lazy)
+	}
+
+	var buf bytes.Buffer
+	size, err := Repr(testStructure, Default).WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != String(testStructure) || int(size) != buf.Len() {
+		t.Fatalf(`WriteTo output doesn't match regular output`)
+	}
+
+	conf := Default
+	conf.Validate = true
+	_, err = Repr(invalidGoStringer{}, conf).WriteTo(&buf)
+	if err == nil {
+		t.Fatalf(`expected a validation error`)
+	}
+}
+
+type chunkWriter struct {
+	buf    []byte
+	chunks int
+	max    int
+}
+
+func (self *chunkWriter) Write(src []byte) (int, error) {
+	self.buf = append(self.buf, src...)
+	self.chunks++
+	if len(src) > self.max {
+		self.max = len(src)
+	}
+	return len(src), nil
+}
+
+func TestWriteC(t *testing.T) {
+	val := struct {
+		Name string
+		Blob []byte
+	}{`blob`, bytes.Repeat([]byte(`hello world`), 1<<15)}
+
+	for _, conf := range []Config{Default, CompactConfig} {
+		var out chunkWriter
+		size, err := WriteC(&out, val, conf)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := StringC(val, conf)
+		if string(out.buf) != expected || int(size) != len(expected) {
+			t.Fatalf(`streamed output doesn't match regular output`)
+		}
+		if out.chunks < 2 || out.max > flushSize*2 {
+			t.Fatalf(`expected output in bounded chunks, got %v chunks of up to %v bytes`, out.chunks, out.max)
+		}
+	}
+}